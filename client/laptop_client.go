@@ -3,8 +3,10 @@ package client
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"gobook/pb"
+	"hash/crc32"
 	"io"
 	"log"
 	"os"
@@ -13,9 +15,19 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
+// castagnoliTable computes the CRC-32C checksums of the uploaded chunks
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// UploadRouteHeader is the metadata sent with every call of a resumable upload, set to the laptop id.
+// An upload is kept by the server instance that started it, so a load balancer in front of
+// several instances routes the calls by this header, as in nginx.conf.
+const UploadRouteHeader = "upload-route"
+
 type LaptopClient struct {
 	service pb.LaptopServiceClient
 }
@@ -47,6 +59,163 @@ func (client *LaptopClient) CreateLaptop(laptop *pb.Laptop) {
 	log.Printf("create laptop with id: %s", res.Id)
 }
 
+// CreateLaptops creates the laptops in a single stream and returns the result of each one.
+// An atomic batch creates every laptop or none of them.
+func (client *LaptopClient) CreateLaptops(laptops []*pb.Laptop, atomic bool) (*pb.CreateLaptopsResponse, error) {
+	stream, err := client.service.CreateLaptops(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("cannot create laptops: %v", err)
+	}
+
+	req := &pb.CreateLaptopsRequest{
+		Data: &pb.CreateLaptopsRequest_Options{
+			Options: &pb.CreateLaptopsOptions{
+				Atomic: atomic,
+			},
+		},
+	}
+
+	err = stream.Send(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send options: %v - %v", err, stream.RecvMsg(nil))
+	}
+
+	for _, laptop := range laptops {
+		req := &pb.CreateLaptopsRequest{
+			Data: &pb.CreateLaptopsRequest_Laptop{
+				Laptop: laptop,
+			},
+		}
+
+		err := stream.Send(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot send laptop: %v - %v", err, stream.RecvMsg(nil))
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fmt.Errorf("cannot receive response: %v", err)
+	}
+
+	log.Printf("created %d of %d laptops", res.GetCreatedCount(), len(laptops))
+	return res, nil
+}
+
+func (client *LaptopClient) GetLaptop(id string) (*pb.Laptop, error) {
+	req := &pb.GetLaptopRequest{
+		Id: id,
+	}
+
+	res, err := client.service.GetLaptop(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get laptop: %v", err)
+	}
+
+	return res.GetLaptop(), nil
+}
+
+// UpdateLaptop replaces the stored laptop, or only the given field paths if any are passed.
+// The update is rejected if the laptop revision is set and no longer the stored one.
+func (client *LaptopClient) UpdateLaptop(laptop *pb.Laptop, paths ...string) error {
+	req := &pb.UpdateLaptopRequest{
+		Laptop:           laptop,
+		ExpectedRevision: laptop.GetRevision(),
+	}
+
+	if len(paths) > 0 {
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: paths}
+	}
+
+	_, err := client.service.UpdateLaptop(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("cannot update laptop: %v", err)
+	}
+
+	log.Printf("update laptop with id: %s", laptop.GetId())
+	return nil
+}
+
+func (client *LaptopClient) DeleteLaptop(id string) error {
+	req := &pb.DeleteLaptopRequest{
+		Id: id,
+	}
+
+	_, err := client.service.DeleteLaptop(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("cannot delete laptop: %v", err)
+	}
+
+	log.Printf("delete laptop with id: %s", id)
+	return nil
+}
+
+// ListDeletedLaptops returns the laptops of the trash
+func (client *LaptopClient) ListDeletedLaptops() ([]*pb.Laptop, error) {
+	stream, err := client.service.ListDeletedLaptops(context.Background(), &pb.ListDeletedLaptopsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list deleted laptops: %v", err)
+	}
+
+	laptops := []*pb.Laptop{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return laptops, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot receive deleted laptop: %v", err)
+		}
+
+		laptops = append(laptops, res.GetLaptop())
+	}
+}
+
+// UndeleteLaptop moves a laptop back from the trash and returns it
+func (client *LaptopClient) UndeleteLaptop(id string) (*pb.Laptop, error) {
+	req := &pb.UndeleteLaptopRequest{
+		Id: id,
+	}
+
+	res, err := client.service.UndeleteLaptop(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot undelete laptop: %v", err)
+	}
+
+	log.Printf("undelete laptop with id: %s", id)
+	return res.GetLaptop(), nil
+}
+
+// ListLaptopRevisions returns every version of a laptop, oldest first
+func (client *LaptopClient) ListLaptopRevisions(id string) ([]*pb.Laptop, error) {
+	req := &pb.ListLaptopRevisionsRequest{
+		Id: id,
+	}
+
+	res, err := client.service.ListLaptopRevisions(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list laptop revisions: %v", err)
+	}
+
+	return res.GetRevisions(), nil
+}
+
+// DiffLaptopRevisions returns the fields changed between two revisions of a laptop
+func (client *LaptopClient) DiffLaptopRevisions(id string, fromRevision uint64, toRevision uint64) ([]*pb.FieldChange, error) {
+	req := &pb.DiffLaptopRevisionsRequest{
+		Id:           id,
+		FromRevision: fromRevision,
+		ToRevision:   toRevision,
+	}
+
+	res, err := client.service.DiffLaptopRevisions(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot diff laptop revisions: %v", err)
+	}
+
+	return res.GetChanges(), nil
+}
+
 func (client *LaptopClient) SearchLaptop(filter *pb.Filter) {
 	log.Print("search filter", filter)
 
@@ -80,6 +249,31 @@ func (client *LaptopClient) SearchLaptop(filter *pb.Filter) {
 	}
 }
 
+// ListLaptops fetches every page of laptops matching the filter in the given order
+func (client *LaptopClient) ListLaptops(filter *pb.Filter, orderBy string, pageSize uint32) ([]*pb.Laptop, error) {
+	req := &pb.ListLaptopsRequest{
+		Filter:   filter,
+		PageSize: pageSize,
+		OrderBy:  orderBy,
+	}
+
+	laptops := []*pb.Laptop{}
+	for {
+		res, err := client.service.ListLaptops(context.Background(), req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list laptops: %v", err)
+		}
+
+		laptops = append(laptops, res.GetLaptops()...)
+		log.Printf("receive a page of %d laptops", len(res.GetLaptops()))
+
+		if len(res.GetNextPageToken()) == 0 {
+			return laptops, nil
+		}
+		req.PageToken = res.GetNextPageToken()
+	}
+}
+
 func (client *LaptopClient) UploadImage(laptopID string, imagePath string) {
 	file, err := os.Open(imagePath)
 
@@ -150,6 +344,142 @@ func (client *LaptopClient) UploadImage(laptopID string, imagePath string) {
 	log.Printf("image upload with id: %s and size: %d ", res.GetId(), res.GetSize())
 }
 
+// UploadImageResumable uploads an image in chunks of chunkSize and returns its id. With the
+// id of an upload cut off earlier, it resumes that upload from the offset the server has.
+func (client *LaptopClient) UploadImageResumable(
+	laptopID string,
+	imagePath string,
+	uploadID string,
+	chunkSize int,
+) (string, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("cannot open file: %v", err)
+	}
+	defer file.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UploadRouteHeader, laptopID)
+
+	offset := int64(0)
+	if len(uploadID) == 0 {
+		req := &pb.StartImageUploadRequest{
+			Info: &pb.ImageInfo{
+				LaptopId:  laptopID,
+				ImageType: filepath.Ext(imagePath),
+			},
+		}
+
+		res, err := client.service.StartImageUpload(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("cannot start upload: %v", err)
+		}
+		uploadID = res.GetUploadId()
+	} else {
+		res, err := client.service.GetImageUpload(ctx, &pb.GetImageUploadRequest{UploadId: uploadID})
+		if err != nil {
+			return "", fmt.Errorf("cannot get upload: %v", err)
+		}
+		offset = int64(res.GetCommittedOffset())
+		log.Printf("resume upload %s at offset %d", uploadID, offset)
+	}
+
+	// The digest covers the whole image, including the part uploaded before resuming
+	digest := sha256.New()
+	_, err = io.CopyN(digest, file, offset)
+	if err != nil {
+		return "", fmt.Errorf("cannot read file: %v", err)
+	}
+
+	buffer := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(file, buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", fmt.Errorf("cannot read file: %v", err)
+		}
+		digest.Write(buffer[:n])
+
+		req := &pb.UploadImageChunkRequest{
+			UploadId: uploadID,
+			Offset:   uint64(offset),
+			Data:     buffer[:n],
+			Crc32C:   crc32.Checksum(buffer[:n], castagnoliTable),
+		}
+
+		res, err := client.service.UploadImageChunk(ctx, req)
+		if err != nil {
+			return uploadID, fmt.Errorf("cannot upload chunk of upload %s: %v", uploadID, err)
+		}
+		offset = int64(res.GetCommittedOffset())
+	}
+
+	req := &pb.FinishImageUploadRequest{
+		UploadId: uploadID,
+		Sha256:   digest.Sum(nil),
+	}
+
+	res, err := client.service.FinishImageUpload(ctx, req)
+	if err != nil {
+		return uploadID, fmt.Errorf("cannot finish upload %s: %v", uploadID, err)
+	}
+
+	log.Printf("image upload with id: %s and size: %d ", res.GetId(), res.GetSize())
+	return res.GetId(), nil
+}
+
+// ListLaptopImages returns the info of the images of a laptop, oldest first
+func (client *LaptopClient) ListLaptopImages(laptopID string) ([]*pb.ImageInfo, error) {
+	req := &pb.ListLaptopImagesRequest{
+		LaptopId: laptopID,
+	}
+
+	res, err := client.service.ListLaptopImages(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list laptop images: %v", err)
+	}
+
+	return res.GetImages(), nil
+}
+
+// DownloadImage writes the data of an image, or of its variant if one is named, to writer and returns its info
+func (client *LaptopClient) DownloadImage(imageID string, variant string, writer io.Writer) (*pb.ImageInfo, error) {
+	req := &pb.DownloadImageRequest{
+		Id:      imageID,
+		Variant: variant,
+	}
+
+	stream, err := client.service.DownloadImage(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download image: %v", err)
+	}
+
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("cannot receive image info: %v", err)
+	}
+	info := res.GetInfo()
+
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot receive chunk data: %v", err)
+		}
+
+		_, err = writer.Write(res.GetChunkData())
+		if err != nil {
+			return nil, fmt.Errorf("cannot write chunk data: %v", err)
+		}
+	}
+
+	log.Printf("image download with id: %s and size: %d", imageID, info.GetSize())
+	return info, nil
+}
+
 func (client *LaptopClient) RateLaptop(laptopIDs []string, score []float64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
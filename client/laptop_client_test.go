@@ -11,12 +11,14 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestUploadImageClient(t *testing.T) {
@@ -85,6 +87,50 @@ func TestUploadImageClient(t *testing.T) {
 	require.FileExists(t, saveImagePath)
 }
 
+func TestUploadImageResumableRoute(t *testing.T) {
+	laptop := sample.NewLaptop()
+	laptopStore := service.NewInMemoryLaptopStore()
+	require.NoError(t, laptopStore.Save(laptop))
+	imageStore := service.NewDiskImageStore(t.TempDir())
+
+	// Every call of the upload carries the laptop id, so a load balancer sends them to one instance
+	var mutex sync.Mutex
+	routes := make(map[string][]string)
+	interceptor := func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mutex.Lock()
+		routes[info.FullMethod] = md.Get(UploadRouteHeader)
+		mutex.Unlock()
+		return handler(ctx, req)
+	}
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(interceptor))
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(laptopStore, imageStore, nil))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	imageID, err := NewLaptopClient(conn).UploadImageResumable(laptop.Id, "../tmp/laptop.jpg", "", 1024)
+	require.NoError(t, err)
+	require.NotEmpty(t, imageID)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, method := range []string{"StartImageUpload", "UploadImageChunk", "FinishImageUpload"} {
+		require.Equal(t, []string{laptop.Id}, routes["/pb.LaptopService/"+method], method)
+	}
+}
+
 func TestRateLaptop(t *testing.T) {
 	laptopStore := service.NewInMemoryLaptopStore()
 	ratingStore := service.NewInMemoryRatingStore()
@@ -30,9 +30,9 @@ func main() {
 	// }
 
 	// filter := &pb.Filter{
-	// 	MaxPriceUsd: 3000,
-	// 	MinCpuCores: 4,
-	// 	MinCpuGhz:   2.5,
+	// 	MaxPriceUsd: proto.Float64(3000),
+	// 	MinCpuCores: proto.Uint32(4),
+	// 	MinCpuGhz:   proto.Float64(2.5),
 	// 	MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
 	// }
 
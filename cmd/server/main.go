@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"gobook/pb"
@@ -12,6 +14,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"path/filepath"
 
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc"
@@ -21,11 +24,29 @@ import (
 
 func accessibleRoles() map[string][]string {
 	const laptopServicePath = "/pb.LaptopService/"
+	const adminServicePath = "/pb.AdminService/"
 
 	return map[string][]string{
 		laptopServicePath + "CreateLaptopService": {"admin"},
+		laptopServicePath + "CreateLaptops":       {"admin"},
+		laptopServicePath + "GetLaptop":           {"admin", "user"},
+		laptopServicePath + "UpdateLaptop":        {"admin"},
+		laptopServicePath + "DeleteLaptop":        {"admin"},
+		laptopServicePath + "ListDeletedLaptops":  {"admin"},
+		laptopServicePath + "UndeleteLaptop":      {"admin"},
+		laptopServicePath + "ListLaptopRevisions": {"admin"},
+		laptopServicePath + "GetLaptopRevision":   {"admin"},
+		laptopServicePath + "DiffLaptopRevisions": {"admin"},
 		laptopServicePath + "UploadImageService":  {"admin"},
+		laptopServicePath + "StartImageUpload":    {"admin"},
+		laptopServicePath + "UploadImageChunk":    {"admin"},
+		laptopServicePath + "GetImageUpload":      {"admin"},
+		laptopServicePath + "FinishImageUpload":   {"admin"},
+		laptopServicePath + "ListLaptopImages":    {"admin", "user"},
+		laptopServicePath + "DownloadImage":       {"admin", "user"},
 		laptopServicePath + "RateLaptopService":   {"admin", "user"},
+		adminServicePath + "ExportCatalog":        {"admin"},
+		adminServicePath + "ImportCatalog":        {"admin"},
 	}
 }
 
@@ -35,6 +56,8 @@ const (
 	serverCertFile   = "cert/server-cert.pem"
 	serverKeyFile    = "cert/server-key.pem"
 	clientCACertFile = "cert/ca-cert.pem"
+	// imageFolder holds the files of the uploaded images and their variants
+	imageFolder = "img"
 )
 
 func loadTLSCredentials() (credentials.TransportCredentials, error) {
@@ -65,6 +88,161 @@ func loadTLSCredentials() (credentials.TransportCredentials, error) {
 	return credentials.NewTLS(config), nil
 }
 
+// loadClusterTLS loads the server certificate, presented to the other cluster nodes,
+// and the CA that signed the certificates of the nodes
+func loadClusterTLS(peerName string) (service.ClusterTLS, error) {
+	pemCA, err := os.ReadFile(clientCACertFile)
+	if err != nil {
+		return service.ClusterTLS{}, err
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(pemCA) {
+		return service.ClusterTLS{}, fmt.Errorf("failed to add cluster CA's certificate")
+	}
+
+	serverCert, err := tls.LoadX509KeyPair(serverCertFile, serverKeyFile)
+	if err != nil {
+		return service.ClusterTLS{}, err
+	}
+
+	clusterTLS := service.ClusterTLS{
+		Certificate: serverCert,
+		CAs:         certPool,
+		PeerName:    peerName,
+	}
+
+	return clusterTLS, nil
+}
+
+// stores are the stores used by the server
+type stores struct {
+	laptop service.LaptopStore
+	image  service.ImageStore
+	// imageIndex is the metadata of the images of the image store, both are nil with the raft driver
+	imageIndex service.ImageIndex
+	rating     service.RatingStore
+	user       service.UserStore
+	// revision keeps every version of the laptops
+	revision service.LaptopRevisionStore
+	// cluster is set by the raft driver
+	cluster *service.Cluster
+}
+
+// newStores creates the stores of the storage driver. The memory laptop store is seeded with a sample laptop.
+// The wal driver logs the changes of the in-memory laptop and rating stores to disk.
+// The sqlite driver only persists the laptops, the bolt driver persists every store in the same file.
+// The raft driver replicates the laptops, users, ratings, laptop revisions and idempotency keys to the cluster peers, node being the id of this one.
+func newStores(driver string, path string, config util.Config, node string) (*stores, error) {
+	switch driver {
+	case "", "memory":
+		laptopStore := service.NewInMemoryLaptopStore()
+		laptop := sample.NewLaptop()
+		log.Println("Laptop ID: ", laptop.Id)
+		err := laptopStore.Save(laptop)
+		if err != nil {
+			return nil, err
+		}
+		imageIndex := service.NewInMemoryImageIndex()
+		return &stores{
+			laptop:     laptopStore,
+			image:      service.NewDiskImageStoreWithIndex(imageFolder, imageIndex),
+			imageIndex: imageIndex,
+			rating:     service.NewInMemoryRatingStore(),
+			user:       service.NewInMemoryUserStore(),
+			revision:   service.NewInMemoryLaptopRevisionStore(),
+		}, nil
+	case "wal":
+		options := service.WALOptions{
+			Dir:           config.WALDir,
+			SnapshotEvery: config.WALSnapshotEvery,
+			Sync:          config.WALSync,
+		}
+		log.Printf("log laptop, rating and revision changes in %s", options.Dir)
+		laptopStore, err := service.NewInMemoryLaptopStoreWithWAL(options)
+		if err != nil {
+			return nil, err
+		}
+		ratingStore, err := service.NewInMemoryRatingStoreWithWAL(options)
+		if err != nil {
+			return nil, err
+		}
+		revisionStore, err := service.NewInMemoryLaptopRevisionStoreWithWAL(options)
+		if err != nil {
+			return nil, err
+		}
+		imageIndex := service.NewInMemoryImageIndex()
+		return &stores{
+			laptop:     laptopStore,
+			image:      service.NewDiskImageStoreWithIndex(imageFolder, imageIndex),
+			imageIndex: imageIndex,
+			rating:     ratingStore,
+			user:       service.NewInMemoryUserStore(),
+			revision:   revisionStore,
+		}, nil
+	case "sqlite":
+		log.Printf("store laptops and their revisions in sqlite database %s", path)
+		laptopStore, err := service.NewSQLiteLaptopStore(path)
+		if err != nil {
+			return nil, err
+		}
+		imageIndex := service.NewInMemoryImageIndex()
+		return &stores{
+			laptop:     laptopStore,
+			image:      service.NewDiskImageStoreWithIndex(imageFolder, imageIndex),
+			imageIndex: imageIndex,
+			rating:     service.NewInMemoryRatingStore(),
+			user:       service.NewInMemoryUserStore(),
+			revision:   service.NewSQLiteLaptopRevisionStore(laptopStore),
+		}, nil
+	case "bolt":
+		log.Printf("store everything in bolt database %s", path)
+		db, err := service.OpenBoltDB(path)
+		if err != nil {
+			return nil, err
+		}
+		imageIndex := service.NewBoltImageIndex(db)
+		return &stores{
+			laptop:     service.NewBoltLaptopStore(db),
+			image:      service.NewDiskImageStoreWithIndex(imageFolder, imageIndex),
+			imageIndex: imageIndex,
+			rating:     service.NewBoltRatingStore(db),
+			user:       service.NewBoltUserStore(db),
+			revision:   service.NewBoltLaptopRevisionStore(db),
+		}, nil
+	case "raft":
+		peers, err := service.ParseClusterPeers(config.ClusterPeers)
+		if err != nil {
+			return nil, err
+		}
+		clusterTLS, err := loadClusterTLS(config.ClusterPeerName)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load cluster tls: %w", err)
+		}
+		log.Printf("replicate stores as cluster node %s with peers %v", node, peers)
+		cluster, err := service.NewCluster(service.ClusterConfig{
+			NodeID:  node,
+			Peers:   peers,
+			DataDir: filepath.Join(config.ClusterDataDir, node),
+			TLS:     clusterTLS,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Image files stay on the disk of the node that received them, so the cluster
+		// stores no images and rejects the image RPCs rather than serve them from one node only
+		return &stores{
+			laptop:   cluster.LaptopStore(),
+			rating:   cluster.RatingStore(),
+			user:     cluster.UserStore(),
+			revision: cluster.LaptopRevisionStore(),
+			cluster:  cluster,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
 func main() {
 
 	config, err := util.LoadConfig("./app.env")
@@ -72,6 +250,8 @@ func main() {
 		log.Fatal("cannot load .env file ", err)
 	}
 	port := flag.Int("port", 0, "server port running")
+	storage := flag.String("storage", config.StorageDriver, "storage driver: memory, wal, sqlite, bolt or raft, which stores no images")
+	node := flag.String("node", config.ClusterNodeID, "cluster node id of the raft storage driver")
 	flag.Parse()
 
 	log.Printf("server running on port %d ", *port)
@@ -90,17 +270,33 @@ func main() {
 	}
 
 	//Create store
-	store := service.NewInMemoryLaptopStore()
-	laptop := sample.NewLaptop()
-	log.Println("Laptop ID: ", laptop.Id)
-	err = store.Save(laptop)
+	stores, err := newStores(*storage, config.StoragePath, config, *node)
 	if err != nil {
-		log.Fatal("cannot save laptop ", err)
+		log.Fatal("cannot create stores ", err)
+	}
+	imageVariants, err := service.ParseImageVariants(config.ImageVariants)
+	if err != nil {
+		log.Fatal("cannot parse image variants ", err)
+	}
+	var imageResizer *service.ImageResizer
+	if len(imageVariants) > 0 && stores.image != nil {
+		imageResizer = service.NewImageResizer(stores.image, imageVariants, config.ImageResizeWorkers)
+	}
+	// The cluster nodes share the idempotency keys, so a retry sent to another node is not run twice
+	idempotencyStore := service.NewInMemoryIdempotencyStore(config.IdempotencyTTL)
+	if stores.cluster != nil {
+		idempotencyStore = stores.cluster.IdempotencyStore(config.IdempotencyTTL)
 	}
-	imageStore := service.NewDiskImageStore("img")
-	ratingStore := service.NewInMemoryRatingStore()
 	//Create Server
-	laptopServer := service.NewLaptopServer(store, imageStore, ratingStore)
+	laptopServer := service.NewLaptopServer(
+		stores.laptop,
+		stores.image,
+		stores.rating,
+		service.WithIdempotencyStore(idempotencyStore),
+		service.WithRevisionStore(stores.revision),
+		service.WithImageUploadTTL(config.ImageUploadTTL),
+		service.WithImageResizer(imageResizer),
+	)
 	//Create grpc server
 	grpcServer := grpc.NewServer(serverOptions...)
 	//RegisterServer
@@ -116,13 +312,31 @@ func main() {
 		HasedPassword: string(hashedPassword),
 		Role:          "admin",
 	}
-	userStore := service.NewInMemoryUserStore()
-	err = userStore.Save(user)
-	if err != nil {
+	if stores.cluster != nil {
+		// Writes need a leader, which needs a majority of the peers to be up
+		log.Print("wait for the cluster to elect a leader")
+		err = stores.cluster.WaitForLeader(context.Background())
+		if err != nil {
+			log.Fatal("cannot elect cluster leader ", err)
+		}
+	}
+	// A persistent or replicated user store may already have the user
+	err = stores.user.Save(user)
+	if err != nil && !errors.Is(err, service.ErrAlreadyExists) {
 		log.Fatal("cannot save user ", err)
 	}
-	authServer := service.NewAuthServer(userStore, jwtManager)
+	authServer := service.NewAuthServer(stores.user, jwtManager)
 	pb.RegisterAuthServiceServer(grpcServer, authServer)
+	purger := service.NewLaptopPurger(
+		stores.laptop,
+		stores.image,
+		stores.rating,
+		stores.revision,
+		config.PurgeRetention,
+	)
+	go purger.Run(context.Background(), config.PurgeInterval)
+	adminServer := service.NewAdminServer(stores.laptop, stores.rating, stores.user, stores.imageIndex, imageFolder)
+	pb.RegisterAdminServiceServer(grpcServer, adminServer)
 	reflection.Register(grpcServer)
 	address := fmt.Sprintf("0.0.0.0:%d", *port)
 	listener, err := net.Listen("tcp", address)
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/service"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessibleRolesCoverServices(t *testing.T) {
+	// publicMethods are the read-only methods open to clients without a token
+	publicMethods := map[string]bool{
+		"/pb.LaptopService/ListLaptops":         true,
+		"/pb.LaptopService/SearchFacets":        true,
+		"/pb.LaptopService/SearchLaptopService": true,
+		"/pb.LaptopService/WatchLaptops":        true,
+	}
+
+	roles := accessibleRoles()
+	for _, desc := range []grpc.ServiceDesc{pb.LaptopService_ServiceDesc, pb.AdminService_ServiceDesc} {
+		methods := []string{}
+		for _, method := range desc.Methods {
+			methods = append(methods, method.MethodName)
+		}
+		for _, stream := range desc.Streams {
+			methods = append(methods, stream.StreamName)
+		}
+
+		for _, method := range methods {
+			path := "/" + desc.ServiceName + "/" + method
+			if !publicMethods[path] {
+				require.NotEmpty(t, roles[path], "%s has no roles", path)
+			}
+		}
+	}
+}
+
+func TestCreateLaptopsWithoutToken(t *testing.T) {
+	laptopStore := service.NewInMemoryLaptopStore()
+	jwtManager := service.NewJWTToken("e8c17fd65e37a83147f021726921fe75")
+	interceptor := service.NewAuthInterceptor(jwtManager, accessibleRoles())
+
+	grpcServer := grpc.NewServer(grpc.StreamInterceptor(interceptor.Stream()))
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(laptopStore, nil, nil))
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := pb.NewLaptopServiceClient(conn).CreateLaptops(context.Background())
+	require.NoError(t, err)
+
+	_, err = stream.CloseAndRecv()
+	require.Error(t, err)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
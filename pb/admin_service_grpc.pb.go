@@ -0,0 +1,204 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: admin_service.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	// ExportCatalog leaves out the laptops in the trash, with their ratings and images
+	ExportCatalog(ctx context.Context, in *ExportCatalogRequest, opts ...grpc.CallOption) (AdminService_ExportCatalogClient, error)
+	ImportCatalog(ctx context.Context, opts ...grpc.CallOption) (AdminService_ImportCatalogClient, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) ExportCatalog(ctx context.Context, in *ExportCatalogRequest, opts ...grpc.CallOption) (AdminService_ExportCatalogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdminService_ServiceDesc.Streams[0], "/pb.AdminService/ExportCatalog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminServiceExportCatalogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AdminService_ExportCatalogClient interface {
+	Recv() (*ExportCatalogResponse, error)
+	grpc.ClientStream
+}
+
+type adminServiceExportCatalogClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminServiceExportCatalogClient) Recv() (*ExportCatalogResponse, error) {
+	m := new(ExportCatalogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adminServiceClient) ImportCatalog(ctx context.Context, opts ...grpc.CallOption) (AdminService_ImportCatalogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdminService_ServiceDesc.Streams[1], "/pb.AdminService/ImportCatalog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminServiceImportCatalogClient{stream}
+	return x, nil
+}
+
+type AdminService_ImportCatalogClient interface {
+	Send(*ImportCatalogRequest) error
+	CloseAndRecv() (*ImportCatalogResponse, error)
+	grpc.ClientStream
+}
+
+type adminServiceImportCatalogClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminServiceImportCatalogClient) Send(m *ImportCatalogRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *adminServiceImportCatalogClient) CloseAndRecv() (*ImportCatalogResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportCatalogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	// ExportCatalog leaves out the laptops in the trash, with their ratings and images
+	ExportCatalog(*ExportCatalogRequest, AdminService_ExportCatalogServer) error
+	ImportCatalog(AdminService_ImportCatalogServer) error
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) ExportCatalog(*ExportCatalogRequest, AdminService_ExportCatalogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportCatalog not implemented")
+}
+func (UnimplementedAdminServiceServer) ImportCatalog(AdminService_ImportCatalogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportCatalog not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_ExportCatalog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportCatalogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServiceServer).ExportCatalog(m, &adminServiceExportCatalogServer{stream})
+}
+
+type AdminService_ExportCatalogServer interface {
+	Send(*ExportCatalogResponse) error
+	grpc.ServerStream
+}
+
+type adminServiceExportCatalogServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminServiceExportCatalogServer) Send(m *ExportCatalogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AdminService_ImportCatalog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AdminServiceServer).ImportCatalog(&adminServiceImportCatalogServer{stream})
+}
+
+type AdminService_ImportCatalogServer interface {
+	SendAndClose(*ImportCatalogResponse) error
+	Recv() (*ImportCatalogRequest, error)
+	grpc.ServerStream
+}
+
+type adminServiceImportCatalogServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminServiceImportCatalogServer) SendAndClose(m *ImportCatalogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *adminServiceImportCatalogServer) Recv() (*ImportCatalogRequest, error) {
+	m := new(ImportCatalogRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportCatalog",
+			Handler:       _AdminService_ExportCatalog_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportCatalog",
+			Handler:       _AdminService_ImportCatalog_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "admin_service.proto",
+}
@@ -0,0 +1,2202 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.21.12
+// source: cluster_service.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type IdempotencyOutcome int32
+
+const (
+	// IDEMPOTENCY_RESERVED means the request must run, then finish or cancel its key
+	IdempotencyOutcome_IDEMPOTENCY_RESERVED   IdempotencyOutcome = 0
+	IdempotencyOutcome_IDEMPOTENCY_REPLAYED   IdempotencyOutcome = 1
+	IdempotencyOutcome_IDEMPOTENCY_KEY_REUSED IdempotencyOutcome = 2
+	IdempotencyOutcome_IDEMPOTENCY_KEY_IN_USE IdempotencyOutcome = 3
+)
+
+// Enum value maps for IdempotencyOutcome.
+var (
+	IdempotencyOutcome_name = map[int32]string{
+		0: "IDEMPOTENCY_RESERVED",
+		1: "IDEMPOTENCY_REPLAYED",
+		2: "IDEMPOTENCY_KEY_REUSED",
+		3: "IDEMPOTENCY_KEY_IN_USE",
+	}
+	IdempotencyOutcome_value = map[string]int32{
+		"IDEMPOTENCY_RESERVED":   0,
+		"IDEMPOTENCY_REPLAYED":   1,
+		"IDEMPOTENCY_KEY_REUSED": 2,
+		"IDEMPOTENCY_KEY_IN_USE": 3,
+	}
+)
+
+func (x IdempotencyOutcome) Enum() *IdempotencyOutcome {
+	p := new(IdempotencyOutcome)
+	*p = x
+	return p
+}
+
+func (x IdempotencyOutcome) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (IdempotencyOutcome) Descriptor() protoreflect.EnumDescriptor {
+	return file_cluster_service_proto_enumTypes[0].Descriptor()
+}
+
+func (IdempotencyOutcome) Type() protoreflect.EnumType {
+	return &file_cluster_service_proto_enumTypes[0]
+}
+
+func (x IdempotencyOutcome) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use IdempotencyOutcome.Descriptor instead.
+func (IdempotencyOutcome) EnumDescriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{0}
+}
+
+type SaveLaptopCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+}
+
+func (x *SaveLaptopCommand) Reset() {
+	*x = SaveLaptopCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SaveLaptopCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveLaptopCommand) ProtoMessage() {}
+
+func (x *SaveLaptopCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveLaptopCommand.ProtoReflect.Descriptor instead.
+func (*SaveLaptopCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SaveLaptopCommand) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+// SaveLaptopsCommand saves every laptop, or none of them if one already exists
+type SaveLaptopsCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptops []*Laptop `protobuf:"bytes,1,rep,name=laptops,proto3" json:"laptops,omitempty"`
+}
+
+func (x *SaveLaptopsCommand) Reset() {
+	*x = SaveLaptopsCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SaveLaptopsCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveLaptopsCommand) ProtoMessage() {}
+
+func (x *SaveLaptopsCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveLaptopsCommand.ProtoReflect.Descriptor instead.
+func (*SaveLaptopsCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SaveLaptopsCommand) GetLaptops() []*Laptop {
+	if x != nil {
+		return x.Laptops
+	}
+	return nil
+}
+
+type UpdateLaptopCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop           *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+	ExpectedRevision uint64  `protobuf:"varint,2,opt,name=expected_revision,json=expectedRevision,proto3" json:"expected_revision,omitempty"`
+}
+
+func (x *UpdateLaptopCommand) Reset() {
+	*x = UpdateLaptopCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateLaptopCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateLaptopCommand) ProtoMessage() {}
+
+func (x *UpdateLaptopCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateLaptopCommand.ProtoReflect.Descriptor instead.
+func (*UpdateLaptopCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UpdateLaptopCommand) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+func (x *UpdateLaptopCommand) GetExpectedRevision() uint64 {
+	if x != nil {
+		return x.ExpectedRevision
+	}
+	return 0
+}
+
+type DeleteLaptopCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id               string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ExpectedRevision uint64 `protobuf:"varint,2,opt,name=expected_revision,json=expectedRevision,proto3" json:"expected_revision,omitempty"`
+	// deleted_at is set by the node proposing the command, so every node trashes the laptop at the same time
+	DeletedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+}
+
+func (x *DeleteLaptopCommand) Reset() {
+	*x = DeleteLaptopCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteLaptopCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteLaptopCommand) ProtoMessage() {}
+
+func (x *DeleteLaptopCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteLaptopCommand.ProtoReflect.Descriptor instead.
+func (*DeleteLaptopCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeleteLaptopCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteLaptopCommand) GetExpectedRevision() uint64 {
+	if x != nil {
+		return x.ExpectedRevision
+	}
+	return 0
+}
+
+func (x *DeleteLaptopCommand) GetDeletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return nil
+}
+
+type UndeleteLaptopCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id               string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ExpectedRevision uint64 `protobuf:"varint,2,opt,name=expected_revision,json=expectedRevision,proto3" json:"expected_revision,omitempty"`
+}
+
+func (x *UndeleteLaptopCommand) Reset() {
+	*x = UndeleteLaptopCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UndeleteLaptopCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UndeleteLaptopCommand) ProtoMessage() {}
+
+func (x *UndeleteLaptopCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UndeleteLaptopCommand.ProtoReflect.Descriptor instead.
+func (*UndeleteLaptopCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UndeleteLaptopCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UndeleteLaptopCommand) GetExpectedRevision() uint64 {
+	if x != nil {
+		return x.ExpectedRevision
+	}
+	return 0
+}
+
+type PurgeLaptopCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *PurgeLaptopCommand) Reset() {
+	*x = PurgeLaptopCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PurgeLaptopCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeLaptopCommand) ProtoMessage() {}
+
+func (x *PurgeLaptopCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeLaptopCommand.ProtoReflect.Descriptor instead.
+func (*PurgeLaptopCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PurgeLaptopCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type SaveUserCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	User *UserRecord `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+}
+
+func (x *SaveUserCommand) Reset() {
+	*x = SaveUserCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SaveUserCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveUserCommand) ProtoMessage() {}
+
+func (x *SaveUserCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveUserCommand.ProtoReflect.Descriptor instead.
+func (*SaveUserCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SaveUserCommand) GetUser() *UserRecord {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+type AddRatingCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LaptopId string  `protobuf:"bytes,1,opt,name=laptop_id,json=laptopId,proto3" json:"laptop_id,omitempty"`
+	Score    float64 `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+}
+
+func (x *AddRatingCommand) Reset() {
+	*x = AddRatingCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddRatingCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRatingCommand) ProtoMessage() {}
+
+func (x *AddRatingCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRatingCommand.ProtoReflect.Descriptor instead.
+func (*AddRatingCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AddRatingCommand) GetLaptopId() string {
+	if x != nil {
+		return x.LaptopId
+	}
+	return ""
+}
+
+func (x *AddRatingCommand) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type SetRatingCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rating *RatingRecord `protobuf:"bytes,1,opt,name=rating,proto3" json:"rating,omitempty"`
+}
+
+func (x *SetRatingCommand) Reset() {
+	*x = SetRatingCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetRatingCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetRatingCommand) ProtoMessage() {}
+
+func (x *SetRatingCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetRatingCommand.ProtoReflect.Descriptor instead.
+func (*SetRatingCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SetRatingCommand) GetRating() *RatingRecord {
+	if x != nil {
+		return x.Rating
+	}
+	return nil
+}
+
+type DeleteRatingCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LaptopId string `protobuf:"bytes,1,opt,name=laptop_id,json=laptopId,proto3" json:"laptop_id,omitempty"`
+}
+
+func (x *DeleteRatingCommand) Reset() {
+	*x = DeleteRatingCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteRatingCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRatingCommand) ProtoMessage() {}
+
+func (x *DeleteRatingCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRatingCommand.ProtoReflect.Descriptor instead.
+func (*DeleteRatingCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteRatingCommand) GetLaptopId() string {
+	if x != nil {
+		return x.LaptopId
+	}
+	return ""
+}
+
+type SaveLaptopRevisionCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+}
+
+func (x *SaveLaptopRevisionCommand) Reset() {
+	*x = SaveLaptopRevisionCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SaveLaptopRevisionCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveLaptopRevisionCommand) ProtoMessage() {}
+
+func (x *SaveLaptopRevisionCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveLaptopRevisionCommand.ProtoReflect.Descriptor instead.
+func (*SaveLaptopRevisionCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SaveLaptopRevisionCommand) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+type DeleteLaptopRevisionsCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LaptopId string `protobuf:"bytes,1,opt,name=laptop_id,json=laptopId,proto3" json:"laptop_id,omitempty"`
+}
+
+func (x *DeleteLaptopRevisionsCommand) Reset() {
+	*x = DeleteLaptopRevisionsCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteLaptopRevisionsCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteLaptopRevisionsCommand) ProtoMessage() {}
+
+func (x *DeleteLaptopRevisionsCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteLaptopRevisionsCommand.ProtoReflect.Descriptor instead.
+func (*DeleteLaptopRevisionsCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DeleteLaptopRevisionsCommand) GetLaptopId() string {
+	if x != nil {
+		return x.LaptopId
+	}
+	return ""
+}
+
+// BeginIdempotentRequestCommand reserves the idempotency key of a request
+type BeginIdempotentRequestCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key         string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Fingerprint []byte `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// now and expires_at are set by the node proposing the command, so every node
+	// expires the keys at the same time
+	Now       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=now,proto3" json:"now,omitempty"`
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+}
+
+func (x *BeginIdempotentRequestCommand) Reset() {
+	*x = BeginIdempotentRequestCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BeginIdempotentRequestCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BeginIdempotentRequestCommand) ProtoMessage() {}
+
+func (x *BeginIdempotentRequestCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BeginIdempotentRequestCommand.ProtoReflect.Descriptor instead.
+func (*BeginIdempotentRequestCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BeginIdempotentRequestCommand) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *BeginIdempotentRequestCommand) GetFingerprint() []byte {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return nil
+}
+
+func (x *BeginIdempotentRequestCommand) GetNow() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Now
+	}
+	return nil
+}
+
+func (x *BeginIdempotentRequestCommand) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+// FinishIdempotentRequestCommand saves the response of the request that reserved the key
+type FinishIdempotentRequestCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key       string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Response  []byte                 `protobuf:"bytes,2,opt,name=response,proto3" json:"response,omitempty"`
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+}
+
+func (x *FinishIdempotentRequestCommand) Reset() {
+	*x = FinishIdempotentRequestCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FinishIdempotentRequestCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FinishIdempotentRequestCommand) ProtoMessage() {}
+
+func (x *FinishIdempotentRequestCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FinishIdempotentRequestCommand.ProtoReflect.Descriptor instead.
+func (*FinishIdempotentRequestCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FinishIdempotentRequestCommand) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *FinishIdempotentRequestCommand) GetResponse() []byte {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *FinishIdempotentRequestCommand) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+// CancelIdempotentRequestCommand releases the key of a failed request
+type CancelIdempotentRequestCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (x *CancelIdempotentRequestCommand) Reset() {
+	*x = CancelIdempotentRequestCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CancelIdempotentRequestCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelIdempotentRequestCommand) ProtoMessage() {}
+
+func (x *CancelIdempotentRequestCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelIdempotentRequestCommand.ProtoReflect.Descriptor instead.
+func (*CancelIdempotentRequestCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CancelIdempotentRequestCommand) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+// ClusterCommand is a write replicated to every node of the cluster through the raft log
+type ClusterCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Command:
+	//
+	//	*ClusterCommand_SaveLaptop
+	//	*ClusterCommand_UpdateLaptop
+	//	*ClusterCommand_DeleteLaptop
+	//	*ClusterCommand_SaveUser
+	//	*ClusterCommand_AddRating
+	//	*ClusterCommand_SetRating
+	//	*ClusterCommand_UndeleteLaptop
+	//	*ClusterCommand_PurgeLaptop
+	//	*ClusterCommand_DeleteRating
+	//	*ClusterCommand_SaveLaptopRevision
+	//	*ClusterCommand_DeleteLaptopRevisions
+	//	*ClusterCommand_SaveLaptops
+	//	*ClusterCommand_BeginIdempotentRequest
+	//	*ClusterCommand_FinishIdempotentRequest
+	//	*ClusterCommand_CancelIdempotentRequest
+	Command isClusterCommand_Command `protobuf_oneof:"command"`
+	// id is set once by the node proposing the command, so a command retried after
+	// an unknown outcome is applied only once
+	Id string `protobuf:"bytes,13,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *ClusterCommand) Reset() {
+	*x = ClusterCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterCommand) ProtoMessage() {}
+
+func (x *ClusterCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterCommand.ProtoReflect.Descriptor instead.
+func (*ClusterCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{15}
+}
+
+func (m *ClusterCommand) GetCommand() isClusterCommand_Command {
+	if m != nil {
+		return m.Command
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetSaveLaptop() *SaveLaptopCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_SaveLaptop); ok {
+		return x.SaveLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetUpdateLaptop() *UpdateLaptopCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_UpdateLaptop); ok {
+		return x.UpdateLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetDeleteLaptop() *DeleteLaptopCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_DeleteLaptop); ok {
+		return x.DeleteLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetSaveUser() *SaveUserCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_SaveUser); ok {
+		return x.SaveUser
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetAddRating() *AddRatingCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_AddRating); ok {
+		return x.AddRating
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetSetRating() *SetRatingCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_SetRating); ok {
+		return x.SetRating
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetUndeleteLaptop() *UndeleteLaptopCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_UndeleteLaptop); ok {
+		return x.UndeleteLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetPurgeLaptop() *PurgeLaptopCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_PurgeLaptop); ok {
+		return x.PurgeLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetDeleteRating() *DeleteRatingCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_DeleteRating); ok {
+		return x.DeleteRating
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetSaveLaptopRevision() *SaveLaptopRevisionCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_SaveLaptopRevision); ok {
+		return x.SaveLaptopRevision
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetDeleteLaptopRevisions() *DeleteLaptopRevisionsCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_DeleteLaptopRevisions); ok {
+		return x.DeleteLaptopRevisions
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetSaveLaptops() *SaveLaptopsCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_SaveLaptops); ok {
+		return x.SaveLaptops
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetBeginIdempotentRequest() *BeginIdempotentRequestCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_BeginIdempotentRequest); ok {
+		return x.BeginIdempotentRequest
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetFinishIdempotentRequest() *FinishIdempotentRequestCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_FinishIdempotentRequest); ok {
+		return x.FinishIdempotentRequest
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetCancelIdempotentRequest() *CancelIdempotentRequestCommand {
+	if x, ok := x.GetCommand().(*ClusterCommand_CancelIdempotentRequest); ok {
+		return x.CancelIdempotentRequest
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type isClusterCommand_Command interface {
+	isClusterCommand_Command()
+}
+
+type ClusterCommand_SaveLaptop struct {
+	SaveLaptop *SaveLaptopCommand `protobuf:"bytes,1,opt,name=save_laptop,json=saveLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_UpdateLaptop struct {
+	UpdateLaptop *UpdateLaptopCommand `protobuf:"bytes,2,opt,name=update_laptop,json=updateLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_DeleteLaptop struct {
+	DeleteLaptop *DeleteLaptopCommand `protobuf:"bytes,3,opt,name=delete_laptop,json=deleteLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_SaveUser struct {
+	SaveUser *SaveUserCommand `protobuf:"bytes,4,opt,name=save_user,json=saveUser,proto3,oneof"`
+}
+
+type ClusterCommand_AddRating struct {
+	AddRating *AddRatingCommand `protobuf:"bytes,5,opt,name=add_rating,json=addRating,proto3,oneof"`
+}
+
+type ClusterCommand_SetRating struct {
+	SetRating *SetRatingCommand `protobuf:"bytes,6,opt,name=set_rating,json=setRating,proto3,oneof"`
+}
+
+type ClusterCommand_UndeleteLaptop struct {
+	UndeleteLaptop *UndeleteLaptopCommand `protobuf:"bytes,7,opt,name=undelete_laptop,json=undeleteLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_PurgeLaptop struct {
+	PurgeLaptop *PurgeLaptopCommand `protobuf:"bytes,8,opt,name=purge_laptop,json=purgeLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_DeleteRating struct {
+	DeleteRating *DeleteRatingCommand `protobuf:"bytes,9,opt,name=delete_rating,json=deleteRating,proto3,oneof"`
+}
+
+type ClusterCommand_SaveLaptopRevision struct {
+	SaveLaptopRevision *SaveLaptopRevisionCommand `protobuf:"bytes,10,opt,name=save_laptop_revision,json=saveLaptopRevision,proto3,oneof"`
+}
+
+type ClusterCommand_DeleteLaptopRevisions struct {
+	DeleteLaptopRevisions *DeleteLaptopRevisionsCommand `protobuf:"bytes,11,opt,name=delete_laptop_revisions,json=deleteLaptopRevisions,proto3,oneof"`
+}
+
+type ClusterCommand_SaveLaptops struct {
+	SaveLaptops *SaveLaptopsCommand `protobuf:"bytes,12,opt,name=save_laptops,json=saveLaptops,proto3,oneof"`
+}
+
+type ClusterCommand_BeginIdempotentRequest struct {
+	BeginIdempotentRequest *BeginIdempotentRequestCommand `protobuf:"bytes,14,opt,name=begin_idempotent_request,json=beginIdempotentRequest,proto3,oneof"`
+}
+
+type ClusterCommand_FinishIdempotentRequest struct {
+	FinishIdempotentRequest *FinishIdempotentRequestCommand `protobuf:"bytes,15,opt,name=finish_idempotent_request,json=finishIdempotentRequest,proto3,oneof"`
+}
+
+type ClusterCommand_CancelIdempotentRequest struct {
+	CancelIdempotentRequest *CancelIdempotentRequestCommand `protobuf:"bytes,16,opt,name=cancel_idempotent_request,json=cancelIdempotentRequest,proto3,oneof"`
+}
+
+func (*ClusterCommand_SaveLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_UpdateLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_DeleteLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_SaveUser) isClusterCommand_Command() {}
+
+func (*ClusterCommand_AddRating) isClusterCommand_Command() {}
+
+func (*ClusterCommand_SetRating) isClusterCommand_Command() {}
+
+func (*ClusterCommand_UndeleteLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_PurgeLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_DeleteRating) isClusterCommand_Command() {}
+
+func (*ClusterCommand_SaveLaptopRevision) isClusterCommand_Command() {}
+
+func (*ClusterCommand_DeleteLaptopRevisions) isClusterCommand_Command() {}
+
+func (*ClusterCommand_SaveLaptops) isClusterCommand_Command() {}
+
+func (*ClusterCommand_BeginIdempotentRequest) isClusterCommand_Command() {}
+
+func (*ClusterCommand_FinishIdempotentRequest) isClusterCommand_Command() {}
+
+func (*ClusterCommand_CancelIdempotentRequest) isClusterCommand_Command() {}
+
+type ClusterResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// index of the command in the raft log
+	Index uint64 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// revision of the saved, updated or undeleted laptop
+	Revision uint64 `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	// rating after the added score
+	Rating *RatingRecord `protobuf:"bytes,3,opt,name=rating,proto3" json:"rating,omitempty"`
+	// idempotency is the outcome of a begin idempotent request command
+	Idempotency IdempotencyOutcome `protobuf:"varint,4,opt,name=idempotency,proto3,enum=pb.IdempotencyOutcome" json:"idempotency,omitempty"`
+	// idempotent_response is the saved response when the outcome is IDEMPOTENCY_REPLAYED
+	IdempotentResponse []byte `protobuf:"bytes,5,opt,name=idempotent_response,json=idempotentResponse,proto3" json:"idempotent_response,omitempty"`
+}
+
+func (x *ClusterResult) Reset() {
+	*x = ClusterResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterResult) ProtoMessage() {}
+
+func (x *ClusterResult) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterResult.ProtoReflect.Descriptor instead.
+func (*ClusterResult) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ClusterResult) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ClusterResult) GetRevision() uint64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *ClusterResult) GetRating() *RatingRecord {
+	if x != nil {
+		return x.Rating
+	}
+	return nil
+}
+
+func (x *ClusterResult) GetIdempotency() IdempotencyOutcome {
+	if x != nil {
+		return x.Idempotency
+	}
+	return IdempotencyOutcome_IDEMPOTENCY_RESERVED
+}
+
+func (x *ClusterResult) GetIdempotentResponse() []byte {
+	if x != nil {
+		return x.IdempotentResponse
+	}
+	return nil
+}
+
+// ClusterSnapshot is the state of the stores after the command at index was applied
+type ClusterSnapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index uint64 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// laptops include the ones in the trash, which have deleted_at set
+	Laptops []*Laptop       `protobuf:"bytes,2,rep,name=laptops,proto3" json:"laptops,omitempty"`
+	Users   []*UserRecord   `protobuf:"bytes,3,rep,name=users,proto3" json:"users,omitempty"`
+	Ratings []*RatingRecord `protobuf:"bytes,4,rep,name=ratings,proto3" json:"ratings,omitempty"`
+	// laptop_revisions are the versions kept by the laptop revision store
+	LaptopRevisions []*Laptop `protobuf:"bytes,5,rep,name=laptop_revisions,json=laptopRevisions,proto3" json:"laptop_revisions,omitempty"`
+	// applied_commands are the last commands applied, to recognize their retries
+	AppliedCommands []*AppliedCommand `protobuf:"bytes,6,rep,name=applied_commands,json=appliedCommands,proto3" json:"applied_commands,omitempty"`
+	// laptop_event_sequence is the one of the last laptop event,
+	// so the events are numbered the same on every node and after a restart
+	LaptopEventSequence uint64 `protobuf:"varint,7,opt,name=laptop_event_sequence,json=laptopEventSequence,proto3" json:"laptop_event_sequence,omitempty"`
+	// idempotency_records are the idempotency keys not expired yet
+	IdempotencyRecords []*IdempotencyRecord `protobuf:"bytes,8,rep,name=idempotency_records,json=idempotencyRecords,proto3" json:"idempotency_records,omitempty"`
+}
+
+func (x *ClusterSnapshot) Reset() {
+	*x = ClusterSnapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterSnapshot) ProtoMessage() {}
+
+func (x *ClusterSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterSnapshot.ProtoReflect.Descriptor instead.
+func (*ClusterSnapshot) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ClusterSnapshot) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ClusterSnapshot) GetLaptops() []*Laptop {
+	if x != nil {
+		return x.Laptops
+	}
+	return nil
+}
+
+func (x *ClusterSnapshot) GetUsers() []*UserRecord {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *ClusterSnapshot) GetRatings() []*RatingRecord {
+	if x != nil {
+		return x.Ratings
+	}
+	return nil
+}
+
+func (x *ClusterSnapshot) GetLaptopRevisions() []*Laptop {
+	if x != nil {
+		return x.LaptopRevisions
+	}
+	return nil
+}
+
+func (x *ClusterSnapshot) GetAppliedCommands() []*AppliedCommand {
+	if x != nil {
+		return x.AppliedCommands
+	}
+	return nil
+}
+
+func (x *ClusterSnapshot) GetLaptopEventSequence() uint64 {
+	if x != nil {
+		return x.LaptopEventSequence
+	}
+	return 0
+}
+
+func (x *ClusterSnapshot) GetIdempotencyRecords() []*IdempotencyRecord {
+	if x != nil {
+		return x.IdempotencyRecords
+	}
+	return nil
+}
+
+type IdempotencyRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key         string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Fingerprint []byte `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// finished is set once the response is saved, it may be empty
+	Finished  bool                   `protobuf:"varint,3,opt,name=finished,proto3" json:"finished,omitempty"`
+	Response  []byte                 `protobuf:"bytes,4,opt,name=response,proto3" json:"response,omitempty"`
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+}
+
+func (x *IdempotencyRecord) Reset() {
+	*x = IdempotencyRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IdempotencyRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdempotencyRecord) ProtoMessage() {}
+
+func (x *IdempotencyRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdempotencyRecord.ProtoReflect.Descriptor instead.
+func (*IdempotencyRecord) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *IdempotencyRecord) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *IdempotencyRecord) GetFingerprint() []byte {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return nil
+}
+
+func (x *IdempotencyRecord) GetFinished() bool {
+	if x != nil {
+		return x.Finished
+	}
+	return false
+}
+
+func (x *IdempotencyRecord) GetResponse() []byte {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *IdempotencyRecord) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+// AppliedCommand is the result of a command applied with an id
+type AppliedCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     string         `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Result *ClusterResult `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *AppliedCommand) Reset() {
+	*x = AppliedCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AppliedCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppliedCommand) ProtoMessage() {}
+
+func (x *AppliedCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppliedCommand.ProtoReflect.Descriptor instead.
+func (*AppliedCommand) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *AppliedCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AppliedCommand) GetResult() *ClusterResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type ApplyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Command *ClusterCommand `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+}
+
+func (x *ApplyRequest) Reset() {
+	*x = ApplyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ApplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRequest) ProtoMessage() {}
+
+func (x *ApplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRequest.ProtoReflect.Descriptor instead.
+func (*ApplyRequest) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ApplyRequest) GetCommand() *ClusterCommand {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+type ApplyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result *ClusterResult `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *ApplyResponse) Reset() {
+	*x = ApplyResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cluster_service_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ApplyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyResponse) ProtoMessage() {}
+
+func (x *ApplyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cluster_service_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyResponse.ProtoReflect.Descriptor instead.
+func (*ApplyResponse) Descriptor() ([]byte, []int) {
+	return file_cluster_service_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ApplyResponse) GetResult() *ClusterResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+var File_cluster_service_proto protoreflect.FileDescriptor
+
+var file_cluster_service_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70, 0x62, 0x1a, 0x14, 0x6c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x1a, 0x14, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x37, 0x0a, 0x11, 0x53, 0x61, 0x76, 0x65,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x22, 0x0a,
+	0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e,
+	0x70, 0x62, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f,
+	0x70, 0x22, 0x3a, 0x0a, 0x12, 0x53, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x73,
+	0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x24, 0x0a, 0x07, 0x6c, 0x61, 0x70, 0x74, 0x6f,
+	0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x52, 0x07, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x22, 0x66, 0x0a,
+	0x13, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x12, 0x22, 0x0a, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x52, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x2b, 0x0a, 0x11, 0x65, 0x78, 0x70, 0x65,
+	0x63, 0x74, 0x65, 0x64, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x10, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x52, 0x65, 0x76,
+	0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x8d, 0x01, 0x0a, 0x13, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x2b, 0x0a,
+	0x11, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74,
+	0x65, 0x64, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x39, 0x0a, 0x0a, 0x64, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x64, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x64, 0x41, 0x74, 0x22, 0x54, 0x0a, 0x15, 0x55, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x0e,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x2b,
+	0x0a, 0x11, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x65, 0x78, 0x70, 0x65, 0x63,
+	0x74, 0x65, 0x64, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x24, 0x0a, 0x12, 0x50,
+	0x75, 0x72, 0x67, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x22, 0x35, 0x0a, 0x0f, 0x53, 0x61, 0x76, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x12, 0x22, 0x0a, 0x04, 0x75, 0x73, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x62, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x52, 0x04, 0x75, 0x73, 0x65, 0x72, 0x22, 0x45, 0x0a, 0x10, 0x41, 0x64, 0x64, 0x52,
+	0x61, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x1b, 0x0a, 0x09,
+	0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f,
+	0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22,
+	0x3c, 0x0a, 0x10, 0x53, 0x65, 0x74, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x12, 0x28, 0x0a, 0x06, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x52,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x52, 0x06, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x22, 0x32, 0x0a,
+	0x13, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49,
+	0x64, 0x22, 0x3f, 0x0a, 0x19, 0x53, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52,
+	0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x22,
+	0x0a, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a,
+	0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x06, 0x6c, 0x61, 0x70, 0x74,
+	0x6f, 0x70, 0x22, 0x3b, 0x0a, 0x1c, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74,
+	0x6f, 0x70, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49, 0x64, 0x22,
+	0xbc, 0x01, 0x0a, 0x1d, 0x42, 0x65, 0x67, 0x69, 0x6e, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74,
+	0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69,
+	0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72,
+	0x70, 0x72, 0x69, 0x6e, 0x74, 0x12, 0x2c, 0x0a, 0x03, 0x6e, 0x6f, 0x77, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x03,
+	0x6e, 0x6f, 0x77, 0x12, 0x39, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x5f, 0x61,
+	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x52, 0x09, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x22, 0x89,
+	0x01, 0x0a, 0x1e, 0x46, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74,
+	0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x39, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x5f, 0x61, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x09, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x22, 0x32, 0x0a, 0x1e, 0x43, 0x61,
+	0x6e, 0x63, 0x65, 0x6c, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0xd9,
+	0x08, 0x0a, 0x0e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x12, 0x38, 0x0a, 0x0b, 0x73, 0x61, 0x76, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x61, 0x76, 0x65,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52,
+	0x0a, 0x73, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x3e, 0x0a, 0x0d, 0x75,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x70, 0x62, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x0c, 0x75,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x3e, 0x0a, 0x0d, 0x64,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x0c, 0x64,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x32, 0x0a, 0x09, 0x73,
+	0x61, 0x76, 0x65, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
+	0x2e, 0x70, 0x62, 0x2e, 0x53, 0x61, 0x76, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x08, 0x73, 0x61, 0x76, 0x65, 0x55, 0x73, 0x65, 0x72, 0x12,
+	0x35, 0x0a, 0x0a, 0x61, 0x64, 0x64, 0x5f, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x64, 0x64, 0x52, 0x61, 0x74, 0x69,
+	0x6e, 0x67, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x09, 0x61, 0x64, 0x64,
+	0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x35, 0x0a, 0x0a, 0x73, 0x65, 0x74, 0x5f, 0x72, 0x61,
+	0x74, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x62, 0x2e,
+	0x53, 0x65, 0x74, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
+	0x48, 0x00, 0x52, 0x09, 0x73, 0x65, 0x74, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x44, 0x0a,
+	0x0f, 0x75, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x62, 0x2e, 0x55, 0x6e, 0x64, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x48, 0x00, 0x52, 0x0e, 0x75, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x12, 0x3b, 0x0a, 0x0c, 0x70, 0x75, 0x72, 0x67, 0x65, 0x5f, 0x6c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x62, 0x2e, 0x50,
+	0x75, 0x72, 0x67, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x48, 0x00, 0x52, 0x0b, 0x70, 0x75, 0x72, 0x67, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x12, 0x3e, 0x0a, 0x0d, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x69, 0x6e,
+	0x67, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
+	0x48, 0x00, 0x52, 0x0c, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67,
+	0x12, 0x51, 0x0a, 0x14, 0x73, 0x61, 0x76, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d,
+	0x2e, 0x70, 0x62, 0x2e, 0x53, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52,
+	0x12, 0x73, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x65, 0x76, 0x69, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x5a, 0x0a, 0x17, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x6c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0b,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x43,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x15, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12,
+	0x3b, 0x0a, 0x0c, 0x73, 0x61, 0x76, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x61, 0x76, 0x65, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52,
+	0x0b, 0x73, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x12, 0x5d, 0x0a, 0x18,
+	0x62, 0x65, 0x67, 0x69, 0x6e, 0x5f, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74,
+	0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21,
+	0x2e, 0x70, 0x62, 0x2e, 0x42, 0x65, 0x67, 0x69, 0x6e, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74,
+	0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x48, 0x00, 0x52, 0x16, 0x62, 0x65, 0x67, 0x69, 0x6e, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f,
+	0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x60, 0x0a, 0x19, 0x66,
+	0x69, 0x6e, 0x69, 0x73, 0x68, 0x5f, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74,
+	0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22,
+	0x2e, 0x70, 0x62, 0x2e, 0x46, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f,
+	0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x48, 0x00, 0x52, 0x17, 0x66, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x49, 0x64, 0x65, 0x6d,
+	0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x60, 0x0a,
+	0x19, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x5f, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65,
+	0x6e, 0x74, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x22, 0x2e, 0x70, 0x62, 0x2e, 0x43, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x49, 0x64, 0x65, 0x6d,
+	0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x17, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x49, 0x64,
+	0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x42,
+	0x09, 0x0a, 0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22, 0xd6, 0x01, 0x0a, 0x0d, 0x43,
+	0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05,
+	0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x28,
+	0x0a, 0x06, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10,
+	0x2e, 0x70, 0x62, 0x2e, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
+	0x52, 0x06, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x38, 0x0a, 0x0b, 0x69, 0x64, 0x65, 0x6d,
+	0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e,
+	0x70, 0x62, 0x2e, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x4f, 0x75,
+	0x74, 0x63, 0x6f, 0x6d, 0x65, 0x52, 0x0b, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e,
+	0x63, 0x79, 0x12, 0x2f, 0x0a, 0x13, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74,
+	0x5f, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x12, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x91, 0x03, 0x0a, 0x0f, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x53,
+	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x24, 0x0a,
+	0x07, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a,
+	0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x07, 0x6c, 0x61, 0x70, 0x74,
+	0x6f, 0x70, 0x73, 0x12, 0x24, 0x0a, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x62, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x52, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x12, 0x2a, 0x0a, 0x07, 0x72, 0x61, 0x74,
+	0x69, 0x6e, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x70, 0x62, 0x2e,
+	0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x52, 0x07, 0x72, 0x61,
+	0x74, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x35, 0x0a, 0x10, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x0f, 0x6c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x3d, 0x0a, 0x10,
+	0x61, 0x70, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73,
+	0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x70, 0x70, 0x6c,
+	0x69, 0x65, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x0f, 0x61, 0x70, 0x70, 0x6c,
+	0x69, 0x65, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x32, 0x0a, 0x15, 0x6c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f, 0x73, 0x65, 0x71, 0x75,
+	0x65, 0x6e, 0x63, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x13, 0x6c, 0x61, 0x70, 0x74,
+	0x6f, 0x70, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12,
+	0x46, 0x0a, 0x13, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x72,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70,
+	0x62, 0x2e, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x65, 0x63,
+	0x6f, 0x72, 0x64, 0x52, 0x12, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79,
+	0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x22, 0xba, 0x01, 0x0a, 0x11, 0x49, 0x64, 0x65, 0x6d,
+	0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x20, 0x0a, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e,
+	0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x08, 0x66, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x0a, 0x65, 0x78, 0x70,
+	0x69, 0x72, 0x65, 0x73, 0x5f, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x65, 0x78, 0x70, 0x69, 0x72,
+	0x65, 0x73, 0x41, 0x74, 0x22, 0x4b, 0x0a, 0x0e, 0x41, 0x70, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x43,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x29, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x70, 0x62, 0x2e, 0x43, 0x6c, 0x75, 0x73,
+	0x74, 0x65, 0x72, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x22, 0x3c, 0x0a, 0x0c, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x2c, 0x0a, 0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x62, 0x2e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x43,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22,
+	0x3a, 0x0a, 0x0d, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x29, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x11, 0x2e, 0x70, 0x62, 0x2e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2a, 0x80, 0x01, 0x0a, 0x12,
+	0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x4f, 0x75, 0x74, 0x63, 0x6f,
+	0x6d, 0x65, 0x12, 0x18, 0x0a, 0x14, 0x49, 0x44, 0x45, 0x4d, 0x50, 0x4f, 0x54, 0x45, 0x4e, 0x43,
+	0x59, 0x5f, 0x52, 0x45, 0x53, 0x45, 0x52, 0x56, 0x45, 0x44, 0x10, 0x00, 0x12, 0x18, 0x0a, 0x14,
+	0x49, 0x44, 0x45, 0x4d, 0x50, 0x4f, 0x54, 0x45, 0x4e, 0x43, 0x59, 0x5f, 0x52, 0x45, 0x50, 0x4c,
+	0x41, 0x59, 0x45, 0x44, 0x10, 0x01, 0x12, 0x1a, 0x0a, 0x16, 0x49, 0x44, 0x45, 0x4d, 0x50, 0x4f,
+	0x54, 0x45, 0x4e, 0x43, 0x59, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x52, 0x45, 0x55, 0x53, 0x45, 0x44,
+	0x10, 0x02, 0x12, 0x1a, 0x0a, 0x16, 0x49, 0x44, 0x45, 0x4d, 0x50, 0x4f, 0x54, 0x45, 0x4e, 0x43,
+	0x59, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x49, 0x4e, 0x5f, 0x55, 0x53, 0x45, 0x10, 0x03, 0x32, 0x40,
+	0x0a, 0x0e, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x12, 0x2e, 0x0a, 0x05, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x12, 0x10, 0x2e, 0x70, 0x62, 0x2e, 0x41,
+	0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x70, 0x62,
+	0x2e, 0x41, 0x70, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x42, 0x0b, 0x5a, 0x09, 0x67, 0x6f, 0x62, 0x6f, 0x6f, 0x6b, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_cluster_service_proto_rawDescOnce sync.Once
+	file_cluster_service_proto_rawDescData = file_cluster_service_proto_rawDesc
+)
+
+func file_cluster_service_proto_rawDescGZIP() []byte {
+	file_cluster_service_proto_rawDescOnce.Do(func() {
+		file_cluster_service_proto_rawDescData = protoimpl.X.CompressGZIP(file_cluster_service_proto_rawDescData)
+	})
+	return file_cluster_service_proto_rawDescData
+}
+
+var file_cluster_service_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_cluster_service_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_cluster_service_proto_goTypes = []interface{}{
+	(IdempotencyOutcome)(0),                // 0: pb.IdempotencyOutcome
+	(*SaveLaptopCommand)(nil),              // 1: pb.SaveLaptopCommand
+	(*SaveLaptopsCommand)(nil),             // 2: pb.SaveLaptopsCommand
+	(*UpdateLaptopCommand)(nil),            // 3: pb.UpdateLaptopCommand
+	(*DeleteLaptopCommand)(nil),            // 4: pb.DeleteLaptopCommand
+	(*UndeleteLaptopCommand)(nil),          // 5: pb.UndeleteLaptopCommand
+	(*PurgeLaptopCommand)(nil),             // 6: pb.PurgeLaptopCommand
+	(*SaveUserCommand)(nil),                // 7: pb.SaveUserCommand
+	(*AddRatingCommand)(nil),               // 8: pb.AddRatingCommand
+	(*SetRatingCommand)(nil),               // 9: pb.SetRatingCommand
+	(*DeleteRatingCommand)(nil),            // 10: pb.DeleteRatingCommand
+	(*SaveLaptopRevisionCommand)(nil),      // 11: pb.SaveLaptopRevisionCommand
+	(*DeleteLaptopRevisionsCommand)(nil),   // 12: pb.DeleteLaptopRevisionsCommand
+	(*BeginIdempotentRequestCommand)(nil),  // 13: pb.BeginIdempotentRequestCommand
+	(*FinishIdempotentRequestCommand)(nil), // 14: pb.FinishIdempotentRequestCommand
+	(*CancelIdempotentRequestCommand)(nil), // 15: pb.CancelIdempotentRequestCommand
+	(*ClusterCommand)(nil),                 // 16: pb.ClusterCommand
+	(*ClusterResult)(nil),                  // 17: pb.ClusterResult
+	(*ClusterSnapshot)(nil),                // 18: pb.ClusterSnapshot
+	(*IdempotencyRecord)(nil),              // 19: pb.IdempotencyRecord
+	(*AppliedCommand)(nil),                 // 20: pb.AppliedCommand
+	(*ApplyRequest)(nil),                   // 21: pb.ApplyRequest
+	(*ApplyResponse)(nil),                  // 22: pb.ApplyResponse
+	(*Laptop)(nil),                         // 23: pb.Laptop
+	(*timestamppb.Timestamp)(nil),          // 24: google.protobuf.Timestamp
+	(*UserRecord)(nil),                     // 25: pb.UserRecord
+	(*RatingRecord)(nil),                   // 26: pb.RatingRecord
+}
+var file_cluster_service_proto_depIdxs = []int32{
+	23, // 0: pb.SaveLaptopCommand.laptop:type_name -> pb.Laptop
+	23, // 1: pb.SaveLaptopsCommand.laptops:type_name -> pb.Laptop
+	23, // 2: pb.UpdateLaptopCommand.laptop:type_name -> pb.Laptop
+	24, // 3: pb.DeleteLaptopCommand.deleted_at:type_name -> google.protobuf.Timestamp
+	25, // 4: pb.SaveUserCommand.user:type_name -> pb.UserRecord
+	26, // 5: pb.SetRatingCommand.rating:type_name -> pb.RatingRecord
+	23, // 6: pb.SaveLaptopRevisionCommand.laptop:type_name -> pb.Laptop
+	24, // 7: pb.BeginIdempotentRequestCommand.now:type_name -> google.protobuf.Timestamp
+	24, // 8: pb.BeginIdempotentRequestCommand.expires_at:type_name -> google.protobuf.Timestamp
+	24, // 9: pb.FinishIdempotentRequestCommand.expires_at:type_name -> google.protobuf.Timestamp
+	1,  // 10: pb.ClusterCommand.save_laptop:type_name -> pb.SaveLaptopCommand
+	3,  // 11: pb.ClusterCommand.update_laptop:type_name -> pb.UpdateLaptopCommand
+	4,  // 12: pb.ClusterCommand.delete_laptop:type_name -> pb.DeleteLaptopCommand
+	7,  // 13: pb.ClusterCommand.save_user:type_name -> pb.SaveUserCommand
+	8,  // 14: pb.ClusterCommand.add_rating:type_name -> pb.AddRatingCommand
+	9,  // 15: pb.ClusterCommand.set_rating:type_name -> pb.SetRatingCommand
+	5,  // 16: pb.ClusterCommand.undelete_laptop:type_name -> pb.UndeleteLaptopCommand
+	6,  // 17: pb.ClusterCommand.purge_laptop:type_name -> pb.PurgeLaptopCommand
+	10, // 18: pb.ClusterCommand.delete_rating:type_name -> pb.DeleteRatingCommand
+	11, // 19: pb.ClusterCommand.save_laptop_revision:type_name -> pb.SaveLaptopRevisionCommand
+	12, // 20: pb.ClusterCommand.delete_laptop_revisions:type_name -> pb.DeleteLaptopRevisionsCommand
+	2,  // 21: pb.ClusterCommand.save_laptops:type_name -> pb.SaveLaptopsCommand
+	13, // 22: pb.ClusterCommand.begin_idempotent_request:type_name -> pb.BeginIdempotentRequestCommand
+	14, // 23: pb.ClusterCommand.finish_idempotent_request:type_name -> pb.FinishIdempotentRequestCommand
+	15, // 24: pb.ClusterCommand.cancel_idempotent_request:type_name -> pb.CancelIdempotentRequestCommand
+	26, // 25: pb.ClusterResult.rating:type_name -> pb.RatingRecord
+	0,  // 26: pb.ClusterResult.idempotency:type_name -> pb.IdempotencyOutcome
+	23, // 27: pb.ClusterSnapshot.laptops:type_name -> pb.Laptop
+	25, // 28: pb.ClusterSnapshot.users:type_name -> pb.UserRecord
+	26, // 29: pb.ClusterSnapshot.ratings:type_name -> pb.RatingRecord
+	23, // 30: pb.ClusterSnapshot.laptop_revisions:type_name -> pb.Laptop
+	20, // 31: pb.ClusterSnapshot.applied_commands:type_name -> pb.AppliedCommand
+	19, // 32: pb.ClusterSnapshot.idempotency_records:type_name -> pb.IdempotencyRecord
+	24, // 33: pb.IdempotencyRecord.expires_at:type_name -> google.protobuf.Timestamp
+	17, // 34: pb.AppliedCommand.result:type_name -> pb.ClusterResult
+	16, // 35: pb.ApplyRequest.command:type_name -> pb.ClusterCommand
+	17, // 36: pb.ApplyResponse.result:type_name -> pb.ClusterResult
+	21, // 37: pb.ClusterService.Apply:input_type -> pb.ApplyRequest
+	22, // 38: pb.ClusterService.Apply:output_type -> pb.ApplyResponse
+	38, // [38:39] is the sub-list for method output_type
+	37, // [37:38] is the sub-list for method input_type
+	37, // [37:37] is the sub-list for extension type_name
+	37, // [37:37] is the sub-list for extension extendee
+	0,  // [0:37] is the sub-list for field type_name
+}
+
+func init() { file_cluster_service_proto_init() }
+func file_cluster_service_proto_init() {
+	if File_cluster_service_proto != nil {
+		return
+	}
+	file_laptop_message_proto_init()
+	file_record_message_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_cluster_service_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SaveLaptopCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SaveLaptopsCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateLaptopCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteLaptopCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UndeleteLaptopCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PurgeLaptopCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SaveUserCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddRatingCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetRatingCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteRatingCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SaveLaptopRevisionCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteLaptopRevisionsCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BeginIdempotentRequestCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FinishIdempotentRequestCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CancelIdempotentRequestCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterSnapshot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IdempotencyRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AppliedCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ApplyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cluster_service_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ApplyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_cluster_service_proto_msgTypes[15].OneofWrappers = []interface{}{
+		(*ClusterCommand_SaveLaptop)(nil),
+		(*ClusterCommand_UpdateLaptop)(nil),
+		(*ClusterCommand_DeleteLaptop)(nil),
+		(*ClusterCommand_SaveUser)(nil),
+		(*ClusterCommand_AddRating)(nil),
+		(*ClusterCommand_SetRating)(nil),
+		(*ClusterCommand_UndeleteLaptop)(nil),
+		(*ClusterCommand_PurgeLaptop)(nil),
+		(*ClusterCommand_DeleteRating)(nil),
+		(*ClusterCommand_SaveLaptopRevision)(nil),
+		(*ClusterCommand_DeleteLaptopRevisions)(nil),
+		(*ClusterCommand_SaveLaptops)(nil),
+		(*ClusterCommand_BeginIdempotentRequest)(nil),
+		(*ClusterCommand_FinishIdempotentRequest)(nil),
+		(*ClusterCommand_CancelIdempotentRequest)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_cluster_service_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cluster_service_proto_goTypes,
+		DependencyIndexes: file_cluster_service_proto_depIdxs,
+		EnumInfos:         file_cluster_service_proto_enumTypes,
+		MessageInfos:      file_cluster_service_proto_msgTypes,
+	}.Build()
+	File_cluster_service_proto = out.File
+	file_cluster_service_proto_rawDesc = nil
+	file_cluster_service_proto_goTypes = nil
+	file_cluster_service_proto_depIdxs = nil
+}
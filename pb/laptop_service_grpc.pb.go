@@ -23,8 +23,26 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type LaptopServiceClient interface {
 	CreateLaptopService(ctx context.Context, in *CreateLaptopRequest, opts ...grpc.CallOption) (*CreateLaptopResponse, error)
+	CreateLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_CreateLaptopsClient, error)
+	GetLaptop(ctx context.Context, in *GetLaptopRequest, opts ...grpc.CallOption) (*GetLaptopResponse, error)
+	UpdateLaptop(ctx context.Context, in *UpdateLaptopRequest, opts ...grpc.CallOption) (*UpdateLaptopResponse, error)
+	DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error)
+	ListDeletedLaptops(ctx context.Context, in *ListDeletedLaptopsRequest, opts ...grpc.CallOption) (LaptopService_ListDeletedLaptopsClient, error)
+	UndeleteLaptop(ctx context.Context, in *UndeleteLaptopRequest, opts ...grpc.CallOption) (*UndeleteLaptopResponse, error)
+	ListLaptopRevisions(ctx context.Context, in *ListLaptopRevisionsRequest, opts ...grpc.CallOption) (*ListLaptopRevisionsResponse, error)
+	GetLaptopRevision(ctx context.Context, in *GetLaptopRevisionRequest, opts ...grpc.CallOption) (*GetLaptopRevisionResponse, error)
+	DiffLaptopRevisions(ctx context.Context, in *DiffLaptopRevisionsRequest, opts ...grpc.CallOption) (*DiffLaptopRevisionsResponse, error)
 	SearchLaptopService(ctx context.Context, in *SearchLaptopRequest, opts ...grpc.CallOption) (LaptopService_SearchLaptopServiceClient, error)
+	ListLaptops(ctx context.Context, in *ListLaptopsRequest, opts ...grpc.CallOption) (*ListLaptopsResponse, error)
+	SearchFacets(ctx context.Context, in *SearchFacetsRequest, opts ...grpc.CallOption) (*SearchFacetsResponse, error)
+	WatchLaptops(ctx context.Context, in *WatchLaptopsRequest, opts ...grpc.CallOption) (LaptopService_WatchLaptopsClient, error)
 	UploadImageService(ctx context.Context, opts ...grpc.CallOption) (LaptopService_UploadImageServiceClient, error)
+	StartImageUpload(ctx context.Context, in *StartImageUploadRequest, opts ...grpc.CallOption) (*StartImageUploadResponse, error)
+	UploadImageChunk(ctx context.Context, in *UploadImageChunkRequest, opts ...grpc.CallOption) (*UploadImageChunkResponse, error)
+	GetImageUpload(ctx context.Context, in *GetImageUploadRequest, opts ...grpc.CallOption) (*GetImageUploadResponse, error)
+	FinishImageUpload(ctx context.Context, in *FinishImageUploadRequest, opts ...grpc.CallOption) (*FinishImageUploadResponse, error)
+	ListLaptopImages(ctx context.Context, in *ListLaptopImagesRequest, opts ...grpc.CallOption) (*ListLaptopImagesResponse, error)
+	DownloadImage(ctx context.Context, in *DownloadImageRequest, opts ...grpc.CallOption) (LaptopService_DownloadImageClient, error)
 	RateLaptopService(ctx context.Context, opts ...grpc.CallOption) (LaptopService_RateLaptopServiceClient, error)
 }
 
@@ -45,8 +63,137 @@ func (c *laptopServiceClient) CreateLaptopService(ctx context.Context, in *Creat
 	return out, nil
 }
 
+func (c *laptopServiceClient) CreateLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_CreateLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[0], "/pb.LaptopService/CreateLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceCreateLaptopsClient{stream}
+	return x, nil
+}
+
+type LaptopService_CreateLaptopsClient interface {
+	Send(*CreateLaptopsRequest) error
+	CloseAndRecv() (*CreateLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceCreateLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceCreateLaptopsClient) Send(m *CreateLaptopsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceCreateLaptopsClient) CloseAndRecv() (*CreateLaptopsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CreateLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) GetLaptop(ctx context.Context, in *GetLaptopRequest, opts ...grpc.CallOption) (*GetLaptopResponse, error) {
+	out := new(GetLaptopResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/GetLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) UpdateLaptop(ctx context.Context, in *UpdateLaptopRequest, opts ...grpc.CallOption) (*UpdateLaptopResponse, error) {
+	out := new(UpdateLaptopResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/UpdateLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error) {
+	out := new(DeleteLaptopResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/DeleteLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ListDeletedLaptops(ctx context.Context, in *ListDeletedLaptopsRequest, opts ...grpc.CallOption) (LaptopService_ListDeletedLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[1], "/pb.LaptopService/ListDeletedLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceListDeletedLaptopsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_ListDeletedLaptopsClient interface {
+	Recv() (*ListDeletedLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceListDeletedLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceListDeletedLaptopsClient) Recv() (*ListDeletedLaptopsResponse, error) {
+	m := new(ListDeletedLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) UndeleteLaptop(ctx context.Context, in *UndeleteLaptopRequest, opts ...grpc.CallOption) (*UndeleteLaptopResponse, error) {
+	out := new(UndeleteLaptopResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/UndeleteLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ListLaptopRevisions(ctx context.Context, in *ListLaptopRevisionsRequest, opts ...grpc.CallOption) (*ListLaptopRevisionsResponse, error) {
+	out := new(ListLaptopRevisionsResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/ListLaptopRevisions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) GetLaptopRevision(ctx context.Context, in *GetLaptopRevisionRequest, opts ...grpc.CallOption) (*GetLaptopRevisionResponse, error) {
+	out := new(GetLaptopRevisionResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/GetLaptopRevision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DiffLaptopRevisions(ctx context.Context, in *DiffLaptopRevisionsRequest, opts ...grpc.CallOption) (*DiffLaptopRevisionsResponse, error) {
+	out := new(DiffLaptopRevisionsResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/DiffLaptopRevisions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *laptopServiceClient) SearchLaptopService(ctx context.Context, in *SearchLaptopRequest, opts ...grpc.CallOption) (LaptopService_SearchLaptopServiceClient, error) {
-	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[0], "/pb.LaptopService/SearchLaptopService", opts...)
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[2], "/pb.LaptopService/SearchLaptopService", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +224,58 @@ func (x *laptopServiceSearchLaptopServiceClient) Recv() (*SearchLaptopResponse,
 	return m, nil
 }
 
+func (c *laptopServiceClient) ListLaptops(ctx context.Context, in *ListLaptopsRequest, opts ...grpc.CallOption) (*ListLaptopsResponse, error) {
+	out := new(ListLaptopsResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/ListLaptops", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) SearchFacets(ctx context.Context, in *SearchFacetsRequest, opts ...grpc.CallOption) (*SearchFacetsResponse, error) {
+	out := new(SearchFacetsResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/SearchFacets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) WatchLaptops(ctx context.Context, in *WatchLaptopsRequest, opts ...grpc.CallOption) (LaptopService_WatchLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[3], "/pb.LaptopService/WatchLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceWatchLaptopsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_WatchLaptopsClient interface {
+	Recv() (*WatchLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceWatchLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceWatchLaptopsClient) Recv() (*WatchLaptopsResponse, error) {
+	m := new(WatchLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *laptopServiceClient) UploadImageService(ctx context.Context, opts ...grpc.CallOption) (LaptopService_UploadImageServiceClient, error) {
-	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[1], "/pb.LaptopService/UploadImageService", opts...)
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[4], "/pb.LaptopService/UploadImageService", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -111,8 +308,85 @@ func (x *laptopServiceUploadImageServiceClient) CloseAndRecv() (*UploadImageResp
 	return m, nil
 }
 
+func (c *laptopServiceClient) StartImageUpload(ctx context.Context, in *StartImageUploadRequest, opts ...grpc.CallOption) (*StartImageUploadResponse, error) {
+	out := new(StartImageUploadResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/StartImageUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) UploadImageChunk(ctx context.Context, in *UploadImageChunkRequest, opts ...grpc.CallOption) (*UploadImageChunkResponse, error) {
+	out := new(UploadImageChunkResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/UploadImageChunk", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) GetImageUpload(ctx context.Context, in *GetImageUploadRequest, opts ...grpc.CallOption) (*GetImageUploadResponse, error) {
+	out := new(GetImageUploadResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/GetImageUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) FinishImageUpload(ctx context.Context, in *FinishImageUploadRequest, opts ...grpc.CallOption) (*FinishImageUploadResponse, error) {
+	out := new(FinishImageUploadResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/FinishImageUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) ListLaptopImages(ctx context.Context, in *ListLaptopImagesRequest, opts ...grpc.CallOption) (*ListLaptopImagesResponse, error) {
+	out := new(ListLaptopImagesResponse)
+	err := c.cc.Invoke(ctx, "/pb.LaptopService/ListLaptopImages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DownloadImage(ctx context.Context, in *DownloadImageRequest, opts ...grpc.CallOption) (LaptopService_DownloadImageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[5], "/pb.LaptopService/DownloadImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceDownloadImageClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_DownloadImageClient interface {
+	Recv() (*DownloadImageResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceDownloadImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceDownloadImageClient) Recv() (*DownloadImageResponse, error) {
+	m := new(DownloadImageResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *laptopServiceClient) RateLaptopService(ctx context.Context, opts ...grpc.CallOption) (LaptopService_RateLaptopServiceClient, error) {
-	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[2], "/pb.LaptopService/RateLaptopService", opts...)
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[6], "/pb.LaptopService/RateLaptopService", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -147,8 +421,26 @@ func (x *laptopServiceRateLaptopServiceClient) Recv() (*RateLaptopResponse, erro
 // for forward compatibility
 type LaptopServiceServer interface {
 	CreateLaptopService(context.Context, *CreateLaptopRequest) (*CreateLaptopResponse, error)
+	CreateLaptops(LaptopService_CreateLaptopsServer) error
+	GetLaptop(context.Context, *GetLaptopRequest) (*GetLaptopResponse, error)
+	UpdateLaptop(context.Context, *UpdateLaptopRequest) (*UpdateLaptopResponse, error)
+	DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error)
+	ListDeletedLaptops(*ListDeletedLaptopsRequest, LaptopService_ListDeletedLaptopsServer) error
+	UndeleteLaptop(context.Context, *UndeleteLaptopRequest) (*UndeleteLaptopResponse, error)
+	ListLaptopRevisions(context.Context, *ListLaptopRevisionsRequest) (*ListLaptopRevisionsResponse, error)
+	GetLaptopRevision(context.Context, *GetLaptopRevisionRequest) (*GetLaptopRevisionResponse, error)
+	DiffLaptopRevisions(context.Context, *DiffLaptopRevisionsRequest) (*DiffLaptopRevisionsResponse, error)
 	SearchLaptopService(*SearchLaptopRequest, LaptopService_SearchLaptopServiceServer) error
+	ListLaptops(context.Context, *ListLaptopsRequest) (*ListLaptopsResponse, error)
+	SearchFacets(context.Context, *SearchFacetsRequest) (*SearchFacetsResponse, error)
+	WatchLaptops(*WatchLaptopsRequest, LaptopService_WatchLaptopsServer) error
 	UploadImageService(LaptopService_UploadImageServiceServer) error
+	StartImageUpload(context.Context, *StartImageUploadRequest) (*StartImageUploadResponse, error)
+	UploadImageChunk(context.Context, *UploadImageChunkRequest) (*UploadImageChunkResponse, error)
+	GetImageUpload(context.Context, *GetImageUploadRequest) (*GetImageUploadResponse, error)
+	FinishImageUpload(context.Context, *FinishImageUploadRequest) (*FinishImageUploadResponse, error)
+	ListLaptopImages(context.Context, *ListLaptopImagesRequest) (*ListLaptopImagesResponse, error)
+	DownloadImage(*DownloadImageRequest, LaptopService_DownloadImageServer) error
 	RateLaptopService(LaptopService_RateLaptopServiceServer) error
 	mustEmbedUnimplementedLaptopServiceServer()
 }
@@ -160,12 +452,66 @@ type UnimplementedLaptopServiceServer struct {
 func (UnimplementedLaptopServiceServer) CreateLaptopService(context.Context, *CreateLaptopRequest) (*CreateLaptopResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateLaptopService not implemented")
 }
+func (UnimplementedLaptopServiceServer) CreateLaptops(LaptopService_CreateLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) GetLaptop(context.Context, *GetLaptopRequest) (*GetLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) UpdateLaptop(context.Context, *UpdateLaptopRequest) (*UpdateLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) ListDeletedLaptops(*ListDeletedLaptopsRequest, LaptopService_ListDeletedLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListDeletedLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) UndeleteLaptop(context.Context, *UndeleteLaptopRequest) (*UndeleteLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UndeleteLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) ListLaptopRevisions(context.Context, *ListLaptopRevisionsRequest) (*ListLaptopRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLaptopRevisions not implemented")
+}
+func (UnimplementedLaptopServiceServer) GetLaptopRevision(context.Context, *GetLaptopRevisionRequest) (*GetLaptopRevisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLaptopRevision not implemented")
+}
+func (UnimplementedLaptopServiceServer) DiffLaptopRevisions(context.Context, *DiffLaptopRevisionsRequest) (*DiffLaptopRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffLaptopRevisions not implemented")
+}
 func (UnimplementedLaptopServiceServer) SearchLaptopService(*SearchLaptopRequest, LaptopService_SearchLaptopServiceServer) error {
 	return status.Errorf(codes.Unimplemented, "method SearchLaptopService not implemented")
 }
+func (UnimplementedLaptopServiceServer) ListLaptops(context.Context, *ListLaptopsRequest) (*ListLaptopsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) SearchFacets(context.Context, *SearchFacetsRequest) (*SearchFacetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchFacets not implemented")
+}
+func (UnimplementedLaptopServiceServer) WatchLaptops(*WatchLaptopsRequest, LaptopService_WatchLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchLaptops not implemented")
+}
 func (UnimplementedLaptopServiceServer) UploadImageService(LaptopService_UploadImageServiceServer) error {
 	return status.Errorf(codes.Unimplemented, "method UploadImageService not implemented")
 }
+func (UnimplementedLaptopServiceServer) StartImageUpload(context.Context, *StartImageUploadRequest) (*StartImageUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartImageUpload not implemented")
+}
+func (UnimplementedLaptopServiceServer) UploadImageChunk(context.Context, *UploadImageChunkRequest) (*UploadImageChunkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadImageChunk not implemented")
+}
+func (UnimplementedLaptopServiceServer) GetImageUpload(context.Context, *GetImageUploadRequest) (*GetImageUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetImageUpload not implemented")
+}
+func (UnimplementedLaptopServiceServer) FinishImageUpload(context.Context, *FinishImageUploadRequest) (*FinishImageUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishImageUpload not implemented")
+}
+func (UnimplementedLaptopServiceServer) ListLaptopImages(context.Context, *ListLaptopImagesRequest) (*ListLaptopImagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLaptopImages not implemented")
+}
+func (UnimplementedLaptopServiceServer) DownloadImage(*DownloadImageRequest, LaptopService_DownloadImageServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadImage not implemented")
+}
 func (UnimplementedLaptopServiceServer) RateLaptopService(LaptopService_RateLaptopServiceServer) error {
 	return status.Errorf(codes.Unimplemented, "method RateLaptopService not implemented")
 }
@@ -200,6 +546,179 @@ func _LaptopService_CreateLaptopService_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LaptopService_CreateLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).CreateLaptops(&laptopServiceCreateLaptopsServer{stream})
+}
+
+type LaptopService_CreateLaptopsServer interface {
+	SendAndClose(*CreateLaptopsResponse) error
+	Recv() (*CreateLaptopsRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceCreateLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceCreateLaptopsServer) SendAndClose(m *CreateLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceCreateLaptopsServer) Recv() (*CreateLaptopsRequest, error) {
+	m := new(CreateLaptopsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LaptopService_GetLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).GetLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/GetLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).GetLaptop(ctx, req.(*GetLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_UpdateLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).UpdateLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/UpdateLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).UpdateLaptop(ctx, req.(*UpdateLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DeleteLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/DeleteLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, req.(*DeleteLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ListDeletedLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListDeletedLaptopsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).ListDeletedLaptops(m, &laptopServiceListDeletedLaptopsServer{stream})
+}
+
+type LaptopService_ListDeletedLaptopsServer interface {
+	Send(*ListDeletedLaptopsResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceListDeletedLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceListDeletedLaptopsServer) Send(m *ListDeletedLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_UndeleteLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UndeleteLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).UndeleteLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/UndeleteLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).UndeleteLaptop(ctx, req.(*UndeleteLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ListLaptopRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLaptopRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).ListLaptopRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/ListLaptopRevisions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).ListLaptopRevisions(ctx, req.(*ListLaptopRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_GetLaptopRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLaptopRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).GetLaptopRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/GetLaptopRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).GetLaptopRevision(ctx, req.(*GetLaptopRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DiffLaptopRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffLaptopRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).DiffLaptopRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/DiffLaptopRevisions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).DiffLaptopRevisions(ctx, req.(*DiffLaptopRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _LaptopService_SearchLaptopService_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SearchLaptopRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -221,6 +740,63 @@ func (x *laptopServiceSearchLaptopServiceServer) Send(m *SearchLaptopResponse) e
 	return x.ServerStream.SendMsg(m)
 }
 
+func _LaptopService_ListLaptops_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLaptopsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).ListLaptops(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/ListLaptops",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).ListLaptops(ctx, req.(*ListLaptopsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_SearchFacets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchFacetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).SearchFacets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/SearchFacets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).SearchFacets(ctx, req.(*SearchFacetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_WatchLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchLaptopsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).WatchLaptops(m, &laptopServiceWatchLaptopsServer{stream})
+}
+
+type LaptopService_WatchLaptopsServer interface {
+	Send(*WatchLaptopsResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceWatchLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceWatchLaptopsServer) Send(m *WatchLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _LaptopService_UploadImageService_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(LaptopServiceServer).UploadImageService(&laptopServiceUploadImageServiceServer{stream})
 }
@@ -247,6 +823,117 @@ func (x *laptopServiceUploadImageServiceServer) Recv() (*UploadImageRequest, err
 	return m, nil
 }
 
+func _LaptopService_StartImageUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartImageUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).StartImageUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/StartImageUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).StartImageUpload(ctx, req.(*StartImageUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_UploadImageChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadImageChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).UploadImageChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/UploadImageChunk",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).UploadImageChunk(ctx, req.(*UploadImageChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_GetImageUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetImageUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).GetImageUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/GetImageUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).GetImageUpload(ctx, req.(*GetImageUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_FinishImageUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishImageUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).FinishImageUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/FinishImageUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).FinishImageUpload(ctx, req.(*FinishImageUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_ListLaptopImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLaptopImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).ListLaptopImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.LaptopService/ListLaptopImages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).ListLaptopImages(ctx, req.(*ListLaptopImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DownloadImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadImageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).DownloadImage(m, &laptopServiceDownloadImageServer{stream})
+}
+
+type LaptopService_DownloadImageServer interface {
+	Send(*DownloadImageResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceDownloadImageServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceDownloadImageServer) Send(m *DownloadImageResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _LaptopService_RateLaptopService_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(LaptopServiceServer).RateLaptopService(&laptopServiceRateLaptopServiceServer{stream})
 }
@@ -284,18 +971,94 @@ var LaptopService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateLaptopService",
 			Handler:    _LaptopService_CreateLaptopService_Handler,
 		},
+		{
+			MethodName: "GetLaptop",
+			Handler:    _LaptopService_GetLaptop_Handler,
+		},
+		{
+			MethodName: "UpdateLaptop",
+			Handler:    _LaptopService_UpdateLaptop_Handler,
+		},
+		{
+			MethodName: "DeleteLaptop",
+			Handler:    _LaptopService_DeleteLaptop_Handler,
+		},
+		{
+			MethodName: "UndeleteLaptop",
+			Handler:    _LaptopService_UndeleteLaptop_Handler,
+		},
+		{
+			MethodName: "ListLaptopRevisions",
+			Handler:    _LaptopService_ListLaptopRevisions_Handler,
+		},
+		{
+			MethodName: "GetLaptopRevision",
+			Handler:    _LaptopService_GetLaptopRevision_Handler,
+		},
+		{
+			MethodName: "DiffLaptopRevisions",
+			Handler:    _LaptopService_DiffLaptopRevisions_Handler,
+		},
+		{
+			MethodName: "ListLaptops",
+			Handler:    _LaptopService_ListLaptops_Handler,
+		},
+		{
+			MethodName: "SearchFacets",
+			Handler:    _LaptopService_SearchFacets_Handler,
+		},
+		{
+			MethodName: "StartImageUpload",
+			Handler:    _LaptopService_StartImageUpload_Handler,
+		},
+		{
+			MethodName: "UploadImageChunk",
+			Handler:    _LaptopService_UploadImageChunk_Handler,
+		},
+		{
+			MethodName: "GetImageUpload",
+			Handler:    _LaptopService_GetImageUpload_Handler,
+		},
+		{
+			MethodName: "FinishImageUpload",
+			Handler:    _LaptopService_FinishImageUpload_Handler,
+		},
+		{
+			MethodName: "ListLaptopImages",
+			Handler:    _LaptopService_ListLaptopImages_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateLaptops",
+			Handler:       _LaptopService_CreateLaptops_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ListDeletedLaptops",
+			Handler:       _LaptopService_ListDeletedLaptops_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "SearchLaptopService",
 			Handler:       _LaptopService_SearchLaptopService_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchLaptops",
+			Handler:       _LaptopService_WatchLaptops_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "UploadImageService",
 			Handler:       _LaptopService_UploadImageService_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "DownloadImage",
+			Handler:       _LaptopService_DownloadImage_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "RateLaptopService",
 			Handler:       _LaptopService_RateLaptopService_Handler,
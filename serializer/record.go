@@ -0,0 +1,95 @@
+package serializer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRecordSize bounds the length read from a record header, so a corrupt one cannot allocate too much
+const maxRecordSize = 64 << 20
+
+// ErrCorruptRecord is returned when a record is cut short or does not match its checksum
+var ErrCorruptRecord = errors.New("corrupt record")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteRecord writes the message as a record: its varint length, the CRC-32C of the data, then the data
+func WriteRecord(writer io.Writer, message proto.Message) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("cannot marshal proto message to binary %w ", err)
+	}
+
+	record := make([]byte, 0, binary.MaxVarintLen64+4+len(data))
+	record = binary.AppendUvarint(record, uint64(len(data)))
+	record = binary.BigEndian.AppendUint32(record, crc32.Checksum(data, crcTable))
+	record = append(record, data...)
+
+	_, err = writer.Write(record)
+	if err != nil {
+		return fmt.Errorf("cannot write record %w ", err)
+	}
+
+	return nil
+}
+
+// RecordReader reads the records written by WriteRecord
+type RecordReader struct {
+	reader *bufio.Reader
+	offset int64
+}
+
+func NewRecordReader(reader io.Reader) *RecordReader {
+	return &RecordReader{
+		reader: bufio.NewReader(reader),
+	}
+}
+
+// Offset returns the position after the last record read successfully
+func (reader *RecordReader) Offset() int64 {
+	return reader.offset
+}
+
+// Read reads the next record into message. It returns io.EOF after the last record,
+// and ErrCorruptRecord if the next record is incomplete or its checksum does not match.
+func (reader *RecordReader) Read(message proto.Message) error {
+	length, err := binary.ReadUvarint(reader.reader)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("%w: cannot read length: %v", ErrCorruptRecord, err)
+	}
+	if length > maxRecordSize {
+		return fmt.Errorf("%w: length %d is too large", ErrCorruptRecord, length)
+	}
+
+	record := make([]byte, 4+length)
+	_, err = io.ReadFull(reader.reader, record)
+	if err != nil {
+		return fmt.Errorf("%w: cannot read data: %v", ErrCorruptRecord, err)
+	}
+
+	data := record[4:]
+	if binary.BigEndian.Uint32(record) != crc32.Checksum(data, crcTable) {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorruptRecord)
+	}
+
+	err = proto.Unmarshal(data, message)
+	if err != nil {
+		return fmt.Errorf("%w: cannot unmarshal binary to proto message: %v", ErrCorruptRecord, err)
+	}
+
+	reader.offset += int64(uvarintSize(length)) + int64(len(record))
+	return nil
+}
+
+func uvarintSize(value uint64) int {
+	return len(binary.AppendUvarint(nil, value))
+}
@@ -0,0 +1,49 @@
+package serializer
+
+import (
+	"bytes"
+	"gobook/pb"
+	"gobook/sample"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRecord(t *testing.T) {
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+
+	buffer := &bytes.Buffer{}
+	err := WriteRecord(buffer, laptop1)
+	require.NoError(t, err)
+	size := buffer.Len()
+	err = WriteRecord(buffer, laptop2)
+	require.NoError(t, err)
+	data := buffer.Bytes()
+
+	reader := NewRecordReader(bytes.NewReader(data))
+	for _, expected := range []*pb.Laptop{laptop1, laptop2} {
+		laptop := &pb.Laptop{}
+		err = reader.Read(laptop)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(expected, laptop))
+	}
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+	require.Equal(t, int64(len(data)), reader.Offset())
+
+	// A torn tail
+	reader = NewRecordReader(bytes.NewReader(data[:len(data)-3]))
+	require.NoError(t, reader.Read(&pb.Laptop{}))
+	require.ErrorIs(t, reader.Read(&pb.Laptop{}), ErrCorruptRecord)
+	require.Equal(t, int64(size), reader.Offset())
+
+	// A flipped byte
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	reader = NewRecordReader(bytes.NewReader(corrupt))
+	require.NoError(t, reader.Read(&pb.Laptop{}))
+	require.ErrorIs(t, reader.Read(&pb.Laptop{}), ErrCorruptRecord)
+	require.Equal(t, int64(size), reader.Offset())
+}
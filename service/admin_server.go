@@ -0,0 +1,422 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gobook/pb"
+	"io"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// catalogVersion is the version of the archives written by ExportCatalog
+const catalogVersion = 1
+
+// maxCatalogImportSize is the default largest size of the items of an imported archive
+const maxCatalogImportSize = 128 << 20
+
+type AdminServer struct {
+	pb.UnimplementedAdminServiceServer
+	laptopStore LaptopStore
+	ratingStore RatingStore
+	userStore   UserStore
+	imageIndex  ImageIndex
+	// imageFolder is where the files of the imported images are expected
+	imageFolder string
+	// maxImportSize is the largest size of the items of an imported archive
+	maxImportSize int
+}
+
+func NewAdminServer(
+	laptopStore LaptopStore,
+	ratingStore RatingStore,
+	userStore UserStore,
+	imageIndex ImageIndex,
+	imageFolder string,
+) pb.AdminServiceServer {
+	return &AdminServer{
+		laptopStore:   laptopStore,
+		ratingStore:   ratingStore,
+		userStore:     userStore,
+		imageIndex:    imageIndex,
+		imageFolder:   imageFolder,
+		maxImportSize: maxCatalogImportSize,
+	}
+}
+
+// ExportCatalog sends a header, then the laptops, ratings, users and image metadata.
+// The laptops in the trash are left out with their ratings and images, they are purged after the retention.
+func (server *AdminServer) ExportCatalog(
+	req *pb.ExportCatalogRequest,
+	stream pb.AdminService_ExportCatalogServer,
+) error {
+	log.Print("receive an export-catalog request")
+
+	count := 0
+	send := func(item *pb.CatalogItem) error {
+		err := stream.Send(&pb.ExportCatalogResponse{Item: item})
+		if err != nil {
+			return status.Errorf(codes.Unknown, "cannot send catalog item: %v", err)
+		}
+		count++
+		return nil
+	}
+
+	err := send(&pb.CatalogItem{
+		Item: &pb.CatalogItem_Header{
+			Header: &pb.CatalogHeader{
+				Version:    catalogVersion,
+				ExportedAt: timestamppb.Now(),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = server.laptopStore.Search(stream.Context(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		return send(&pb.CatalogItem{Item: &pb.CatalogItem_Laptop{Laptop: laptop}})
+	})
+	if err != nil {
+		return exportError("laptops", err)
+	}
+
+	err = server.ratingStore.List(func(laptopID string, rating *Rating) error {
+		if server.laptopStore.FindDeleted(laptopID) != nil {
+			return nil
+		}
+		return send(&pb.CatalogItem{
+			Item: &pb.CatalogItem_Rating{
+				Rating: &pb.RatingRecord{
+					LaptopId: laptopID,
+					Count:    rating.Count,
+					Sum:      rating.Sum,
+				},
+			},
+		})
+	})
+	if err != nil {
+		return exportError("ratings", err)
+	}
+
+	err = server.userStore.List(func(user *User) error {
+		return send(&pb.CatalogItem{
+			Item: &pb.CatalogItem_User{
+				User: &pb.CatalogUser{
+					Username: user.Username,
+					Role:     user.Role,
+				},
+			},
+		})
+	})
+	if err != nil {
+		return exportError("users", err)
+	}
+
+	// Without an image index, as in cluster mode, the archive has no images
+	if server.imageIndex != nil {
+		err = server.imageIndex.List(func(imageID string, info *ImageInfo) error {
+			if server.laptopStore.FindDeleted(info.LaptopID) != nil {
+				return nil
+			}
+			return send(&pb.CatalogItem{
+				Item: &pb.CatalogItem_Image{
+					Image: newImageRecord(imageID, info),
+				},
+			})
+		})
+		if err != nil {
+			return exportError("images", err)
+		}
+	}
+
+	// The laptop search stops without error when the context is done
+	err = contextError(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("exported %d catalog items", count)
+	return nil
+}
+
+// exportError keeps the status of a failed send, and reports other errors as internal ones
+func exportError(what string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	return status.Errorf(codes.Internal, "cannot export %s: %v", what, err)
+}
+
+// ImportCatalog receives the import options then an archive written by ExportCatalog.
+// The archive is checked against the stores before anything is written, so a FAIL policy
+// or an invalid archive leaves the stores unchanged.
+func (server *AdminServer) ImportCatalog(stream pb.AdminService_ImportCatalogServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.Unknown, "cannot receive import options: %v", err)
+	}
+
+	options := req.GetOptions()
+	if options == nil {
+		return status.Errorf(codes.InvalidArgument, "import options must be sent first")
+	}
+
+	log.Printf(
+		"receive an import-catalog request with dry run %t and conflict policy %s",
+		options.GetDryRun(),
+		options.GetConflictPolicy(),
+	)
+
+	items := []*pb.CatalogItem{}
+	itemsSize := 0
+	hasHeader := false
+
+	for {
+		err := contextError(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Unknown, "cannot receive catalog item: %v", err)
+		}
+
+		item := req.GetItem()
+		if item == nil {
+			return status.Errorf(codes.InvalidArgument, "import options can only be sent first")
+		}
+
+		header := item.GetHeader()
+		if hasHeader != (header == nil) {
+			return status.Errorf(codes.InvalidArgument, "archive must start with a single header")
+		}
+		if header != nil {
+			if header.GetVersion() != catalogVersion {
+				return status.Errorf(codes.InvalidArgument, "unsupported archive version %d", header.GetVersion())
+			}
+			hasHeader = true
+			continue
+		}
+
+		// The items are held until the whole archive is checked, so their size is limited
+		itemsSize += proto.Size(item)
+		if itemsSize > server.maxImportSize {
+			return status.Errorf(codes.ResourceExhausted, "archive is larger than %d bytes", server.maxImportSize)
+		}
+
+		items = append(items, item)
+	}
+
+	if !hasHeader {
+		return status.Errorf(codes.InvalidArgument, "archive must start with a single header")
+	}
+
+	catalogImport := &catalogImport{
+		server: server,
+		policy: options.GetConflictPolicy(),
+		seen:   make(map[string]bool),
+		res: &pb.ImportCatalogResponse{
+			DryRun:      options.GetDryRun(),
+			Created:     &pb.CatalogCounts{},
+			Overwritten: &pb.CatalogCounts{},
+			Skipped:     &pb.CatalogCounts{},
+		},
+	}
+
+	writes, err := catalogImport.plan(items)
+	if err != nil {
+		return err
+	}
+
+	if !options.GetDryRun() {
+		for _, write := range writes {
+			err := write()
+			if err != nil {
+				return status.Errorf(storeErrorCode(err), "cannot import catalog: %v", err)
+			}
+		}
+	}
+
+	log.Printf("imported %d of %d catalog items", len(writes), len(items))
+	return stream.SendAndClose(catalogImport.res)
+}
+
+// catalogImport decides what to do with every item of an archive
+type catalogImport struct {
+	server *AdminServer
+	policy pb.ImportOptions_ConflictPolicy
+	// seen has the kind and key of the items already planned
+	seen map[string]bool
+	res  *pb.ImportCatalogResponse
+}
+
+// plan checks the items, counts them in the response, and returns the writes to import them
+func (catalogImport *catalogImport) plan(items []*pb.CatalogItem) ([]func() error, error) {
+	writes := []func() error{}
+
+	for _, item := range items {
+		write, err := catalogImport.planItem(item)
+		if err != nil {
+			return nil, err
+		}
+		if write != nil {
+			writes = append(writes, write)
+		}
+	}
+
+	return writes, nil
+}
+
+func (catalogImport *catalogImport) planItem(item *pb.CatalogItem) (func() error, error) {
+	server := catalogImport.server
+
+	switch item := item.GetItem().(type) {
+	case *pb.CatalogItem_Laptop:
+		laptop := proto.Clone(item.Laptop).(*pb.Laptop)
+		err := checkLaptopID(laptop.GetId())
+		if err != nil {
+			return nil, err
+		}
+		err = validateLaptop(laptop)
+		if err != nil {
+			return nil, err
+		}
+
+		// A laptop in the trash keeps its id until it is purged, and cannot be overwritten
+		deleted := server.laptopStore.FindDeleted(laptop.GetId()) != nil
+		exists := deleted || server.laptopStore.Find(laptop.GetId()) != nil
+		action, err := catalogImport.decide("laptop", laptop.GetId(), exists, !deleted)
+		if err != nil || action == importSkip {
+			return nil, err
+		}
+
+		if action == importOverwrite {
+			return func() error { return server.laptopStore.Update(laptop, 0) }, nil
+		}
+		return func() error { return server.laptopStore.Save(laptop) }, nil
+
+	case *pb.CatalogItem_Rating:
+		record := item.Rating
+		if record.GetLaptopId() == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "rating laptop id is required")
+		}
+
+		exists := server.ratingStore.Find(record.GetLaptopId()) != nil
+		action, err := catalogImport.decide("rating", record.GetLaptopId(), exists, true)
+		if err != nil || action == importSkip {
+			return nil, err
+		}
+
+		rating := &Rating{
+			Count: record.GetCount(),
+			Sum:   record.GetSum(),
+		}
+		return func() error { return server.ratingStore.Set(record.GetLaptopId(), rating) }, nil
+
+	case *pb.CatalogItem_User:
+		user := item.User
+		if user.GetUsername() == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "username is required")
+		}
+
+		// The archive has no password, so an existing user is never overwritten
+		_, err := server.userStore.Find(user.GetUsername())
+		action, err := catalogImport.decide("user", user.GetUsername(), err == nil, false)
+		if err != nil || action == importSkip {
+			return nil, err
+		}
+
+		// A user imported without password cannot log in until one is set
+		return func() error {
+			return server.userStore.Save(&User{
+				Username: user.GetUsername(),
+				Role:     user.GetRole(),
+			})
+		}, nil
+
+	case *pb.CatalogItem_Image:
+		record := item.Image
+		if record.GetId() == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "image id is required")
+		}
+		if server.imageIndex == nil {
+			return nil, status.Errorf(codes.Unimplemented, "images are not stored")
+		}
+
+		_, err := server.imageIndex.Find(record.GetId())
+		if err != nil && !errors.Is(err, ErrImageNotFound) {
+			return nil, status.Errorf(codes.Internal, "cannot find image %s: %v", record.GetId(), err)
+		}
+
+		action, err := catalogImport.decide("image", record.GetId(), err == nil, true)
+		if err != nil || action == importSkip {
+			return nil, err
+		}
+
+		info, err := importedImageInfo(server.imageFolder, record)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "cannot import image %s: %v", record.GetId(), err)
+		}
+		return func() error { return server.imageIndex.Save(record.GetId(), info) }, nil
+
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown catalog item %T", item)
+	}
+}
+
+type importAction int
+
+const (
+	importSkip importAction = iota
+	importCreate
+	importOverwrite
+)
+
+// decide applies the conflict policy to an item and counts it in the response.
+// An existing item that cannot be overwritten is skipped by the OVERWRITE policy.
+func (catalogImport *catalogImport) decide(kind string, key string, exists bool, overwritable bool) (importAction, error) {
+	seenKey := fmt.Sprintf("%s/%s", kind, key)
+	if catalogImport.seen[seenKey] {
+		return importSkip, status.Errorf(codes.InvalidArgument, "duplicate %s %s in archive", kind, key)
+	}
+	catalogImport.seen[seenKey] = true
+
+	action := importCreate
+	counts := catalogImport.res.Created
+
+	if exists {
+		switch {
+		case catalogImport.policy == pb.ImportOptions_FAIL:
+			return importSkip, status.Errorf(codes.AlreadyExists, "%s %s already exists", kind, key)
+		case catalogImport.policy == pb.ImportOptions_OVERWRITE && overwritable:
+			action = importOverwrite
+			counts = catalogImport.res.Overwritten
+		default:
+			action = importSkip
+			counts = catalogImport.res.Skipped
+		}
+	}
+
+	switch kind {
+	case "laptop":
+		counts.Laptops++
+	case "rating":
+		counts.Ratings++
+	case "user":
+		counts.Users++
+	case "image":
+		counts.Images++
+	}
+
+	return action, nil
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type testCatalog struct {
+	laptopStore LaptopStore
+	ratingStore RatingStore
+	userStore   UserStore
+	imageIndex  ImageIndex
+	server      *AdminServer
+	client      pb.AdminServiceClient
+}
+
+func newTestCatalog(t *testing.T) *testCatalog {
+	catalog := &testCatalog{
+		laptopStore: NewInMemoryLaptopStore(),
+		ratingStore: NewInMemoryRatingStore(),
+		userStore:   NewInMemoryUserStore(),
+		imageIndex:  NewInMemoryImageIndex(),
+	}
+
+	grpcServer := grpc.NewServer()
+	catalog.server = NewAdminServer(
+		catalog.laptopStore,
+		catalog.ratingStore,
+		catalog.userStore,
+		catalog.imageIndex,
+		"img",
+	).(*AdminServer)
+	pb.RegisterAdminServiceServer(grpcServer, catalog.server)
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	catalog.client = pb.NewAdminServiceClient(conn)
+
+	return catalog
+}
+
+func exportTestCatalog(t *testing.T, catalog *testCatalog) []*pb.CatalogItem {
+	stream, err := catalog.client.ExportCatalog(context.Background(), &pb.ExportCatalogRequest{})
+	require.NoError(t, err)
+
+	items := []*pb.CatalogItem{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return items
+		}
+		require.NoError(t, err)
+		items = append(items, res.GetItem())
+	}
+}
+
+func importTestCatalog(
+	t *testing.T,
+	catalog *testCatalog,
+	options *pb.ImportOptions,
+	items []*pb.CatalogItem,
+) (*pb.ImportCatalogResponse, error) {
+	stream, err := catalog.client.ImportCatalog(context.Background())
+	require.NoError(t, err)
+
+	err = stream.Send(&pb.ImportCatalogRequest{Data: &pb.ImportCatalogRequest_Options{Options: options}})
+	require.NoError(t, err)
+
+	for _, item := range items {
+		err := stream.Send(&pb.ImportCatalogRequest{Data: &pb.ImportCatalogRequest_Item{Item: item}})
+		if err == io.EOF {
+			// The server rejected the archive, CloseAndRecv returns why
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	return stream.CloseAndRecv()
+}
+
+func TestAdminServerExportImport(t *testing.T) {
+	source := newTestCatalog(t)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, source.laptopStore.Save(laptop))
+	require.NoError(t, source.laptopStore.Save(sample.NewLaptop()))
+	_, err := source.ratingStore.Add(laptop.Id, 4)
+	require.NoError(t, err)
+	user, err := NewUser("admin1", "secret", "admin")
+	require.NoError(t, err)
+	require.NoError(t, source.userStore.Save(user))
+	// The paths of the archive are not trusted, they are rebuilt in the image folder
+	imageID := uuid.New().String()
+	require.NoError(t, source.imageIndex.Save(imageID, &ImageInfo{
+		LaptopID: laptop.Id,
+		Type:     ".jpg",
+		Path:     "cert/server-key.pem",
+		Variants: []*ImageVariant{{Name: "thumbnail", Type: ".jpg", Path: "/etc/passwd"}},
+	}))
+
+	items := exportTestCatalog(t, source)
+	require.Len(t, items, 6)
+	require.Equal(t, uint32(catalogVersion), items[0].GetHeader().GetVersion())
+
+	target := newTestCatalog(t)
+	existing := proto.Clone(laptop).(*pb.Laptop)
+	existing.PriceUsd = 1
+	require.NoError(t, target.laptopStore.Save(existing))
+
+	// A dry run counts without writing
+	res, err := importTestCatalog(t, target, &pb.ImportOptions{DryRun: true}, items)
+	require.NoError(t, err)
+	require.True(t, res.GetDryRun())
+	require.Equal(t, uint32(1), res.GetCreated().GetLaptops())
+	require.Equal(t, uint32(1), res.GetSkipped().GetLaptops())
+	require.Nil(t, target.ratingStore.Find(laptop.Id))
+
+	// FAIL rejects the whole archive
+	_, err = importTestCatalog(t, target, &pb.ImportOptions{ConflictPolicy: pb.ImportOptions_FAIL}, items)
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	require.Nil(t, target.ratingStore.Find(laptop.Id))
+
+	res, err = importTestCatalog(t, target, &pb.ImportOptions{ConflictPolicy: pb.ImportOptions_OVERWRITE}, items)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), res.GetOverwritten().GetLaptops())
+	require.True(t, proto.Equal(&pb.CatalogCounts{Laptops: 1, Ratings: 1, Users: 1, Images: 1}, res.GetCreated()))
+
+	require.Equal(t, laptop.PriceUsd, target.laptopStore.Find(laptop.Id).GetPriceUsd())
+	require.Equal(t, &Rating{Count: 1, Sum: 4}, target.ratingStore.Find(laptop.Id))
+	info, err := target.imageIndex.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, laptop.Id, info.LaptopID)
+	require.Equal(t, "img/"+imageID+".jpg", info.Path)
+	require.Equal(t, "img/"+imageID+"-thumbnail.jpg", info.Variants[0].Path)
+
+	// Users come without their password
+	imported, err := target.userStore.Find(user.Username)
+	require.NoError(t, err)
+	require.Equal(t, "admin", imported.Role)
+	require.False(t, imported.IsPasswordCorrect("secret"))
+
+	// SKIP leaves everything as it is
+	res, err = importTestCatalog(t, target, &pb.ImportOptions{}, items)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&pb.CatalogCounts{Laptops: 2, Ratings: 1, Users: 1, Images: 1}, res.GetSkipped()))
+}
+
+func TestAdminServerExportTrash(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	kept := sample.NewLaptop()
+	trashed := sample.NewLaptop()
+	for _, laptop := range []*pb.Laptop{kept, trashed} {
+		require.NoError(t, catalog.laptopStore.Save(laptop))
+		_, err := catalog.ratingStore.Add(laptop.Id, 4)
+		require.NoError(t, err)
+		require.NoError(t, catalog.imageIndex.Save(uuid.New().String(), &ImageInfo{LaptopID: laptop.Id, Type: ".jpg"}))
+	}
+	require.NoError(t, catalog.laptopStore.Delete(trashed.Id, 0))
+
+	// The laptop in the trash is left out with its rating and image
+	items := exportTestCatalog(t, catalog)
+	require.Len(t, items, 4)
+	require.Equal(t, kept.Id, items[1].GetLaptop().GetId())
+	require.Equal(t, kept.Id, items[2].GetRating().GetLaptopId())
+	require.Equal(t, kept.Id, items[3].GetImage().GetLaptopId())
+
+	// Once undeleted, it is exported again
+	require.NoError(t, catalog.laptopStore.Undelete(trashed.Id, 0))
+	items = exportTestCatalog(t, catalog)
+	require.Len(t, items, 7)
+}
+
+func TestAdminServerImportInvalidArchive(t *testing.T) {
+	catalog := newTestCatalog(t)
+	header := &pb.CatalogItem{Item: &pb.CatalogItem_Header{Header: &pb.CatalogHeader{Version: catalogVersion}}}
+	laptop := &pb.CatalogItem{Item: &pb.CatalogItem_Laptop{Laptop: sample.NewLaptop()}}
+	invalid := sample.NewLaptop()
+	invalid.PriceUsd = -5
+	invalid.Cpu = nil
+	image := func(id string, imageType string, variants ...*pb.ImageVariantRecord) *pb.CatalogItem {
+		return &pb.CatalogItem{Item: &pb.CatalogItem_Image{Image: &pb.ImageRecord{
+			Id:       id,
+			LaptopId: invalid.Id,
+			Type:     imageType,
+			Variants: variants,
+		}}}
+	}
+
+	testCases := []struct {
+		name  string
+		items []*pb.CatalogItem
+	}{
+		{
+			name:  "no_header",
+			items: []*pb.CatalogItem{laptop},
+		},
+		{
+			name:  "unsupported_version",
+			items: []*pb.CatalogItem{{Item: &pb.CatalogItem_Header{Header: &pb.CatalogHeader{Version: 2}}}},
+		},
+		{
+			name:  "second_header",
+			items: []*pb.CatalogItem{header, laptop, header},
+		},
+		{
+			name:  "duplicate_laptop",
+			items: []*pb.CatalogItem{header, laptop, laptop},
+		},
+		{
+			name:  "invalid_laptop",
+			items: []*pb.CatalogItem{header, laptop, {Item: &pb.CatalogItem_Laptop{Laptop: invalid}}},
+		},
+		{
+			name:  "image_id_outside_folder",
+			items: []*pb.CatalogItem{header, image("../cert/server-key", ".jpg")},
+		},
+		{
+			name:  "image_type",
+			items: []*pb.CatalogItem{header, image(uuid.New().String(), "/../../etc/passwd")},
+		},
+		{
+			name: "variant_name_outside_folder",
+			items: []*pb.CatalogItem{header, image(
+				uuid.New().String(),
+				".jpg",
+				&pb.ImageVariantRecord{Name: "../../etc/passwd", Type: ".jpg"},
+			)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := importTestCatalog(t, catalog, &pb.ImportOptions{}, tc.items)
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+
+	require.Nil(t, catalog.laptopStore.Find(laptop.GetLaptop().GetId()))
+	require.Nil(t, catalog.laptopStore.Find(invalid.GetId()))
+}
+
+func TestAdminServerImportTooLarge(t *testing.T) {
+	catalog := newTestCatalog(t)
+	catalog.server.maxImportSize = 1000
+
+	items := []*pb.CatalogItem{{Item: &pb.CatalogItem_Header{Header: &pb.CatalogHeader{Version: catalogVersion}}}}
+	for i := 0; i < 10; i++ {
+		items = append(items, &pb.CatalogItem{Item: &pb.CatalogItem_Laptop{Laptop: sample.NewLaptop()}})
+	}
+
+	_, err := importTestCatalog(t, catalog, &pb.ImportOptions{}, items)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Nil(t, catalog.laptopStore.Find(items[1].GetLaptop().GetId()))
+}
+
+func TestAdminServerWithoutImages(t *testing.T) {
+	catalog := newTestCatalog(t)
+	catalog.server.imageIndex = nil
+	require.NoError(t, catalog.laptopStore.Save(sample.NewLaptop()))
+
+	items := exportTestCatalog(t, catalog)
+	require.Len(t, items, 2)
+
+	image := &pb.CatalogItem{Item: &pb.CatalogItem_Image{Image: &pb.ImageRecord{Id: uuid.New().String(), Type: ".jpg"}}}
+	_, err := importTestCatalog(t, catalog, &pb.ImportOptions{}, append(items, image))
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
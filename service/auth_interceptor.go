@@ -31,7 +31,7 @@ func (interceptor *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 	) (resp interface{}, err error) {
 		log.Println("--> unary interceptor: ", info.FullMethod)
 
-		err = interceptor.authorize(ctx, info.FullMethod)
+		ctx, err = interceptor.authorize(ctx, info.FullMethod)
 		if err != nil {
 			return nil, err
 		}
@@ -49,44 +49,64 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
 	) error {
 		log.Println("--> stream interceptor: ", info.FullMethod)
 
-		err := interceptor.authorize(ss.Context(), info.FullMethod)
+		ctx, err := interceptor.authorize(ss.Context(), info.FullMethod)
 
 		if err != nil {
 			return err
 		}
 
-		return handler(srv, ss)
+		return handler(srv, &authorizedServerStream{ServerStream: ss, ctx: ctx})
 	}
 }
 
-func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) error {
+// authorize checks the token of the request, and returns its context with the claims of the token
+func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
 	accessibleRoles, ok := interceptor.accessibleRoles[method]
 	if !ok {
 		// Every one can access
-		return nil
+		return ctx, nil
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return status.Errorf(codes.Unauthenticated, "metadata is not provided")
+		return nil, status.Errorf(codes.Unauthenticated, "metadata is not provided")
 	}
 
 	values := md["authorization"]
 	if len(values) == 0 {
-		return status.Errorf(codes.Unauthenticated, "authorization token not provided")
+		return nil, status.Errorf(codes.Unauthenticated, "authorization token not provided")
 	}
 
 	token := values[0]
 	claims, err := interceptor.jwtManager.VerifyToken(token)
 	if err != nil {
-		return status.Errorf(codes.Unauthenticated, "cannot authorize token %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "cannot authorize token %v", err)
 	}
 
 	for _, role := range accessibleRoles {
 		if role == claims.Role {
-			return nil
+			return context.WithValue(ctx, claimsContextKey{}, claims), nil
 		}
 	}
 
-	return status.Errorf(codes.PermissionDenied, "no permission to access this GRPC")
+	return nil, status.Errorf(codes.PermissionDenied, "no permission to access this GRPC")
+}
+
+type claimsContextKey struct{}
+
+// claimsFromContext returns the claims of the token authorized for the request, or nil
+// if the method is open to every one
+func claimsFromContext(ctx context.Context) *Payload {
+	claims, _ := ctx.Value(claimsContextKey{}).(*Payload)
+	return claims
+}
+
+// authorizedServerStream is a server stream whose context has the claims of its token
+type authorizedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authorizedServerStream) Context() context.Context {
+	return stream.ctx
 }
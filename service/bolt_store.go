@@ -0,0 +1,817 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gobook/pb"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	laptopsBucket        = []byte("laptops")
+	deletedLaptopsBucket = []byte("deleted_laptops")
+	// laptopRevisionsBucket has a bucket per laptop id, with the versions keyed by revision
+	laptopRevisionsBucket = []byte("laptop_revisions")
+	laptopEventsBucket    = []byte("laptop_events")
+	usersBucket           = []byte("users")
+	ratingsBucket         = []byte("ratings")
+	imagesBucket          = []byte("images")
+)
+
+// BoltDB is an embedded key-value database file shared by the bolt stores.
+// Every value is stored protobuf encoded.
+type BoltDB struct {
+	db       *bolt.DB
+	notifier *changeNotifier
+}
+
+// OpenBoltDB opens the database file at path, creating it and its buckets if needed
+func OpenBoltDB(path string) (*BoltDB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		buckets := [][]byte{
+			laptopsBucket,
+			deletedLaptopsBucket,
+			laptopRevisionsBucket,
+			laptopEventsBucket,
+			usersBucket,
+			ratingsBucket,
+			imagesBucket,
+		}
+		for _, bucket := range buckets {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return fmt.Errorf("cannot create bucket %s: %w", bucket, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	boltDB := &BoltDB{
+		db:       db,
+		notifier: newChangeNotifier(),
+	}
+
+	return boltDB, nil
+}
+
+// Close closes the database file
+func (db *BoltDB) Close() error {
+	return db.db.Close()
+}
+
+func getProto(bucket *bolt.Bucket, key string, message proto.Message) (bool, error) {
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+
+	err := proto.Unmarshal(data, message)
+	if err != nil {
+		return false, fmt.Errorf("cannot unmarshal %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func putProto(bucket *bolt.Bucket, key string, message proto.Message) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s: %w", key, err)
+	}
+
+	return bucket.Put([]byte(key), data)
+}
+
+func sequenceKey(sequence uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, sequence)
+	return key
+}
+
+type BoltLaptopStore struct {
+	db *BoltDB
+}
+
+func NewBoltLaptopStore(db *BoltDB) LaptopStore {
+	return &BoltLaptopStore{
+		db: db,
+	}
+}
+
+func (store *BoltLaptopStore) Save(laptop *pb.Laptop) error {
+	return store.SaveAll([]*pb.Laptop{laptop})
+}
+
+func (store *BoltLaptopStore) SaveAll(laptops []*pb.Laptop) error {
+	return store.write(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(laptopsBucket)
+
+		for _, laptop := range laptops {
+			if bucket.Get([]byte(laptop.GetId())) != nil || tx.Bucket(deletedLaptopsBucket).Get([]byte(laptop.GetId())) != nil {
+				return ErrAlreadyExists
+			}
+
+			laptop.Revision = 1
+			err := putProto(bucket, laptop.GetId(), laptop)
+			if err != nil {
+				return err
+			}
+
+			err = putBoltLaptopEvent(tx, pb.LaptopEvent_CREATED, laptop)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (store *BoltLaptopStore) Find(id string) *pb.Laptop {
+	laptop := &pb.Laptop{}
+	ok := false
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ok, err = getProto(tx.Bucket(laptopsBucket), id, laptop)
+		return err
+	})
+	if err != nil || !ok {
+		return nil
+	}
+
+	return laptop
+}
+
+func (store *BoltLaptopStore) Update(laptop *pb.Laptop, expectedRevision uint64) error {
+	return store.write(func(tx *bolt.Tx) error {
+		laptops := tx.Bucket(laptopsBucket)
+
+		stored := &pb.Laptop{}
+		ok, err := getProto(laptops, laptop.GetId(), stored)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+
+		err = checkRevision(stored, expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		laptop.Revision = stored.Revision + 1
+		err = putProto(laptops, laptop.GetId(), laptop)
+		if err != nil {
+			return err
+		}
+
+		return putBoltLaptopEvent(tx, pb.LaptopEvent_UPDATED, laptop)
+	})
+}
+
+func (store *BoltLaptopStore) Delete(id string, expectedRevision uint64) error {
+	return store.write(func(tx *bolt.Tx) error {
+		laptops := tx.Bucket(laptopsBucket)
+
+		deleted := &pb.Laptop{}
+		ok, err := getProto(laptops, id, deleted)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+
+		err = checkRevision(deleted, expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		err = laptops.Delete([]byte(id))
+		if err != nil {
+			return err
+		}
+
+		deleted.Revision++
+		deleted.DeletedAt = timestamppb.Now()
+		err = putProto(tx.Bucket(deletedLaptopsBucket), id, deleted)
+		if err != nil {
+			return err
+		}
+
+		return putBoltLaptopEvent(tx, pb.LaptopEvent_DELETED, deleted)
+	})
+}
+
+func (store *BoltLaptopStore) FindDeleted(id string) *pb.Laptop {
+	laptop := &pb.Laptop{}
+	ok := false
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ok, err = getProto(tx.Bucket(deletedLaptopsBucket), id, laptop)
+		return err
+	})
+	if err != nil || !ok {
+		return nil
+	}
+
+	return laptop
+}
+
+func (store *BoltLaptopStore) ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	laptops := []*pb.Laptop{}
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deletedLaptopsBucket).ForEach(func(key, value []byte) error {
+			laptop := &pb.Laptop{}
+			err := proto.Unmarshal(value, laptop)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal laptop %s: %w", key, err)
+			}
+
+			laptops = append(laptops, laptop)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *BoltLaptopStore) Undelete(id string, expectedRevision uint64) error {
+	return store.write(func(tx *bolt.Tx) error {
+		deleted := tx.Bucket(deletedLaptopsBucket)
+
+		laptop := &pb.Laptop{}
+		ok, err := getProto(deleted, id, laptop)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+
+		err = checkRevision(laptop, expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		err = deleted.Delete([]byte(id))
+		if err != nil {
+			return err
+		}
+
+		laptop.Revision++
+		laptop.DeletedAt = nil
+		err = putProto(tx.Bucket(laptopsBucket), id, laptop)
+		if err != nil {
+			return err
+		}
+
+		return putBoltLaptopEvent(tx, pb.LaptopEvent_CREATED, laptop)
+	})
+}
+
+func (store *BoltLaptopStore) Purge(id string) error {
+	return store.write(func(tx *bolt.Tx) error {
+		deleted := tx.Bucket(deletedLaptopsBucket)
+		if deleted.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+
+		return deleted.Delete([]byte(id))
+	})
+}
+
+func (store *BoltLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	// Collect the matches first, so that the read transaction is not kept open while sending
+	laptops := []*pb.Laptop{}
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(laptopsBucket).ForEach(func(key, value []byte) error {
+			laptop := &pb.Laptop{}
+			err := proto.Unmarshal(value, laptop)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal laptop %s: %w", key, err)
+			}
+
+			if isQualified(filter, laptop) {
+				laptops = append(laptops, laptop)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *BoltLaptopStore) Watch(
+	ctx context.Context,
+	afterSequence uint64,
+	found func(event *pb.LaptopEvent) error,
+) error {
+	if afterSequence == 0 {
+		err := store.db.db.View(func(tx *bolt.Tx) error {
+			afterSequence = tx.Bucket(laptopEventsBucket).Sequence()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return watchLaptopEvents(ctx, afterSequence, store.db.notifier, store.eventsSince, found)
+}
+
+func (store *BoltLaptopStore) eventsSince(afterSequence uint64) ([]*pb.LaptopEvent, error) {
+	events := []*pb.LaptopEvent{}
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(laptopEventsBucket)
+		last := bucket.Sequence()
+
+		first := last + 1
+		cursor := bucket.Cursor()
+		if key, _ := cursor.First(); key != nil {
+			first = binary.BigEndian.Uint64(key)
+		}
+
+		if afterSequence+1 < first || afterSequence > last {
+			return ErrEventsExpired
+		}
+
+		for key, value := cursor.Seek(sequenceKey(afterSequence + 1)); key != nil; key, value = cursor.Next() {
+			event := &pb.LaptopEvent{}
+			err := proto.Unmarshal(value, event)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal event: %w", err)
+			}
+			events = append(events, event)
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+// write runs fn in a read-write transaction, then wakes up the watchers if it was committed
+func (store *BoltLaptopStore) write(fn func(tx *bolt.Tx) error) error {
+	err := store.db.db.Update(fn)
+	if err != nil {
+		return err
+	}
+
+	store.db.notifier.notify()
+	return nil
+}
+
+// putBoltLaptopEvent records the change and forgets the events too old to be resumed from
+func putBoltLaptopEvent(tx *bolt.Tx, eventType pb.LaptopEvent_Type, laptop *pb.Laptop) error {
+	bucket := tx.Bucket(laptopEventsBucket)
+
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return fmt.Errorf("cannot generate event sequence: %w", err)
+	}
+
+	event := &pb.LaptopEvent{
+		Sequence: sequence,
+		Type:     eventType,
+		Laptop:   laptop,
+		Time:     timestamppb.Now(),
+	}
+
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cannot marshal event: %w", err)
+	}
+
+	err = bucket.Put(sequenceKey(sequence), data)
+	if err != nil {
+		return err
+	}
+
+	if sequence <= laptopEventHistory {
+		return nil
+	}
+
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil && binary.BigEndian.Uint64(key) <= sequence-laptopEventHistory; key, _ = cursor.Next() {
+		err := cursor.Delete()
+		if err != nil {
+			return fmt.Errorf("cannot trim events: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type BoltUserStore struct {
+	db *BoltDB
+}
+
+func NewBoltUserStore(db *BoltDB) UserStore {
+	return &BoltUserStore{
+		db: db,
+	}
+}
+
+func (store *BoltUserStore) Save(user *User) error {
+	return store.db.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		if users.Get([]byte(user.Username)) != nil {
+			return ErrAlreadyExists
+		}
+
+		record := &pb.UserRecord{
+			Username:       user.Username,
+			HashedPassword: user.HasedPassword,
+			Role:           user.Role,
+		}
+
+		return putProto(users, user.Username, record)
+	})
+}
+
+func (store *BoltUserStore) Find(username string) (*User, error) {
+	record := &pb.UserRecord{}
+	ok := false
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ok, err = getProto(tx.Bucket(usersBucket), username, record)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("cannot found user")
+	}
+
+	user := &User{
+		Username:      record.GetUsername(),
+		HasedPassword: record.GetHashedPassword(),
+		Role:          record.GetRole(),
+	}
+
+	return user, nil
+}
+
+func (store *BoltUserStore) List(found func(user *User) error) error {
+	users := []*User{}
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(key, value []byte) error {
+			record := &pb.UserRecord{}
+			err := proto.Unmarshal(value, record)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal user %s: %w", key, err)
+			}
+
+			users = append(users, &User{
+				Username:      record.GetUsername(),
+				HasedPassword: record.GetHashedPassword(),
+				Role:          record.GetRole(),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		err := found(user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type BoltLaptopRevisionStore struct {
+	db *BoltDB
+}
+
+func NewBoltLaptopRevisionStore(db *BoltDB) LaptopRevisionStore {
+	return &BoltLaptopRevisionStore{
+		db: db,
+	}
+}
+
+func (store *BoltLaptopRevisionStore) Save(laptop *pb.Laptop) error {
+	return store.db.db.Update(func(tx *bolt.Tx) error {
+		revisions, err := tx.Bucket(laptopRevisionsBucket).CreateBucketIfNotExists([]byte(laptop.GetId()))
+		if err != nil {
+			return fmt.Errorf("cannot create revisions bucket: %w", err)
+		}
+
+		data, err := proto.Marshal(laptop)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+
+		return revisions.Put(sequenceKey(laptop.GetRevision()), data)
+	})
+}
+
+func (store *BoltLaptopRevisionStore) Find(id string, revision uint64) *pb.Laptop {
+	laptop := &pb.Laptop{}
+	ok := false
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		revisions := tx.Bucket(laptopRevisionsBucket).Bucket([]byte(id))
+		if revisions == nil {
+			return nil
+		}
+
+		data := revisions.Get(sequenceKey(revision))
+		if data == nil {
+			return nil
+		}
+
+		ok = true
+		return proto.Unmarshal(data, laptop)
+	})
+	if err != nil || !ok {
+		return nil
+	}
+
+	return laptop
+}
+
+func (store *BoltLaptopRevisionStore) List(id string, found func(laptop *pb.Laptop) error) error {
+	laptops := []*pb.Laptop{}
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		revisions := tx.Bucket(laptopRevisionsBucket).Bucket([]byte(id))
+		if revisions == nil {
+			return nil
+		}
+
+		// Big endian keys iterate by increasing revision
+		return revisions.ForEach(func(key, value []byte) error {
+			laptop := &pb.Laptop{}
+			err := proto.Unmarshal(value, laptop)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal revision %d: %w", binary.BigEndian.Uint64(key), err)
+			}
+
+			laptops = append(laptops, laptop)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *BoltLaptopRevisionStore) Delete(id string) error {
+	return store.db.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(laptopRevisionsBucket).DeleteBucket([]byte(id))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+type BoltRatingStore struct {
+	db *BoltDB
+}
+
+func NewBoltRatingStore(db *BoltDB) RatingStore {
+	return &BoltRatingStore{
+		db: db,
+	}
+}
+
+func (store *BoltRatingStore) Add(laptopID string, score float64) (*Rating, error) {
+	record := &pb.RatingRecord{}
+
+	err := store.db.db.Update(func(tx *bolt.Tx) error {
+		ratings := tx.Bucket(ratingsBucket)
+
+		_, err := getProto(ratings, laptopID, record)
+		if err != nil {
+			return err
+		}
+
+		record.LaptopId = laptopID
+		record.Count++
+		record.Sum += score
+
+		return putProto(ratings, laptopID, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rating := &Rating{
+		Count: record.GetCount(),
+		Sum:   record.GetSum(),
+	}
+
+	return rating, nil
+}
+
+func (store *BoltRatingStore) Find(laptopID string) *Rating {
+	record := &pb.RatingRecord{}
+	ok := false
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ok, err = getProto(tx.Bucket(ratingsBucket), laptopID, record)
+		return err
+	})
+	if err != nil || !ok {
+		return nil
+	}
+
+	rating := &Rating{
+		Count: record.GetCount(),
+		Sum:   record.GetSum(),
+	}
+
+	return rating
+}
+
+func (store *BoltRatingStore) Set(laptopID string, rating *Rating) error {
+	record := &pb.RatingRecord{
+		LaptopId: laptopID,
+		Count:    rating.Count,
+		Sum:      rating.Sum,
+	}
+
+	return store.db.db.Update(func(tx *bolt.Tx) error {
+		return putProto(tx.Bucket(ratingsBucket), laptopID, record)
+	})
+}
+
+func (store *BoltRatingStore) List(found func(laptopID string, rating *Rating) error) error {
+	records := []*pb.RatingRecord{}
+
+	err := store.db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ratingsBucket).ForEach(func(key, value []byte) error {
+			record := &pb.RatingRecord{}
+			err := proto.Unmarshal(value, record)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal rating %s: %w", key, err)
+			}
+
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		err := found(record.GetLaptopId(), &Rating{Count: record.GetCount(), Sum: record.GetSum()})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *BoltRatingStore) Delete(laptopID string) error {
+	return store.db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(ratingsBucket).Delete([]byte(laptopID))
+	})
+}
+
+type BoltImageIndex struct {
+	db *BoltDB
+}
+
+func NewBoltImageIndex(db *BoltDB) ImageIndex {
+	return &BoltImageIndex{
+		db: db,
+	}
+}
+
+func (index *BoltImageIndex) Save(imageID string, info *ImageInfo) error {
+	record := newImageRecord(imageID, info)
+
+	return index.db.db.Update(func(tx *bolt.Tx) error {
+		return putProto(tx.Bucket(imagesBucket), imageID, record)
+	})
+}
+
+func (index *BoltImageIndex) Find(imageID string) (*ImageInfo, error) {
+	record := &pb.ImageRecord{}
+	ok := false
+
+	err := index.db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ok, err = getProto(tx.Bucket(imagesBucket), imageID, record)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrImageNotFound
+	}
+
+	return imageInfoFromRecord(record), nil
+}
+
+func (index *BoltImageIndex) List(found func(imageID string, info *ImageInfo) error) error {
+	records := []*pb.ImageRecord{}
+
+	err := index.db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(imagesBucket).ForEach(func(key, value []byte) error {
+			record := &pb.ImageRecord{}
+			err := proto.Unmarshal(value, record)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal image %s: %w", key, err)
+			}
+
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		err := found(record.GetId(), imageInfoFromRecord(record))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (index *BoltImageIndex) Delete(imageID string) error {
+	return index.db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(imagesBucket).Delete([]byte(imageID))
+	})
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBoltStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gobook.db")
+
+	db, err := OpenBoltDB(path)
+	require.NoError(t, err)
+
+	laptopStore := NewBoltLaptopStore(db)
+	laptops := make(map[string]*pb.Laptop)
+	for i := 0; i < 20; i++ {
+		laptop := sample.NewLaptop()
+		err := laptopStore.Save(laptop)
+		require.NoError(t, err)
+		laptops[laptop.Id] = laptop
+	}
+
+	var laptop *pb.Laptop
+	for _, laptop = range laptops {
+		break
+	}
+
+	err = laptopStore.Save(laptop)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	updated := proto.Clone(laptop).(*pb.Laptop)
+	updated.PriceUsd = 999
+	err = laptopStore.Update(updated, 2)
+	require.ErrorIs(t, err, ErrRevisionMismatch)
+	err = laptopStore.Update(updated, 1)
+	require.NoError(t, err)
+	laptops[laptop.Id] = updated
+
+	err = laptopStore.Delete(sample.NewLaptop().Id, 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	user, err := NewUser("user1", "secret", "admin")
+	require.NoError(t, err)
+	userStore := NewBoltUserStore(db)
+	err = userStore.Save(user)
+	require.NoError(t, err)
+	err = userStore.Save(user)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	ratingStore := NewBoltRatingStore(db)
+	_, err = ratingStore.Add(laptop.Id, 4)
+	require.NoError(t, err)
+	rating, err := ratingStore.Add(laptop.Id, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), rating.Count)
+
+	imageFolder := t.TempDir()
+	imageStore := NewDiskImageStoreWithIndex(imageFolder, NewBoltImageIndex(db))
+	image := newTestImage(t, "jpeg", 3, 2)
+	imageID, err := imageStore.Save(laptop.Id, ".jpg", bytes.NewReader(image))
+	require.NoError(t, err)
+	variant := &ImageVariant{Name: "thumbnail", Type: ".jpg", MimeType: "image/jpeg", Width: 3, Height: 2}
+	require.NoError(t, imageStore.SaveVariant(imageID, variant, bytes.NewReader(image)))
+
+	err = db.Close()
+	require.NoError(t, err)
+
+	// Everything survives a restart
+	db, err = OpenBoltDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	laptopStore = NewBoltLaptopStore(db)
+	require.True(t, proto.Equal(updated, laptopStore.Find(laptop.Id)))
+
+	filter := &pb.Filter{
+		MaxPriceUsd: proto.Float64(3000),
+		MinCpuCores: proto.Uint32(4),
+	}
+	expected := make(map[string]bool)
+	for id, laptop := range laptops {
+		if isQualified(filter, laptop) {
+			expected[id] = true
+		}
+	}
+	result := make(map[string]bool)
+	err = laptopStore.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		result[laptop.Id] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected, result)
+
+	found, err := NewBoltUserStore(db).Find(user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user, found)
+	require.True(t, found.IsPasswordCorrect("secret"))
+
+	rating = NewBoltRatingStore(db).Find(laptop.Id)
+	require.Equal(t, &Rating{Count: 2, Sum: 9}, rating)
+
+	info, err := NewBoltImageIndex(db).Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, laptop.Id, info.LaptopID)
+	require.Equal(t, filepath.Join(imageFolder, imageID+".jpg"), info.Path)
+	require.Equal(t, int64(len(image)), info.Size)
+	require.Equal(t, "image/jpeg", info.MimeType)
+	require.Equal(t, 3, info.Width)
+	require.Len(t, info.Variants, 1)
+	require.Equal(t, "thumbnail", info.Variants[0].Name)
+	require.Equal(t, filepath.Join(imageFolder, imageID+"-thumbnail.jpg"), info.Variants[0].Path)
+	require.Equal(t, int64(len(image)), info.Variants[0].Size)
+	require.False(t, info.UploadedAt.IsZero())
+	_, err = NewBoltImageIndex(db).Find("unknown")
+	require.ErrorIs(t, err, ErrImageNotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*pb.LaptopEvent{}
+	err = laptopStore.Watch(ctx, 19, func(event *pb.LaptopEvent) error {
+		events = append(events, event)
+		if len(events) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, pb.LaptopEvent_CREATED, events[0].GetType())
+	require.Equal(t, pb.LaptopEvent_UPDATED, events[1].GetType())
+	require.Equal(t, uint64(21), events[1].GetSequence())
+}
+
+func TestBoltLaptopStoreTrash(t *testing.T) {
+	db, err := OpenBoltDB(filepath.Join(t.TempDir(), "gobook.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testLaptopStoreTrash(t, NewBoltLaptopStore(db))
+}
+
+func TestBoltLaptopStoreSaveAll(t *testing.T) {
+	db, err := OpenBoltDB(filepath.Join(t.TempDir(), "gobook.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testLaptopStoreSaveAll(t, NewBoltLaptopStore(db))
+}
+
+func TestBoltLaptopRevisionStore(t *testing.T) {
+	db, err := OpenBoltDB(filepath.Join(t.TempDir(), "gobook.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testLaptopRevisionStore(t, NewBoltLaptopRevisionStore(db))
+}
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gobook/pb"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// clusterApplyTimeout is how long a write waits for a leader to commit it
+	clusterApplyTimeout = 10 * time.Second
+	// leaderPollInterval is how often a node checks for a leader while there is none
+	leaderPollInterval = 100 * time.Millisecond
+)
+
+// ClusterConfig describes a node of the cluster
+type ClusterConfig struct {
+	NodeID string
+	// Peers maps the id of every node, this one included, to its cluster address
+	Peers map[string]string
+	// DataDir keeps the raft log and snapshots, an empty one keeps them in memory
+	DataDir string
+	// TLS authenticates the nodes to each other, it is required
+	TLS ClusterTLS
+}
+
+// Cluster replicates the laptop, user and rating stores to every node with raft.
+// Writes are forwarded to the leader, reads are served by the local copy of the node,
+// which may be behind the leader by the replication delay.
+type Cluster struct {
+	config   ClusterConfig
+	raft     *raft.Raft
+	fsm      *clusterFSM
+	listener *clusterListener
+	server   *grpc.Server
+	closers  []func() error
+
+	mutex sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// ParseClusterPeers parses a comma separated list of id=address peers
+func ParseClusterPeers(peers string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	for _, peer := range strings.Split(peers, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+
+		id, address, ok := strings.Cut(peer, "=")
+		if !ok || id == "" || address == "" {
+			return nil, fmt.Errorf("invalid cluster peer %q, expected id=address", peer)
+		}
+		if result[id] != "" {
+			return nil, fmt.Errorf("duplicate cluster peer %q", id)
+		}
+
+		result[id] = address
+	}
+
+	return result, nil
+}
+
+// NewCluster starts the node, listening for the other nodes on its address in the peers.
+// A new cluster is bootstrapped with every peer as a voter.
+func NewCluster(config ClusterConfig) (*Cluster, error) {
+	address := config.Peers[config.NodeID]
+	if address == "" {
+		return nil, fmt.Errorf("node %q is not one of the cluster peers", config.NodeID)
+	}
+
+	err := config.TLS.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &Cluster{
+		config: config,
+		fsm:    newClusterFSM(),
+		conns:  make(map[string]*grpc.ClientConn),
+	}
+
+	err = cluster.start(address)
+	if err != nil {
+		cluster.Close()
+		return nil, err
+	}
+
+	return cluster, nil
+}
+
+func (cluster *Cluster) start(address string) error {
+	listener, err := listenCluster(address, cluster.config.TLS.serverConfig())
+	if err != nil {
+		return fmt.Errorf("cannot listen to cluster address: %w", err)
+	}
+	cluster.listener = listener
+	cluster.closers = append(cluster.closers, listener.Close)
+
+	cluster.server = grpc.NewServer()
+	pb.RegisterClusterServiceServer(cluster.server, &clusterServer{cluster: cluster})
+	go cluster.server.Serve(listener.cluster)
+
+	var logStore raft.LogStore
+	var stableStore raft.StableStore
+	var snapshotStore raft.SnapshotStore
+
+	if cluster.config.DataDir == "" {
+		store := raft.NewInmemStore()
+		logStore, stableStore = store, store
+		snapshotStore = raft.NewInmemSnapshotStore()
+	} else {
+		err := os.MkdirAll(cluster.config.DataDir, 0700)
+		if err != nil {
+			return fmt.Errorf("cannot create cluster data dir: %w", err)
+		}
+
+		store, err := raftboltdb.NewBoltStore(filepath.Join(cluster.config.DataDir, "raft.db"))
+		if err != nil {
+			return fmt.Errorf("cannot open raft log: %w", err)
+		}
+		cluster.closers = append(cluster.closers, store.Close)
+		logStore, stableStore = store, store
+
+		snapshotStore, err = raft.NewFileSnapshotStore(cluster.config.DataDir, 2, os.Stderr)
+		if err != nil {
+			return fmt.Errorf("cannot create snapshot store: %w", err)
+		}
+	}
+
+	streamLayer := raftStreamLayer{connectionQueue: listener.raft, tls: cluster.config.TLS.clientConfig()}
+	transport := raft.NewNetworkTransport(streamLayer, 3, 10*time.Second, os.Stderr)
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(cluster.config.NodeID)
+	raftConfig.LogLevel = "INFO"
+
+	cluster.raft, err = raft.NewRaft(raftConfig, cluster.fsm, logStore, stableStore, snapshotStore, transport)
+	if err != nil {
+		return fmt.Errorf("cannot start raft: %w", err)
+	}
+
+	configuration := raft.Configuration{}
+	for id, peer := range cluster.config.Peers {
+		configuration.Servers = append(configuration.Servers, raft.Server{
+			ID:      raft.ServerID(id),
+			Address: raft.ServerAddress(peer),
+		})
+	}
+	sort.Slice(configuration.Servers, func(i, j int) bool {
+		return configuration.Servers[i].ID < configuration.Servers[j].ID
+	})
+
+	// Every node bootstraps the same configuration, a restarted node already has one
+	err = cluster.raft.BootstrapCluster(configuration).Error()
+	if err != nil && !errors.Is(err, raft.ErrCantBootstrap) {
+		return fmt.Errorf("cannot bootstrap cluster: %w", err)
+	}
+
+	return nil
+}
+
+// Close stops the node
+func (cluster *Cluster) Close() error {
+	if cluster.raft != nil {
+		cluster.raft.Shutdown().Error()
+	}
+	if cluster.server != nil {
+		cluster.server.Stop()
+	}
+
+	cluster.mutex.Lock()
+	for _, conn := range cluster.conns {
+		conn.Close()
+	}
+	cluster.mutex.Unlock()
+
+	var closeErr error
+	for i := len(cluster.closers) - 1; i >= 0; i-- {
+		err := cluster.closers[i]()
+		if err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+// WaitForLeader returns once the cluster has elected a leader, or the context is done
+func (cluster *Cluster) WaitForLeader(ctx context.Context) error {
+	for {
+		address, _ := cluster.raft.LeaderWithID()
+		if address != "" {
+			return nil
+		}
+
+		select {
+		case <-time.After(leaderPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// IsLeader tells if the node is the current leader of the cluster
+func (cluster *Cluster) IsLeader() bool {
+	return cluster.raft.State() == raft.Leader
+}
+
+func (cluster *Cluster) LaptopStore() LaptopStore {
+	return &replicatedLaptopStore{cluster: cluster}
+}
+
+func (cluster *Cluster) UserStore() UserStore {
+	return &replicatedUserStore{cluster: cluster}
+}
+
+func (cluster *Cluster) RatingStore() RatingStore {
+	return &replicatedRatingStore{cluster: cluster}
+}
+
+func (cluster *Cluster) LaptopRevisionStore() LaptopRevisionStore {
+	return &replicatedLaptopRevisionStore{cluster: cluster}
+}
+
+// IdempotencyStore returns a store remembering the responses for ttl on every node
+func (cluster *Cluster) IdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return &replicatedIdempotencyStore{cluster: cluster, ttl: ttl}
+}
+
+// apply commits the command through the leader, and returns once this node has applied it,
+// so the node reads its own writes. A write failing when the leader changes may have been
+// committed anyway, so the command gets an id and the FSM applies its retries only once.
+func (cluster *Cluster) apply(command *pb.ClusterCommand) (*pb.ClusterResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterApplyTimeout)
+	defer cancel()
+
+	commandID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate command id: %w", err)
+	}
+	command.Id = commandID.String()
+
+	for {
+		var result *pb.ClusterResult
+		var err error
+
+		if cluster.IsLeader() {
+			result, err = cluster.applyLocal(command)
+		} else if address, _ := cluster.raft.LeaderWithID(); address != "" {
+			result, err = cluster.forward(ctx, string(address), command)
+		} else {
+			err = raft.ErrNotLeader
+		}
+
+		if err == nil {
+			err = cluster.fsm.waitApplied(ctx, result.GetIndex())
+			if err != nil {
+				return nil, fmt.Errorf("cannot wait for the command to be applied: %w", err)
+			}
+			return result, nil
+		}
+
+		if !isLeaderChange(err) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(leaderPollInterval):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cannot reach the cluster leader: %w", err)
+		}
+	}
+}
+
+// applyLocal commits the command, the node must be the leader
+func (cluster *Cluster) applyLocal(command *pb.ClusterCommand) (*pb.ClusterResult, error) {
+	data, err := proto.Marshal(command)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal command: %w", err)
+	}
+
+	future := cluster.raft.Apply(data, clusterApplyTimeout)
+	err = future.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	response := future.Response().(*clusterApplyResult)
+	return response.result, response.err
+}
+
+func (cluster *Cluster) forward(ctx context.Context, address string, command *pb.ClusterCommand) (*pb.ClusterResult, error) {
+	conn, err := cluster.conn(address)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := pb.NewClusterServiceClient(conn).Apply(ctx, &pb.ApplyRequest{Command: command})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	return res.GetResult(), nil
+}
+
+func (cluster *Cluster) conn(address string) (*grpc.ClientConn, error) {
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+
+	conn := cluster.conns[address]
+	if conn != nil {
+		return conn, nil
+	}
+
+	// The connection is already authenticated with TLS by dialCluster
+	tlsConfig := cluster.config.TLS.clientConfig()
+	conn, err := grpc.Dial(
+		address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return dialCluster(ctx, address, clusterConnection, tlsConfig)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial leader %s: %w", address, err)
+	}
+
+	cluster.conns[address] = conn
+	return conn, nil
+}
+
+// isLeaderChange tells if a write failed because there is no leader, or it moved
+func isLeaderChange(err error) bool {
+	return errors.Is(err, raft.ErrNotLeader) ||
+		errors.Is(err, raft.ErrLeadershipLost) ||
+		errors.Is(err, raft.ErrLeadershipTransferInProgress) ||
+		status.Code(err) == codes.Unavailable
+}
+
+// remoteStoreError is a store error returned by the leader
+type remoteStoreError struct {
+	err     error
+	message string
+}
+
+func (err *remoteStoreError) Error() string {
+	return err.message
+}
+
+func (err *remoteStoreError) Unwrap() error {
+	return err.err
+}
+
+// clusterError turns the status returned by the leader back into a store error
+func clusterError(err error) error {
+	st := status.Convert(err)
+
+	switch st.Code() {
+	case codes.NotFound:
+		return &remoteStoreError{err: ErrNotFound, message: st.Message()}
+	case codes.AlreadyExists:
+		return &remoteStoreError{err: ErrAlreadyExists, message: st.Message()}
+	case codes.Aborted:
+		return &remoteStoreError{err: ErrRevisionMismatch, message: st.Message()}
+	case codes.FailedPrecondition:
+		return raft.ErrNotLeader
+	default:
+		return err
+	}
+}
+
+type clusterServer struct {
+	pb.UnimplementedClusterServiceServer
+	cluster *Cluster
+}
+
+func (server *clusterServer) Apply(ctx context.Context, req *pb.ApplyRequest) (*pb.ApplyResponse, error) {
+	if !server.cluster.IsLeader() {
+		return nil, status.Errorf(codes.FailedPrecondition, "node %s is not the leader", server.cluster.config.NodeID)
+	}
+
+	result, err := server.cluster.applyLocal(req.GetCommand())
+	if err != nil {
+		if isLeaderChange(err) {
+			return nil, status.Errorf(codes.FailedPrecondition, "cannot apply command: %v", err)
+		}
+		log.Printf("cannot apply forwarded command: %v", err)
+		return nil, status.Error(storeErrorCode(err), err.Error())
+	}
+
+	return &pb.ApplyResponse{Result: result}, nil
+}
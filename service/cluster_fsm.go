@@ -0,0 +1,458 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gobook/pb"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/raft"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxAppliedCommands is how many applied command ids the FSM remembers to recognize retries,
+// far more than the commands a cluster applies while a write is retried
+const maxAppliedCommands = 10000
+
+// clusterFSM applies the commands of the raft log to in-memory stores,
+// so every node of the cluster ends up with the same stores
+type clusterFSM struct {
+	mutex   sync.RWMutex
+	laptops *InMemoryLaptopStore
+	users   *InMemoryUserStore
+	ratings *InMemoryRatingStore
+	// revisions keeps the laptop versions
+	revisions *InMemoryLaptopRevisionStore
+	// idempotency keeps the idempotency keys of the requests, its ttl only paces the sweeps
+	// since the commands carry the expiry times
+	idempotency *InMemoryIdempotencyStore
+	// applied is the index of the last command applied, notifier wakes up waitApplied
+	applied  uint64
+	notifier *changeNotifier
+	// commands are the results of the last commands applied with an id, oldest first in commandIDs
+	commands   map[string]*pb.ClusterResult
+	commandIDs []string
+}
+
+// clusterApplyResult is what clusterFSM.Apply returns to the raft future
+type clusterApplyResult struct {
+	result *pb.ClusterResult
+	err    error
+}
+
+func newClusterFSM() *clusterFSM {
+	return &clusterFSM{
+		laptops:     NewInMemoryLaptopStore().(*InMemoryLaptopStore),
+		users:       NewInMemoryUserStore().(*InMemoryUserStore),
+		ratings:     NewInMemoryRatingStore().(*InMemoryRatingStore),
+		revisions:   NewInMemoryLaptopRevisionStore().(*InMemoryLaptopRevisionStore),
+		idempotency: NewInMemoryIdempotencyStore(DefaultIdempotencyTTL).(*InMemoryIdempotencyStore),
+		notifier:    newChangeNotifier(),
+		commands:    make(map[string]*pb.ClusterResult),
+	}
+}
+
+func (fsm *clusterFSM) laptopStore() *InMemoryLaptopStore {
+	fsm.mutex.RLock()
+	defer fsm.mutex.RUnlock()
+
+	return fsm.laptops
+}
+
+func (fsm *clusterFSM) userStore() UserStore {
+	fsm.mutex.RLock()
+	defer fsm.mutex.RUnlock()
+
+	return fsm.users
+}
+
+func (fsm *clusterFSM) ratingStore() RatingStore {
+	fsm.mutex.RLock()
+	defer fsm.mutex.RUnlock()
+
+	return fsm.ratings
+}
+
+func (fsm *clusterFSM) laptopRevisionStore() LaptopRevisionStore {
+	fsm.mutex.RLock()
+	defer fsm.mutex.RUnlock()
+
+	return fsm.revisions
+}
+
+func (fsm *clusterFSM) idempotencyStore() *InMemoryIdempotencyStore {
+	fsm.mutex.RLock()
+	defer fsm.mutex.RUnlock()
+
+	return fsm.idempotency
+}
+
+func (fsm *clusterFSM) Apply(log *raft.Log) interface{} {
+	command := &pb.ClusterCommand{}
+	err := proto.Unmarshal(log.Data, command)
+	if err != nil {
+		return &clusterApplyResult{err: fmt.Errorf("cannot unmarshal command: %w", err)}
+	}
+
+	// A retried command already applied returns its first result instead of being applied again
+	fsm.mutex.RLock()
+	result := fsm.commands[command.GetId()]
+	fsm.mutex.RUnlock()
+	if result != nil {
+		fsm.mutex.Lock()
+		fsm.applied = log.Index
+		fsm.mutex.Unlock()
+		fsm.notifier.notify()
+
+		return &clusterApplyResult{result: proto.Clone(result).(*pb.ClusterResult)}
+	}
+
+	result, err = fsm.apply(command)
+	if result != nil {
+		result.Index = log.Index
+	}
+
+	fsm.mutex.Lock()
+	fsm.applied = log.Index
+	if err == nil && command.GetId() != "" {
+		fsm.rememberCommand(command.GetId(), proto.Clone(result).(*pb.ClusterResult))
+	}
+	fsm.mutex.Unlock()
+	fsm.notifier.notify()
+
+	return &clusterApplyResult{result: result, err: err}
+}
+
+func (fsm *clusterFSM) apply(command *pb.ClusterCommand) (*pb.ClusterResult, error) {
+	switch command := command.GetCommand().(type) {
+	case *pb.ClusterCommand_SaveLaptop:
+		laptop := command.SaveLaptop.GetLaptop()
+		err := fsm.laptopStore().Save(laptop)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{Revision: laptop.GetRevision()}, nil
+	case *pb.ClusterCommand_SaveLaptops:
+		err := fsm.laptopStore().SaveAll(command.SaveLaptops.GetLaptops())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_UpdateLaptop:
+		laptop := command.UpdateLaptop.GetLaptop()
+		err := fsm.laptopStore().Update(laptop, command.UpdateLaptop.GetExpectedRevision())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{Revision: laptop.GetRevision()}, nil
+	case *pb.ClusterCommand_DeleteLaptop:
+		deletedAt := command.DeleteLaptop.GetDeletedAt()
+		if deletedAt == nil {
+			// Commands logged before the trash existed deleted for good, so they are due for purge
+			deletedAt = &timestamppb.Timestamp{}
+		}
+		err := fsm.laptopStore().trash(command.DeleteLaptop.GetId(), command.DeleteLaptop.GetExpectedRevision(), deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_UndeleteLaptop:
+		id := command.UndeleteLaptop.GetId()
+		err := fsm.laptopStore().Undelete(id, command.UndeleteLaptop.GetExpectedRevision())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{Revision: fsm.laptopStore().Find(id).GetRevision()}, nil
+	case *pb.ClusterCommand_PurgeLaptop:
+		err := fsm.laptopStore().Purge(command.PurgeLaptop.GetId())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_SaveUser:
+		record := command.SaveUser.GetUser()
+		err := fsm.userStore().Save(&User{
+			Username:      record.GetUsername(),
+			HasedPassword: record.GetHashedPassword(),
+			Role:          record.GetRole(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_AddRating:
+		laptopID := command.AddRating.GetLaptopId()
+		rating, err := fsm.ratingStore().Add(laptopID, command.AddRating.GetScore())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{
+			Rating: &pb.RatingRecord{
+				LaptopId: laptopID,
+				Count:    rating.Count,
+				Sum:      rating.Sum,
+			},
+		}, nil
+	case *pb.ClusterCommand_SetRating:
+		record := command.SetRating.GetRating()
+		err := fsm.ratingStore().Set(record.GetLaptopId(), &Rating{
+			Count: record.GetCount(),
+			Sum:   record.GetSum(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{Rating: record}, nil
+	case *pb.ClusterCommand_DeleteRating:
+		err := fsm.ratingStore().Delete(command.DeleteRating.GetLaptopId())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_SaveLaptopRevision:
+		err := fsm.laptopRevisionStore().Save(command.SaveLaptopRevision.GetLaptop())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_DeleteLaptopRevisions:
+		err := fsm.laptopRevisionStore().Delete(command.DeleteLaptopRevisions.GetLaptopId())
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_BeginIdempotentRequest:
+		begin := command.BeginIdempotentRequest
+		saved, err := fsm.idempotencyStore().begin(
+			begin.GetKey(),
+			begin.GetFingerprint(),
+			begin.GetNow().AsTime(),
+			begin.GetExpiresAt().AsTime(),
+		)
+		// The outcome is a result rather than an error, so it is the same on a forwarded command
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyReused):
+			return &pb.ClusterResult{Idempotency: pb.IdempotencyOutcome_IDEMPOTENCY_KEY_REUSED}, nil
+		case errors.Is(err, ErrIdempotencyKeyInUse):
+			return &pb.ClusterResult{Idempotency: pb.IdempotencyOutcome_IDEMPOTENCY_KEY_IN_USE}, nil
+		case err != nil:
+			return nil, err
+		case saved != nil:
+			return &pb.ClusterResult{
+				Idempotency:        pb.IdempotencyOutcome_IDEMPOTENCY_REPLAYED,
+				IdempotentResponse: saved,
+			}, nil
+		}
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_FinishIdempotentRequest:
+		finish := command.FinishIdempotentRequest
+		fsm.idempotencyStore().finish(finish.GetKey(), finish.GetResponse(), finish.GetExpiresAt().AsTime())
+		return &pb.ClusterResult{}, nil
+	case *pb.ClusterCommand_CancelIdempotentRequest:
+		fsm.idempotencyStore().Cancel(command.CancelIdempotentRequest.GetKey())
+		return &pb.ClusterResult{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cluster command %T", command)
+	}
+}
+
+// rememberCommand keeps the result of the command, forgetting the oldest ones past maxAppliedCommands.
+// The caller must hold the lock.
+func (fsm *clusterFSM) rememberCommand(id string, result *pb.ClusterResult) {
+	fsm.commands[id] = result
+	fsm.commandIDs = append(fsm.commandIDs, id)
+
+	for len(fsm.commandIDs) > maxAppliedCommands {
+		delete(fsm.commands, fsm.commandIDs[0])
+		fsm.commandIDs = fsm.commandIDs[1:]
+	}
+}
+
+// waitApplied returns once the command at index is applied, or the context is done
+func (fsm *clusterFSM) waitApplied(ctx context.Context, index uint64) error {
+	for {
+		changed := fsm.notifier.wait()
+
+		fsm.mutex.RLock()
+		applied := fsm.applied
+		fsm.mutex.RUnlock()
+
+		if applied >= index {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Snapshot copies the stores. Raft does not call it concurrently with Apply.
+func (fsm *clusterFSM) Snapshot() (raft.FSMSnapshot, error) {
+	fsm.mutex.RLock()
+	defer fsm.mutex.RUnlock()
+
+	snapshot := &pb.ClusterSnapshot{
+		Index:               fsm.applied,
+		LaptopEventSequence: fsm.laptops.events.current(),
+	}
+
+	addLaptop := func(laptop *pb.Laptop) error {
+		snapshot.Laptops = append(snapshot.Laptops, laptop)
+		return nil
+	}
+
+	err := fsm.laptops.Search(context.Background(), &pb.Filter{}, addLaptop)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fsm.laptops.ListDeleted(context.Background(), addLaptop)
+	if err != nil {
+		return nil, err
+	}
+
+	fsm.users.mutex.RLock()
+	for _, user := range fsm.users.users {
+		snapshot.Users = append(snapshot.Users, &pb.UserRecord{
+			Username:       user.Username,
+			HashedPassword: user.HasedPassword,
+			Role:           user.Role,
+		})
+	}
+	fsm.users.mutex.RUnlock()
+
+	fsm.ratings.mutex.RLock()
+	for laptopID, rating := range fsm.ratings.rating {
+		snapshot.Ratings = append(snapshot.Ratings, &pb.RatingRecord{
+			LaptopId: laptopID,
+			Count:    rating.Count,
+			Sum:      rating.Sum,
+		})
+	}
+	fsm.ratings.mutex.RUnlock()
+
+	snapshot.LaptopRevisions = fsm.revisions.all()
+
+	fsm.idempotency.mutex.Lock()
+	for key, record := range fsm.idempotency.records {
+		snapshot.IdempotencyRecords = append(snapshot.IdempotencyRecords, &pb.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: record.fingerprint,
+			Finished:    record.response != nil,
+			Response:    record.response,
+			ExpiresAt:   timestamppb.New(record.expiresAt),
+		})
+	}
+	fsm.idempotency.mutex.Unlock()
+
+	for _, id := range fsm.commandIDs {
+		snapshot.AppliedCommands = append(snapshot.AppliedCommands, &pb.AppliedCommand{
+			Id:     id,
+			Result: fsm.commands[id],
+		})
+	}
+
+	return &clusterFSMSnapshot{snapshot: snapshot}, nil
+}
+
+// Restore replaces the stores with the ones of the snapshot, the laptops in place
+func (fsm *clusterFSM) Restore(reader io.ReadCloser) error {
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("cannot read snapshot: %w", err)
+	}
+
+	snapshot := &pb.ClusterSnapshot{}
+	err = proto.Unmarshal(data, snapshot)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal snapshot: %w", err)
+	}
+
+	users := NewInMemoryUserStore().(*InMemoryUserStore)
+	for _, record := range snapshot.GetUsers() {
+		users.users[record.GetUsername()] = &User{
+			Username:      record.GetUsername(),
+			HasedPassword: record.GetHashedPassword(),
+			Role:          record.GetRole(),
+		}
+	}
+
+	ratings := NewInMemoryRatingStore().(*InMemoryRatingStore)
+	for _, record := range snapshot.GetRatings() {
+		ratings.rating[record.GetLaptopId()] = &Rating{
+			Count: record.GetCount(),
+			Sum:   record.GetSum(),
+		}
+	}
+
+	revisions := NewInMemoryLaptopRevisionStore().(*InMemoryLaptopRevisionStore)
+	for _, laptop := range snapshot.GetLaptopRevisions() {
+		revisions.Save(laptop)
+	}
+
+	idempotency := NewInMemoryIdempotencyStore(DefaultIdempotencyTTL).(*InMemoryIdempotencyStore)
+	for _, record := range snapshot.GetIdempotencyRecords() {
+		var response []byte
+		if record.GetFinished() {
+			response = append([]byte{}, record.GetResponse()...)
+		}
+		idempotency.records[record.GetKey()] = &idempotencyRecord{
+			fingerprint: record.GetFingerprint(),
+			response:    response,
+			expiresAt:   record.GetExpiresAt().AsTime(),
+		}
+	}
+
+	commands := make(map[string]*pb.ClusterResult)
+	commandIDs := []string{}
+	for _, command := range snapshot.GetAppliedCommands() {
+		commands[command.GetId()] = command.GetResult()
+		commandIDs = append(commandIDs, command.GetId())
+	}
+
+	// The laptops are restored into the same store, so its watchers are told if they missed events
+	fsm.laptopStore().restore(snapshot.GetLaptops(), snapshot.GetLaptopEventSequence())
+
+	fsm.mutex.Lock()
+	fsm.users = users
+	fsm.ratings = ratings
+	fsm.revisions = revisions
+	fsm.idempotency = idempotency
+	fsm.applied = snapshot.GetIndex()
+	fsm.commands = commands
+	fsm.commandIDs = commandIDs
+	fsm.mutex.Unlock()
+	fsm.notifier.notify()
+
+	return nil
+}
+
+type clusterFSMSnapshot struct {
+	snapshot *pb.ClusterSnapshot
+}
+
+func (snapshot *clusterFSMSnapshot) Persist(sink raft.SnapshotSink) error {
+	data, err := proto.Marshal(snapshot.snapshot)
+	if err != nil {
+		sink.Cancel()
+		return fmt.Errorf("cannot marshal snapshot: %w", err)
+	}
+
+	_, err = sink.Write(data)
+	if err != nil {
+		sink.Cancel()
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+
+	return sink.Close()
+}
+
+func (snapshot *clusterFSMSnapshot) Release() {}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// The first byte sent on a cluster connection tells which protocol it carries,
+// so raft and the cluster service share the address of the node
+const (
+	raftConnection    byte = 'R'
+	clusterConnection byte = 'C'
+)
+
+// connectionTimeout is how long an accepted connection has to send its protocol byte
+const connectionTimeout = 5 * time.Second
+
+var errListenerClosed = errors.New("cluster listener closed")
+
+// ClusterTLS authenticates the nodes of a cluster to each other with mutual TLS,
+// on the connections of both raft and the cluster service
+type ClusterTLS struct {
+	// Certificate is presented by the node to its peers
+	Certificate tls.Certificate
+	// CAs are the authorities signing the certificates of the nodes
+	CAs *x509.CertPool
+	// PeerName is the name the certificate of every node must be valid for,
+	// so that certificates of the same CAs issued to clients are rejected
+	PeerName string
+}
+
+func (config ClusterTLS) validate() error {
+	if len(config.Certificate.Certificate) == 0 || config.CAs == nil || config.PeerName == "" {
+		return fmt.Errorf("cluster TLS needs a certificate, CAs and a peer name")
+	}
+
+	return nil
+}
+
+// serverConfig accepts the connections of the nodes presenting a certificate for the peer name
+func (config ClusterTLS) serverConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{config.Certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    config.CAs,
+		MinVersion:   tls.VersionTLS12,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("cluster peer has no certificate")
+			}
+			return state.PeerCertificates[0].VerifyHostname(config.PeerName)
+		},
+	}
+}
+
+// clientConfig connects to the nodes presenting a certificate for the peer name
+func (config ClusterTLS) clientConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{config.Certificate},
+		RootCAs:      config.CAs,
+		ServerName:   config.PeerName,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// clusterListener accepts the TLS connections of the cluster address and hands them
+// to the raft transport or the cluster service by their first byte
+type clusterListener struct {
+	listener net.Listener
+	tls      *tls.Config
+	raft     *connectionQueue
+	cluster  *connectionQueue
+}
+
+func listenCluster(address string, tlsConfig *tls.Config) (*clusterListener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := &clusterListener{
+		listener: listener,
+		tls:      tlsConfig,
+	}
+	mux.raft = newConnectionQueue(mux)
+	mux.cluster = newConnectionQueue(mux)
+
+	go mux.serve()
+	return mux, nil
+}
+
+func (mux *clusterListener) serve() {
+	for {
+		conn, err := mux.listener.Accept()
+		if err != nil {
+			mux.raft.close()
+			mux.cluster.close()
+			return
+		}
+
+		go mux.dispatch(conn)
+	}
+}
+
+func (mux *clusterListener) dispatch(conn net.Conn) {
+	protocol := make([]byte, 1)
+
+	// The handshake authenticating the peer happens on the first read
+	conn = tls.Server(conn, mux.tls)
+	conn.SetReadDeadline(time.Now().Add(connectionTimeout))
+	_, err := conn.Read(protocol)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	switch protocol[0] {
+	case raftConnection:
+		mux.raft.push(conn)
+	case clusterConnection:
+		mux.cluster.push(conn)
+	default:
+		conn.Close()
+	}
+}
+
+func (mux *clusterListener) Close() error {
+	return mux.listener.Close()
+}
+
+// dialCluster connects with TLS to the cluster address of a node for the given protocol
+func dialCluster(ctx context.Context, address string, protocol byte, tlsConfig *tls.Config) (net.Conn, error) {
+	dialer := &tls.Dialer{Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Write([]byte{protocol})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// connectionQueue is the net.Listener of one protocol of a clusterListener
+type connectionQueue struct {
+	mux       *clusterListener
+	conns     chan net.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newConnectionQueue(mux *clusterListener) *connectionQueue {
+	return &connectionQueue{
+		mux:    mux,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (queue *connectionQueue) push(conn net.Conn) {
+	select {
+	case queue.conns <- conn:
+	case <-queue.closed:
+		conn.Close()
+	}
+}
+
+func (queue *connectionQueue) close() {
+	queue.closeOnce.Do(func() {
+		close(queue.closed)
+	})
+}
+
+func (queue *connectionQueue) Accept() (net.Conn, error) {
+	select {
+	case conn := <-queue.conns:
+		return conn, nil
+	case <-queue.closed:
+		return nil, errListenerClosed
+	}
+}
+
+// Close only stops this protocol, the listener is closed with the clusterListener
+func (queue *connectionQueue) Close() error {
+	queue.close()
+	return nil
+}
+
+func (queue *connectionQueue) Addr() net.Addr {
+	return queue.mux.listener.Addr()
+}
+
+// raftStreamLayer is the transport of raft over a clusterListener
+type raftStreamLayer struct {
+	*connectionQueue
+	tls *tls.Config
+}
+
+func (layer raftStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return dialCluster(ctx, string(address), raftConnection, layer.tls)
+}
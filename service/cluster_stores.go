@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"log"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// replicatedLaptopStore writes laptops through the raft log of the cluster
+type replicatedLaptopStore struct {
+	cluster *Cluster
+}
+
+func (store *replicatedLaptopStore) Save(laptop *pb.Laptop) error {
+	result, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_SaveLaptop{
+			SaveLaptop: &pb.SaveLaptopCommand{Laptop: laptop},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	laptop.Revision = result.GetRevision()
+	return nil
+}
+
+func (store *replicatedLaptopStore) SaveAll(laptops []*pb.Laptop) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_SaveLaptops{
+			SaveLaptops: &pb.SaveLaptopsCommand{Laptops: laptops},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		laptop.Revision = 1
+	}
+	return nil
+}
+
+func (store *replicatedLaptopStore) Find(id string) *pb.Laptop {
+	return store.cluster.fsm.laptopStore().Find(id)
+}
+
+func (store *replicatedLaptopStore) Update(laptop *pb.Laptop, expectedRevision uint64) error {
+	result, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_UpdateLaptop{
+			UpdateLaptop: &pb.UpdateLaptopCommand{
+				Laptop:           laptop,
+				ExpectedRevision: expectedRevision,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	laptop.Revision = result.GetRevision()
+	return nil
+}
+
+func (store *replicatedLaptopStore) Delete(id string, expectedRevision uint64) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_DeleteLaptop{
+			DeleteLaptop: &pb.DeleteLaptopCommand{
+				Id:               id,
+				ExpectedRevision: expectedRevision,
+				DeletedAt:        timestamppb.Now(),
+			},
+		},
+	})
+	return err
+}
+
+func (store *replicatedLaptopStore) FindDeleted(id string) *pb.Laptop {
+	return store.cluster.fsm.laptopStore().FindDeleted(id)
+}
+
+func (store *replicatedLaptopStore) ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	return store.cluster.fsm.laptopStore().ListDeleted(ctx, found)
+}
+
+func (store *replicatedLaptopStore) Undelete(id string, expectedRevision uint64) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_UndeleteLaptop{
+			UndeleteLaptop: &pb.UndeleteLaptopCommand{
+				Id:               id,
+				ExpectedRevision: expectedRevision,
+			},
+		},
+	})
+	return err
+}
+
+func (store *replicatedLaptopStore) Purge(id string) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_PurgeLaptop{
+			PurgeLaptop: &pb.PurgeLaptopCommand{Id: id},
+		},
+	})
+	return err
+}
+
+func (store *replicatedLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.cluster.fsm.laptopStore().Search(ctx, filter, found)
+}
+
+// Watch follows the changes applied by this node. Every node applies the same log and
+// snapshots carry the event sequence, so a watcher can resume on any node, even after a restart.
+func (store *replicatedLaptopStore) Watch(
+	ctx context.Context,
+	afterSequence uint64,
+	found func(event *pb.LaptopEvent) error,
+) error {
+	return store.cluster.fsm.laptopStore().Watch(ctx, afterSequence, found)
+}
+
+// replicatedUserStore writes users through the raft log of the cluster
+type replicatedUserStore struct {
+	cluster *Cluster
+}
+
+func (store *replicatedUserStore) Save(user *User) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_SaveUser{
+			SaveUser: &pb.SaveUserCommand{
+				User: &pb.UserRecord{
+					Username:       user.Username,
+					HashedPassword: user.HasedPassword,
+					Role:           user.Role,
+				},
+			},
+		},
+	})
+	return err
+}
+
+func (store *replicatedUserStore) Find(username string) (*User, error) {
+	return store.cluster.fsm.userStore().Find(username)
+}
+
+func (store *replicatedUserStore) List(found func(user *User) error) error {
+	return store.cluster.fsm.userStore().List(found)
+}
+
+// replicatedRatingStore writes ratings through the raft log of the cluster
+type replicatedRatingStore struct {
+	cluster *Cluster
+}
+
+func (store *replicatedRatingStore) Add(laptopID string, score float64) (*Rating, error) {
+	result, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_AddRating{
+			AddRating: &pb.AddRatingCommand{
+				LaptopId: laptopID,
+				Score:    score,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rating := &Rating{
+		Count: result.GetRating().GetCount(),
+		Sum:   result.GetRating().GetSum(),
+	}
+
+	return rating, nil
+}
+
+func (store *replicatedRatingStore) Find(laptopID string) *Rating {
+	return store.cluster.fsm.ratingStore().Find(laptopID)
+}
+
+func (store *replicatedRatingStore) Set(laptopID string, rating *Rating) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_SetRating{
+			SetRating: &pb.SetRatingCommand{
+				Rating: &pb.RatingRecord{
+					LaptopId: laptopID,
+					Count:    rating.Count,
+					Sum:      rating.Sum,
+				},
+			},
+		},
+	})
+	return err
+}
+
+func (store *replicatedRatingStore) List(found func(laptopID string, rating *Rating) error) error {
+	return store.cluster.fsm.ratingStore().List(found)
+}
+
+func (store *replicatedRatingStore) Delete(laptopID string) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_DeleteRating{
+			DeleteRating: &pb.DeleteRatingCommand{LaptopId: laptopID},
+		},
+	})
+	return err
+}
+
+// replicatedLaptopRevisionStore writes laptop versions through the raft log of the cluster
+type replicatedLaptopRevisionStore struct {
+	cluster *Cluster
+}
+
+func (store *replicatedLaptopRevisionStore) Save(laptop *pb.Laptop) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_SaveLaptopRevision{
+			SaveLaptopRevision: &pb.SaveLaptopRevisionCommand{Laptop: laptop},
+		},
+	})
+	return err
+}
+
+func (store *replicatedLaptopRevisionStore) Find(id string, revision uint64) *pb.Laptop {
+	return store.cluster.fsm.laptopRevisionStore().Find(id, revision)
+}
+
+func (store *replicatedLaptopRevisionStore) List(id string, found func(laptop *pb.Laptop) error) error {
+	return store.cluster.fsm.laptopRevisionStore().List(id, found)
+}
+
+func (store *replicatedLaptopRevisionStore) Delete(id string) error {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_DeleteLaptopRevisions{
+			DeleteLaptopRevisions: &pb.DeleteLaptopRevisionsCommand{LaptopId: id},
+		},
+	})
+	return err
+}
+
+// replicatedIdempotencyStore keeps the idempotency keys in the raft log of the cluster,
+// so a retry reaching another node replays the response of the first request
+type replicatedIdempotencyStore struct {
+	cluster *Cluster
+	ttl     time.Duration
+}
+
+func (store *replicatedIdempotencyStore) Begin(key string, fingerprint []byte) ([]byte, error) {
+	now := time.Now()
+	result, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_BeginIdempotentRequest{
+			BeginIdempotentRequest: &pb.BeginIdempotentRequestCommand{
+				Key:         key,
+				Fingerprint: fingerprint,
+				Now:         timestamppb.New(now),
+				ExpiresAt:   timestamppb.New(now.Add(store.ttl)),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch result.GetIdempotency() {
+	case pb.IdempotencyOutcome_IDEMPOTENCY_KEY_REUSED:
+		return nil, ErrIdempotencyKeyReused
+	case pb.IdempotencyOutcome_IDEMPOTENCY_KEY_IN_USE:
+		return nil, ErrIdempotencyKeyInUse
+	case pb.IdempotencyOutcome_IDEMPOTENCY_REPLAYED:
+		return append([]byte{}, result.GetIdempotentResponse()...), nil
+	default:
+		return nil, nil
+	}
+}
+
+// Finish saves the response through the cluster. If it fails, the key stays reserved until it expires.
+func (store *replicatedIdempotencyStore) Finish(key string, response []byte) {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_FinishIdempotentRequest{
+			FinishIdempotentRequest: &pb.FinishIdempotentRequestCommand{
+				Key:       key,
+				Response:  response,
+				ExpiresAt: timestamppb.New(time.Now().Add(store.ttl)),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("cannot save the response of idempotency key %s: %v", key, err)
+	}
+}
+
+// Cancel releases the key through the cluster. If it fails, the key stays reserved until it expires.
+func (store *replicatedIdempotencyStore) Cancel(key string) {
+	_, err := store.cluster.apply(&pb.ClusterCommand{
+		Command: &pb.ClusterCommand_CancelIdempotentRequest{
+			CancelIdempotentRequest: &pb.CancelIdempotentRequestCommand{Key: key},
+		},
+	})
+	if err != nil {
+		log.Printf("cannot release idempotency key %s: %v", key, err)
+	}
+}
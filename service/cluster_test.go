@@ -0,0 +1,485 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"gobook/pb"
+	"gobook/sample"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// testClusterPeerName is the name of the certificates of the test cluster nodes
+const testClusterPeerName = "node.cluster.test"
+
+// testClusterCA signs the certificates of the test cluster nodes and clients
+type testClusterCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestClusterCA(t *testing.T) *testClusterCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test cluster CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testClusterCA{cert: cert, key: key, pool: pool}
+}
+
+// certificate returns a certificate signed by the CA for the name, usable by servers and clients
+func (ca *testClusterCA) certificate(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// clusterTLS returns the TLS of a test cluster node
+func (ca *testClusterCA) clusterTLS(t *testing.T) ClusterTLS {
+	return ClusterTLS{
+		Certificate: ca.certificate(t, testClusterPeerName),
+		CAs:         ca.pool,
+		PeerName:    testClusterPeerName,
+	}
+}
+
+func TestParseClusterPeers(t *testing.T) {
+	peers, err := ParseClusterPeers("node1=127.0.0.1:7051, node2=127.0.0.1:7052,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"node1": "127.0.0.1:7051", "node2": "127.0.0.1:7052"}, peers)
+
+	_, err = ParseClusterPeers("node1")
+	require.Error(t, err)
+
+	_, err = ParseClusterPeers("node1=127.0.0.1:7051,node1=127.0.0.1:7052")
+	require.Error(t, err)
+}
+
+func TestCluster(t *testing.T) {
+	peers := make(map[string]string)
+	for i := 1; i <= 3; i++ {
+		peers[fmt.Sprintf("node%d", i)] = freeAddress(t)
+	}
+
+	dataDir := t.TempDir()
+	ca := newTestClusterCA(t)
+	startNode := func(id string) *Cluster {
+		cluster, err := NewCluster(ClusterConfig{
+			NodeID:  id,
+			Peers:   peers,
+			DataDir: filepath.Join(dataDir, id),
+			TLS:     ca.clusterTLS(t),
+		})
+		require.NoError(t, err)
+		return cluster
+	}
+
+	nodes := []*Cluster{startNode("node1"), startNode("node2"), startNode("node3")}
+	defer func() {
+		for _, node := range nodes {
+			node.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for _, node := range nodes {
+		require.NoError(t, node.WaitForLeader(ctx))
+	}
+
+	followers := []*Cluster{}
+	for _, node := range nodes {
+		if !node.IsLeader() {
+			followers = append(followers, node)
+		}
+	}
+	require.NotEmpty(t, followers)
+
+	// A write on a follower is forwarded to the leader and read back on the same node
+	laptop := sample.NewLaptop()
+	err := followers[0].LaptopStore().Save(laptop)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), laptop.GetRevision())
+	require.True(t, proto.Equal(laptop, followers[0].LaptopStore().Find(laptop.Id)))
+
+	err = nodes[0].LaptopStore().Save(laptop)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	updated := proto.Clone(laptop).(*pb.Laptop)
+	updated.PriceUsd = 999
+	err = followers[len(followers)-1].LaptopStore().Update(updated, 2)
+	require.ErrorIs(t, err, ErrRevisionMismatch)
+	err = followers[len(followers)-1].LaptopStore().Update(updated, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), updated.GetRevision())
+
+	for _, node := range nodes {
+		_, err := node.RatingStore().Add(laptop.Id, 4)
+		require.NoError(t, err)
+	}
+
+	user, err := NewUser("user1", "secret", "admin")
+	require.NoError(t, err)
+	err = nodes[1].UserStore().Save(user)
+	require.NoError(t, err)
+	err = nodes[2].UserStore().Save(user)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	// Every node converges to the same stores
+	for _, node := range nodes {
+		require.Eventually(t, func() bool {
+			found := node.LaptopStore().Find(laptop.Id)
+			rating := node.RatingStore().Find(laptop.Id)
+			_, err := node.UserStore().Find(user.Username)
+			return proto.Equal(updated, found) && rating != nil && rating.Count == 3 && err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+
+	// A restarted node replays its log
+	err = nodes[2].Close()
+	require.NoError(t, err)
+	nodes[2] = startNode("node3")
+
+	require.Eventually(t, func() bool {
+		return proto.Equal(updated, nodes[2].LaptopStore().Find(laptop.Id))
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, &Rating{Count: 3, Sum: 12}, nodes[2].RatingStore().Find(laptop.Id))
+
+	testLaptopStoreTrash(t, followers[0].LaptopStore())
+
+	// A batch is replicated as a single command, all or nothing
+	batch := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	err = followers[0].LaptopStore().SaveAll([]*pb.Laptop{batch[0], batch[1], laptop})
+	require.ErrorIs(t, err, ErrAlreadyExists)
+	require.Nil(t, followers[0].LaptopStore().Find(batch[0].Id))
+	err = followers[0].LaptopStore().SaveAll(batch)
+	require.NoError(t, err)
+	for _, saved := range batch {
+		require.Equal(t, uint64(1), saved.GetRevision())
+		require.True(t, proto.Equal(saved, followers[0].LaptopStore().Find(saved.Id)))
+	}
+	testLaptopRevisionStore(t, followers[0].LaptopRevisionStore())
+
+	// An idempotency key reserved on a node is seen by the others
+	idempotency := followers[0].IdempotencyStore(time.Hour)
+	saved, err := idempotency.Begin("user1/CreateLaptopService/key", []byte("request"))
+	require.NoError(t, err)
+	require.Nil(t, saved)
+	_, err = nodes[0].IdempotencyStore(time.Hour).Begin("user1/CreateLaptopService/key", []byte("request"))
+	require.ErrorIs(t, err, ErrIdempotencyKeyInUse)
+	idempotency.Finish("user1/CreateLaptopService/key", []byte("response"))
+	for _, node := range nodes {
+		require.Eventually(t, func() bool {
+			saved, err := node.IdempotencyStore(time.Hour).Begin("user1/CreateLaptopService/key", []byte("request"))
+			return err == nil && string(saved) == "response"
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+	_, err = nodes[1].IdempotencyStore(time.Hour).Begin("user1/CreateLaptopService/key", []byte("other request"))
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// Every node trashes the laptop at the time set by the node proposing the deletion
+	err = nodes[0].LaptopStore().Delete(laptop.Id, 0)
+	require.NoError(t, err)
+	deleted := nodes[0].LaptopStore().FindDeleted(laptop.Id)
+	require.NotNil(t, deleted)
+	for _, node := range nodes {
+		require.Eventually(t, func() bool {
+			return proto.Equal(deleted, node.LaptopStore().FindDeleted(laptop.Id))
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+}
+
+func TestClusterAuthenticatesPeers(t *testing.T) {
+	ca := newTestClusterCA(t)
+	address := freeAddress(t)
+
+	_, err := NewCluster(ClusterConfig{
+		NodeID: "node1",
+		Peers:  map[string]string{"node1": address},
+	})
+	require.Error(t, err)
+
+	cluster, err := NewCluster(ClusterConfig{
+		NodeID: "node1",
+		Peers:  map[string]string{"node1": address},
+		TLS:    ca.clusterTLS(t),
+	})
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	require.NoError(t, cluster.WaitForLeader(ctx))
+
+	command := &pb.ClusterCommand{
+		Command: &pb.ClusterCommand_SaveUser{
+			SaveUser: &pb.SaveUserCommand{User: &pb.UserRecord{Username: "intruder", Role: "admin"}},
+		},
+	}
+
+	// A client certificate of the same CA is not one of a node, nor is a certificate of another CA
+	testCases := []struct {
+		name string
+		tls  *tls.Config
+	}{
+		{
+			name: "no_certificate",
+			tls:  &tls.Config{RootCAs: ca.pool, ServerName: testClusterPeerName},
+		},
+		{
+			name: "client_certificate",
+			tls: &tls.Config{
+				Certificates: []tls.Certificate{ca.certificate(t, "client.cluster.test")},
+				RootCAs:      ca.pool,
+				ServerName:   testClusterPeerName,
+			},
+		},
+		{
+			name: "other_ca",
+			tls: &tls.Config{
+				Certificates: []tls.Certificate{newTestClusterCA(t).certificate(t, testClusterPeerName)},
+				RootCAs:      ca.pool,
+				ServerName:   testClusterPeerName,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := grpc.Dial(
+				address,
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+					return dialCluster(ctx, address, clusterConnection, tc.tls)
+				}),
+			)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			_, err = pb.NewClusterServiceClient(conn).Apply(ctx, &pb.ApplyRequest{Command: command})
+			require.Error(t, err)
+		})
+	}
+
+	_, err = cluster.UserStore().Find("intruder")
+	require.Error(t, err)
+
+	// Another node is let in
+	nodeTLS := ca.clusterTLS(t).clientConfig()
+	conn, err := grpc.Dial(
+		address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return dialCluster(ctx, address, clusterConnection, nodeTLS)
+		}),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = pb.NewClusterServiceClient(conn).Apply(ctx, &pb.ApplyRequest{Command: command})
+	require.NoError(t, err)
+	_, err = cluster.UserStore().Find("intruder")
+	require.NoError(t, err)
+}
+
+func TestClusterFSMAppliesRetriesOnce(t *testing.T) {
+	fsm := newClusterFSM()
+	index := uint64(0)
+	apply := func(command *pb.ClusterCommand) *clusterApplyResult {
+		data, err := proto.Marshal(command)
+		require.NoError(t, err)
+
+		index++
+		return fsm.Apply(&raft.Log{Index: index, Data: data}).(*clusterApplyResult)
+	}
+
+	laptopID := sample.NewLaptop().Id
+	command := &pb.ClusterCommand{
+		Id: "command1",
+		Command: &pb.ClusterCommand_AddRating{
+			AddRating: &pb.AddRatingCommand{LaptopId: laptopID, Score: 4},
+		},
+	}
+
+	first := apply(command)
+	require.NoError(t, first.err)
+	require.Equal(t, uint32(1), first.result.GetRating().GetCount())
+
+	// The retry of a committed command returns the first result
+	retry := apply(command)
+	require.NoError(t, retry.err)
+	require.True(t, proto.Equal(first.result, retry.result))
+	require.Equal(t, &Rating{Count: 1, Sum: 4}, fsm.ratingStore().Find(laptopID))
+
+	// The ids of the applied commands survive a snapshot
+	restored := newClusterFSM()
+	restoreClusterFSM(t, fsm, restored)
+	fsm = restored
+	retry = apply(command)
+	require.NoError(t, retry.err)
+	require.True(t, proto.Equal(first.result, retry.result))
+	require.Equal(t, &Rating{Count: 1, Sum: 4}, fsm.ratingStore().Find(laptopID))
+
+	command.Id = "command2"
+	second := apply(command)
+	require.NoError(t, second.err)
+	require.Equal(t, &Rating{Count: 2, Sum: 8}, fsm.ratingStore().Find(laptopID))
+}
+
+func TestClusterFSMRestoreIdempotencyKeys(t *testing.T) {
+	fsm := newClusterFSM()
+	now := time.Now()
+	_, err := fsm.idempotencyStore().begin("finished", []byte("request"), now, now.Add(time.Hour))
+	require.NoError(t, err)
+	fsm.idempotencyStore().finish("finished", nil, now.Add(time.Hour))
+	_, err = fsm.idempotencyStore().begin("running", []byte("request"), now, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	restored := newClusterFSM()
+	restoreClusterFSM(t, fsm, restored)
+
+	// An empty response is still replayed, and a running request keeps its key
+	saved, err := restored.idempotencyStore().begin("finished", []byte("request"), now, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	require.Empty(t, saved)
+	_, err = restored.idempotencyStore().begin("running", []byte("request"), now, now.Add(time.Hour))
+	require.ErrorIs(t, err, ErrIdempotencyKeyInUse)
+
+	// The key of a request that never finished is released once it expires
+	later := now.Add(time.Hour)
+	saved, err = restored.idempotencyStore().begin("running", []byte("request"), later, later.Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, saved)
+}
+
+func TestClusterFSMRestoreEventSequence(t *testing.T) {
+	fsm := newClusterFSM()
+	updated := sample.NewLaptop()
+	trashed := sample.NewLaptop()
+	require.NoError(t, fsm.laptopStore().SaveAll([]*pb.Laptop{
+		proto.Clone(updated).(*pb.Laptop),
+		proto.Clone(trashed).(*pb.Laptop),
+	}))
+
+	// The snapshot is ahead, with the laptop updated, the other one in the trash and a new one
+	snapshot := newClusterFSM()
+	require.NoError(t, snapshot.laptopStore().SaveAll([]*pb.Laptop{updated, trashed, sample.NewLaptop()}))
+	updated.PriceUsd = 999
+	require.NoError(t, snapshot.laptopStore().Update(updated, 1))
+	require.NoError(t, snapshot.laptopStore().Delete(trashed.Id, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watching := make(chan error)
+	go func() {
+		watching <- fsm.laptopStore().Watch(ctx, 2, func(event *pb.LaptopEvent) error {
+			return nil
+		})
+	}()
+
+	restoreClusterFSM(t, snapshot, fsm)
+	require.True(t, proto.Equal(updated, fsm.laptopStore().Find(updated.Id)))
+	require.NotNil(t, fsm.laptopStore().FindDeleted(trashed.Id))
+
+	// The watcher missed the events leading to the snapshot, so it is told to start over
+	select {
+	case err := <-watching:
+		require.ErrorIs(t, err, ErrEventsExpired)
+	case <-ctx.Done():
+		t.Fatal("watcher kept waiting after the restore")
+	}
+
+	// The next events are numbered like on the node the snapshot comes from
+	laptop := sample.NewLaptop()
+	events := make(chan *pb.LaptopEvent, 1)
+	go func() {
+		watching <- fsm.laptopStore().Watch(ctx, 5, func(event *pb.LaptopEvent) error {
+			events <- event
+			return nil
+		})
+	}()
+	require.NoError(t, fsm.laptopStore().Save(proto.Clone(laptop).(*pb.Laptop)))
+	require.NoError(t, snapshot.laptopStore().Save(laptop))
+	select {
+	case event := <-events:
+		require.Equal(t, uint64(6), event.GetSequence())
+		require.Equal(t, laptop.Id, event.GetLaptop().GetId())
+	case <-ctx.Done():
+		t.Fatal("watcher stopped after the restore")
+	}
+	require.Equal(t, snapshot.laptopStore().events.current(), fsm.laptopStore().events.current())
+
+	cancel()
+	require.NoError(t, <-watching)
+}
+
+// restoreClusterFSM restores the snapshot of one FSM into another
+func restoreClusterFSM(t *testing.T, fsm *clusterFSM, restored *clusterFSM) {
+	snapshot, err := fsm.Snapshot()
+	require.NoError(t, err)
+
+	snapshots := raft.NewInmemSnapshotStore()
+	sink, err := snapshots.Create(raft.SnapshotVersionMax, fsm.applied, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	require.NoError(t, snapshot.Persist(sink))
+
+	_, reader, err := snapshots.Open(sink.ID())
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(reader))
+}
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
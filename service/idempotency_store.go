@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotencyKeyHeader is the metadata header with the idempotency key of a request
+const IdempotencyKeyHeader = "idempotency-key"
+
+// DefaultIdempotencyTTL is how long a LaptopServer remembers a response when no store is configured
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is used again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key is already used by a different request")
+	// ErrIdempotencyKeyInUse is returned when a request with the same key is still running
+	ErrIdempotencyKeyInUse = errors.New("a request with the same idempotency key is in progress")
+)
+
+// IdempotencyStore remembers the responses of the requests made with an idempotency key
+type IdempotencyStore interface {
+	// Begin reserves key for a request with the fingerprint. It returns the response saved
+	// for the key, or nil if the request must run and then call Finish or Cancel.
+	Begin(key string, fingerprint []byte) ([]byte, error)
+	// Finish saves the response of the request that reserved key
+	Finish(key string, response []byte)
+	// Cancel releases key after the request failed, so it can be retried
+	Cancel(key string)
+}
+
+type InMemoryIdempotencyStore struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	records map[string]*idempotencyRecord
+	// lastSweep is when the expired records were last removed
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type idempotencyRecord struct {
+	fingerprint []byte
+	// response is nil while the request is running
+	response []byte
+	// expiresAt is when the response is forgotten, or the key of a request that never finished is released
+	expiresAt time.Time
+}
+
+// NewInMemoryIdempotencyStore returns a store remembering every response for ttl
+func NewInMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		ttl:     ttl,
+		records: make(map[string]*idempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+func (store *InMemoryIdempotencyStore) Begin(key string, fingerprint []byte) ([]byte, error) {
+	now := store.now()
+	return store.begin(key, fingerprint, now, now.Add(store.ttl))
+}
+
+// begin reserves key until expiresAt, with the time now given by the caller
+// so the replicated stores of a cluster agree on it
+func (store *InMemoryIdempotencyStore) begin(key string, fingerprint []byte, now time.Time, expiresAt time.Time) ([]byte, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.sweep(now)
+
+	record := store.records[key]
+	if record != nil && now.Before(record.expiresAt) {
+		if !bytes.Equal(record.fingerprint, fingerprint) {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if record.response == nil {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		return record.response, nil
+	}
+
+	store.records[key] = &idempotencyRecord{
+		fingerprint: fingerprint,
+		expiresAt:   expiresAt,
+	}
+	return nil, nil
+}
+
+func (store *InMemoryIdempotencyStore) Finish(key string, response []byte) {
+	store.finish(key, response, store.now().Add(store.ttl))
+}
+
+// finish saves the response of key until expiresAt
+func (store *InMemoryIdempotencyStore) finish(key string, response []byte, expiresAt time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record := store.records[key]
+	if record == nil {
+		return
+	}
+
+	// An empty response is still a saved one
+	if response == nil {
+		response = []byte{}
+	}
+	record.response = response
+	record.expiresAt = expiresAt
+}
+
+func (store *InMemoryIdempotencyStore) Cancel(key string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record := store.records[key]
+	if record != nil && record.response == nil {
+		delete(store.records, key)
+	}
+}
+
+// sweep removes the expired records, at most once per ttl
+func (store *InMemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < store.ttl {
+		return
+	}
+
+	for key, record := range store.records {
+		if !now.Before(record.expiresAt) {
+			delete(store.records, key)
+		}
+	}
+	store.lastSweep = now
+}
+
+// idempotencyKey returns the idempotency key in the metadata of the request, or an empty string
+func idempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(IdempotencyKeyHeader)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// requestFingerprint hashes a request, to tell a retry from a different request with the same key
+func requestFingerprint(req proto.Message) ([]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
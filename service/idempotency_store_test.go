@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInMemoryIdempotencyStore(t *testing.T) {
+	now := time.Now()
+	store := NewInMemoryIdempotencyStore(time.Hour).(*InMemoryIdempotencyStore)
+	store.now = func() time.Time { return now }
+
+	saved, err := store.Begin("key", []byte("request"))
+	require.NoError(t, err)
+	require.Nil(t, saved)
+
+	_, err = store.Begin("key", []byte("request"))
+	require.ErrorIs(t, err, ErrIdempotencyKeyInUse)
+
+	store.Finish("key", []byte("response"))
+
+	saved, err = store.Begin("key", []byte("request"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("response"), saved)
+
+	_, err = store.Begin("key", []byte("other request"))
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// A cancelled request releases its key
+	_, err = store.Begin("cancelled", []byte("request"))
+	require.NoError(t, err)
+	store.Cancel("cancelled")
+	saved, err = store.Begin("cancelled", []byte("other request"))
+	require.NoError(t, err)
+	require.Nil(t, saved)
+
+	// An expired response is forgotten
+	now = now.Add(time.Hour)
+	saved, err = store.Begin("key", []byte("other request"))
+	require.NoError(t, err)
+	require.Nil(t, saved)
+}
+
+func TestLaptopServerCreateLaptopIdempotency(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	server := NewLaptopServer(laptopStore, nil, nil)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "create-1"))
+
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+
+	retry := proto.Clone(laptop).(*pb.Laptop)
+
+	res1, err := server.CreateLaptopService(ctx, &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+	require.NotEmpty(t, res1.GetId())
+
+	// The retry gets the first response instead of a second laptop with a new id
+	res2, err := server.CreateLaptopService(ctx, &pb.CreateLaptopRequest{Laptop: retry})
+	require.NoError(t, err)
+	require.Equal(t, res1.GetId(), res2.GetId())
+
+	count := 0
+	err = laptopStore.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// The same key with a different laptop is rejected
+	_, err = server.CreateLaptopService(ctx, &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// A failed request can be retried with its key
+	invalid := sample.NewLaptop()
+	invalid.Screen = nil
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "create-2"))
+	_, err = server.CreateLaptopService(ctx, &pb.CreateLaptopRequest{Laptop: invalid})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.CreateLaptopService(ctx, &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+}
+
+func TestLaptopServerIdempotencyKeyOfUser(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	server := NewLaptopServer(laptopStore, nil, nil)
+
+	// Two users with the same key each create their laptop
+	ids := []string{}
+	for _, username := range []string{"admin1", "admin2"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "create-1"))
+		ctx = context.WithValue(ctx, claimsContextKey{}, &Payload{Username: username, Role: "admin"})
+
+		laptop := sample.NewLaptop()
+		laptop.Id = ""
+		res, err := server.CreateLaptopService(ctx, &pb.CreateLaptopRequest{Laptop: laptop})
+		require.NoError(t, err)
+		ids = append(ids, res.GetId())
+	}
+
+	require.NotEqual(t, ids[0], ids[1])
+	require.NotNil(t, laptopStore.Find(ids[0]))
+	require.NotNil(t, laptopStore.Find(ids[1]))
+}
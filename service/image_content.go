@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/webp"
+)
+
+// ErrInvalidImage is returned when an image has a type or content that is not accepted
+var ErrInvalidImage = errors.New("invalid image")
+
+// imageFormat is a format accepted for the images
+type imageFormat struct {
+	name string
+	// extension is the one of the saved files
+	extension string
+	mimeType  string
+	// magic tells if the first bytes of an image are in the format
+	magic        func(header []byte) bool
+	decodeConfig func(reader io.Reader) (image.Config, error)
+	decode       func(reader io.Reader) (image.Image, error)
+}
+
+var imageFormats = []*imageFormat{
+	{
+		name:      "jpeg",
+		extension: ".jpg",
+		mimeType:  "image/jpeg",
+		magic: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("\xff\xd8\xff"))
+		},
+		decodeConfig: jpeg.DecodeConfig,
+		decode:       jpeg.Decode,
+	},
+	{
+		name:      "png",
+		extension: ".png",
+		mimeType:  "image/png",
+		magic: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n"))
+		},
+		decodeConfig: png.DecodeConfig,
+		decode:       png.Decode,
+	},
+	{
+		name:      "gif",
+		extension: ".gif",
+		mimeType:  "image/gif",
+		magic: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("GIF87a")) || bytes.HasPrefix(header, []byte("GIF89a"))
+		},
+		decodeConfig: gif.DecodeConfig,
+		decode:       gif.Decode,
+	},
+	{
+		name:      "webp",
+		extension: ".webp",
+		mimeType:  "image/webp",
+		magic: func(header []byte) bool {
+			return len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP"))
+		},
+		decodeConfig: webp.DecodeConfig,
+		decode:       webp.Decode,
+	},
+}
+
+// imageTypes maps the image types an upload may declare to their format
+var imageTypes = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".webp": "webp",
+}
+
+// imageHeaderSize is the number of bytes read to detect the format of an image
+const imageHeaderSize = 12
+
+// imageContent is what the content of an image tells about it
+type imageContent struct {
+	format *imageFormat
+	width  int
+	height int
+}
+
+// checkImageType returns an error if an upload declares a type that is not an accepted extension.
+// An empty type is accepted, the extension then comes from the content.
+func checkImageType(imageType string) error {
+	if imageType == "" {
+		return nil
+	}
+
+	if _, ok := imageTypes[strings.ToLower(imageType)]; !ok {
+		return fmt.Errorf("%w: image type %q is not allowed", ErrInvalidImage, imageType)
+	}
+
+	return nil
+}
+
+// sniffImage detects the format of an image from its magic bytes and decodes its dimensions.
+// It returns an error if the content is not an accepted format or does not match the declared type.
+func sniffImage(reader io.Reader, imageType string) (*imageContent, error) {
+	buffered := bufio.NewReader(reader)
+	format, err := detectImageFormat(buffered)
+	if err != nil {
+		return nil, err
+	}
+
+	if imageType != "" && imageTypes[strings.ToLower(imageType)] != format.name {
+		return nil, fmt.Errorf("%w: image type %q does not match content %s", ErrInvalidImage, imageType, format.mimeType)
+	}
+
+	config, err := format.decodeConfig(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decode %s: %v", ErrInvalidImage, format.name, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("%w: image has no pixels", ErrInvalidImage)
+	}
+
+	content := &imageContent{
+		format: format,
+		width:  config.Width,
+		height: config.Height,
+	}
+
+	return content, nil
+}
+
+// decodeImage decodes an image in one of the accepted formats
+func decodeImage(reader io.Reader) (image.Image, *imageFormat, error) {
+	buffered := bufio.NewReader(reader)
+	format, err := detectImageFormat(buffered)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, err := format.decode(buffered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: cannot decode %s: %v", ErrInvalidImage, format.name, err)
+	}
+
+	return img, format, nil
+}
+
+// detectImageFormat returns the format of the image from its magic bytes, without consuming them
+func detectImageFormat(reader *bufio.Reader) (*imageFormat, error) {
+	header, err := reader.Peek(imageHeaderSize)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("cannot read image header: %w", err)
+	}
+
+	for _, format := range imageFormats {
+		if format.magic(header) {
+			return format, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: content is not a JPEG, PNG, GIF or WebP image", ErrInvalidImage)
+}
+
+// findImageFormat returns the accepted format with the name
+func findImageFormat(name string) *imageFormat {
+	for _, format := range imageFormats {
+		if format.name == name {
+			return format
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testWebP is a lossless WebP image of 1x1 pixel
+var testWebP = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+// newTestImage returns an image encoded in the format, one of jpeg, png or gif
+func newTestImage(t *testing.T, format string, width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	buffer := &bytes.Buffer{}
+
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(buffer, img, nil)
+	case "png":
+		err = png.Encode(buffer, img)
+	case "gif":
+		err = gif.Encode(buffer, img, nil)
+	default:
+		t.Fatalf("cannot encode %s images", format)
+	}
+	require.NoError(t, err)
+
+	return buffer.Bytes()
+}
+
+func TestSniffImage(t *testing.T) {
+	testCases := []struct {
+		name      string
+		data      []byte
+		imageType string
+		mimeType  string
+		width     int
+		height    int
+		err       error
+	}{
+		{
+			name:      "jpeg",
+			data:      newTestImage(t, "jpeg", 40, 30),
+			imageType: ".jpg",
+			mimeType:  "image/jpeg",
+			width:     40,
+			height:    30,
+		},
+		{
+			name:      "jpeg_extension",
+			data:      newTestImage(t, "jpeg", 40, 30),
+			imageType: ".JPEG",
+			mimeType:  "image/jpeg",
+			width:     40,
+			height:    30,
+		},
+		{
+			name:      "png",
+			data:      newTestImage(t, "png", 3, 5),
+			imageType: ".png",
+			mimeType:  "image/png",
+			width:     3,
+			height:    5,
+		},
+		{
+			name:     "gif_without_type",
+			data:     newTestImage(t, "gif", 8, 2),
+			mimeType: "image/gif",
+			width:    8,
+			height:   2,
+		},
+		{
+			name:      "webp",
+			data:      testWebP,
+			imageType: ".webp",
+			mimeType:  "image/webp",
+			width:     1,
+			height:    1,
+		},
+		{
+			name:      "type_mismatch",
+			data:      newTestImage(t, "png", 3, 5),
+			imageType: ".jpg",
+			err:       ErrInvalidImage,
+		},
+		{
+			name:      "not_an_image",
+			data:      []byte("MZ\x90\x00 executable"),
+			imageType: ".jpg",
+			err:       ErrInvalidImage,
+		},
+		{
+			name:      "truncated",
+			data:      newTestImage(t, "png", 3, 5)[:20],
+			imageType: ".png",
+			err:       ErrInvalidImage,
+		},
+		{
+			name: "empty",
+			err:  ErrInvalidImage,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := sniffImage(bytes.NewReader(tc.data), tc.imageType)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.mimeType, content.format.mimeType)
+			require.Equal(t, tc.width, content.width)
+			require.Equal(t, tc.height, content.height)
+		})
+	}
+}
+
+func TestCheckImageType(t *testing.T) {
+	for _, imageType := range []string{"", ".jpg", ".jpeg", ".PNG", ".gif", ".webp"} {
+		require.NoError(t, checkImageType(imageType), imageType)
+	}
+
+	for _, imageType := range []string{".exe", "/../../etc/x", ".jpg/../x", "jpg", ".svg"} {
+		require.ErrorIs(t, checkImageType(imageType), ErrInvalidImage, imageType)
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// imageResizeQueueSize is how many images may wait for their variants
+	imageResizeQueueSize = 1000
+	// maxResizePixels is the number of pixels of the largest image resized, decoded in memory
+	maxResizePixels = 50_000_000
+	// variantJPEGQuality is the quality of the variants encoded as JPEG
+	variantJPEGQuality = 85
+)
+
+// variantNamePattern keeps the variant names safe in file names
+var variantNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ImageVariantSpec is a variant generated for every uploaded image,
+// resized to fit a square of Size pixels
+type ImageVariantSpec struct {
+	Name string
+	Size int
+}
+
+// ParseImageVariants parses variants written as name=size, separated by commas
+func ParseImageVariants(value string) ([]ImageVariantSpec, error) {
+	specs := []ImageVariantSpec{}
+	names := map[string]bool{}
+
+	for _, variant := range strings.Split(value, ",") {
+		variant = strings.TrimSpace(variant)
+		if variant == "" {
+			continue
+		}
+
+		name, size, ok := strings.Cut(variant, "=")
+		if !ok {
+			return nil, fmt.Errorf("variant %q is not name=size", variant)
+		}
+		if !variantNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("variant name %q must only have lowercase letters, digits, - and _", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("variant %q is defined twice", name)
+		}
+
+		pixels, err := strconv.Atoi(size)
+		if err != nil || pixels <= 0 {
+			return nil, fmt.Errorf("variant %q has an invalid size %q", name, size)
+		}
+
+		names[name] = true
+		specs = append(specs, ImageVariantSpec{Name: name, Size: pixels})
+	}
+
+	return specs, nil
+}
+
+// ImageResizer generates the variants of the uploaded images in a pool of workers
+type ImageResizer struct {
+	imageStore ImageStore
+	variants   []ImageVariantSpec
+	queue      chan string
+	wait       sync.WaitGroup
+}
+
+// NewImageResizer starts workers generating the variants of the images added to it, at least one
+func NewImageResizer(imageStore ImageStore, variants []ImageVariantSpec, workers int) *ImageResizer {
+	if workers < 1 {
+		workers = 1
+	}
+
+	resizer := &ImageResizer{
+		imageStore: imageStore,
+		variants:   variants,
+		queue:      make(chan string, imageResizeQueueSize),
+	}
+
+	resizer.wait.Add(workers)
+	for i := 0; i < workers; i++ {
+		go resizer.work()
+	}
+
+	return resizer
+}
+
+// Add queues an image to generate its variants. It returns false, without waiting,
+// if the queue is full: the image then stays without variants.
+func (resizer *ImageResizer) Add(imageID string) bool {
+	select {
+	case resizer.queue <- imageID:
+		return true
+	default:
+		log.Printf("cannot queue image %s to resize: queue is full", imageID)
+		return false
+	}
+}
+
+// Close waits for the workers to generate the variants of the queued images, then stops them
+func (resizer *ImageResizer) Close() {
+	close(resizer.queue)
+	resizer.wait.Wait()
+}
+
+func (resizer *ImageResizer) work() {
+	defer resizer.wait.Done()
+
+	for imageID := range resizer.queue {
+		err := resizer.Resize(imageID)
+		if err != nil {
+			log.Printf("cannot generate variants of image %s: %v", imageID, err)
+		}
+	}
+}
+
+// Resize generates every variant of the image and saves them in the image store
+func (resizer *ImageResizer) Resize(imageID string) error {
+	info, file, err := resizer.imageStore.Open(imageID, "")
+	if err != nil {
+		return fmt.Errorf("cannot open image: %w", err)
+	}
+	defer file.Close()
+
+	if info.Width*info.Height > maxResizePixels {
+		return fmt.Errorf("image of %dx%d is too big to resize", info.Width, info.Height)
+	}
+
+	original, format, err := decodeImage(file)
+	if err != nil {
+		return err
+	}
+
+	// The variants keep the transparency of PNG and GIF images, the others become JPEG
+	variantFormat := findImageFormat("jpeg")
+	if format.name == "png" || format.name == "gif" {
+		variantFormat = findImageFormat("png")
+	}
+
+	for _, spec := range resizer.variants {
+		resized := resizeImage(original, spec.Size, variantFormat.name == "jpeg")
+
+		data := &bytes.Buffer{}
+		if variantFormat.name == "jpeg" {
+			err = jpeg.Encode(data, resized, &jpeg.Options{Quality: variantJPEGQuality})
+		} else {
+			err = png.Encode(data, resized)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot encode variant %s: %w", spec.Name, err)
+		}
+
+		variant := &ImageVariant{
+			Name:     spec.Name,
+			Type:     variantFormat.extension,
+			MimeType: variantFormat.mimeType,
+			Width:    resized.Bounds().Dx(),
+			Height:   resized.Bounds().Dy(),
+		}
+
+		err = resizer.imageStore.SaveVariant(imageID, variant, data)
+		if err != nil {
+			return fmt.Errorf("cannot save variant %s: %w", spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// resizeImage scales an image down to fit a square of size pixels, keeping its aspect ratio.
+// A smaller image keeps its size. An opaque copy has a white background instead of transparency.
+func resizeImage(src image.Image, size int, opaque bool) image.Image {
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+
+	if width > size || height > size {
+		if width >= height {
+			height = height * size / width
+			width = size
+		} else {
+			width = width * size / height
+			height = size
+		}
+	}
+	if width == 0 {
+		width = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if opaque {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	return dst
+}
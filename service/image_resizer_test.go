@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageVariants(t *testing.T) {
+	variants, err := ParseImageVariants("thumbnail=128, small=512,large=1024")
+	require.NoError(t, err)
+	require.Equal(t, []ImageVariantSpec{{"thumbnail", 128}, {"small", 512}, {"large", 1024}}, variants)
+
+	variants, err = ParseImageVariants("")
+	require.NoError(t, err)
+	require.Empty(t, variants)
+
+	for _, value := range []string{"thumbnail", "thumb=0", "thumb=big", "../x=128", "Thumb=128", "a=1,a=2"} {
+		_, err := ParseImageVariants(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestImageResizer(t *testing.T) {
+	folder := t.TempDir()
+	store := NewDiskImageStore(folder)
+
+	pngID, err := store.Save("laptop", ".png", bytes.NewReader(newTestImage(t, "png", 300, 150)))
+	require.NoError(t, err)
+	jpegID, err := store.Save("laptop", ".jpg", bytes.NewReader(newTestImage(t, "jpeg", 100, 400)))
+	require.NoError(t, err)
+	webpID, err := store.Save("laptop", ".webp", bytes.NewReader(testWebP))
+	require.NoError(t, err)
+
+	resizer := NewImageResizer(store, []ImageVariantSpec{{"thumb", 64}, {"large", 1000}}, 2)
+	for _, imageID := range []string{pngID, jpegID, webpID} {
+		require.True(t, resizer.Add(imageID))
+	}
+	resizer.Close()
+
+	testCases := []struct {
+		imageID  string
+		variant  string
+		mimeType string
+		width    int
+		height   int
+	}{
+		{pngID, "thumb", "image/png", 64, 32},
+		{pngID, "large", "image/png", 300, 150},
+		{jpegID, "thumb", "image/jpeg", 16, 64},
+		{jpegID, "large", "image/jpeg", 100, 400},
+		{webpID, "thumb", "image/jpeg", 1, 1},
+	}
+
+	for _, tc := range testCases {
+		info, file, err := store.Open(tc.imageID, tc.variant)
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		require.Equal(t, tc.mimeType, info.MimeType)
+		require.Equal(t, int64(len(data)), info.Size)
+		require.Equal(t, tc.width, info.Width)
+		require.Equal(t, tc.height, info.Height)
+
+		decodeConfig := png.DecodeConfig
+		if tc.mimeType == "image/jpeg" {
+			decodeConfig = jpeg.DecodeConfig
+		}
+		config, err := decodeConfig(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, tc.width, config.Width)
+		require.Equal(t, tc.height, config.Height)
+	}
+
+	_, _, err = store.Open(pngID, "unknown")
+	require.ErrorIs(t, err, ErrImageNotFound)
+
+	// Resizing again replaces the variants
+	require.NoError(t, NewImageResizer(store, []ImageVariantSpec{{"thumb", 32}}, 1).Resize(pngID))
+	info, file, err := store.Open(pngID, "thumb")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.Equal(t, 32, info.Width)
+
+	// The variants are deleted with their image
+	require.NoError(t, store.DeleteLaptopImages("laptop"))
+	entries, err := os.ReadDir(folder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -1,62 +1,570 @@
 package service
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"gobook/pb"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+var ErrImageNotFound = errors.New("image not found")
+
 type ImageStore interface {
-	Save(laptopID string, imageType string, imageData bytes.Buffer) (string, error)
+	// Save stores the image read from imageData and returns its id
+	Save(laptopID string, imageType string, imageData io.Reader) (string, error)
+	// Create returns a writer storing a new image as its data arrives
+	Create(laptopID string, imageType string) (ImageWriter, error)
+	// ListLaptopImages calls found with the metadata of every image of the laptop
+	ListLaptopImages(laptopID string, found func(imageID string, info *ImageInfo) error) error
+	// Open returns the metadata of the image and a reader of its data, to be closed by the caller.
+	// With a variant name, the metadata and data are the ones of that variant of the image.
+	Open(imageID string, variant string) (*ImageInfo, io.ReadCloser, error)
+	// SaveVariant stores a resized copy of the image, replacing the variant with the same name
+	SaveVariant(imageID string, variant *ImageVariant, data io.Reader) error
+	// DeleteLaptopImages removes every image of the laptop
+	DeleteLaptopImages(laptopID string) error
+}
+
+// ImageWriter writes the data of a new image, which is only stored once committed
+type ImageWriter interface {
+	io.Writer
+	// Commit stores the image written so far and returns its id
+	Commit() (string, error)
+	// Abort discards the image written so far. It does nothing once the image is committed.
+	Abort() error
+}
+
+// ImageIndex keeps the metadata of the images saved by an ImageStore
+type ImageIndex interface {
+	Save(imageID string, info *ImageInfo) error
+	Find(imageID string) (*ImageInfo, error)
+	// List calls found with the metadata of every image
+	List(found func(imageID string, info *ImageInfo) error) error
+	// Delete removes the metadata of the image, if any
+	Delete(imageID string) error
 }
 
 type DiskImageStore struct {
-	mutex       sync.RWMutex
+	// mutex orders the updates of the variants of the images with their deletion
+	mutex       sync.Mutex
 	imageFolder string
-	images      map[string]*ImageInfo
+	index       ImageIndex
 }
 
 type ImageInfo struct {
 	LaptopID string
 	Type     string
 	Path     string
+	// Size is the size of the image in bytes
+	Size int64
+	// UploadedAt is zero for the images saved before it was recorded
+	UploadedAt time.Time
+	// MimeType, Width and Height are detected from the content of the image
+	MimeType string
+	Width    int
+	Height   int
+	// Variants are the resized copies of the image generated so far
+	Variants []*ImageVariant
+}
+
+// ImageVariant is a resized copy of an image
+type ImageVariant struct {
+	Name     string
+	Type     string
+	Path     string
+	Size     int64
+	MimeType string
+	Width    int
+	Height   int
+}
+
+// variant returns the variant of the image with the name, or nil
+func (info *ImageInfo) variant(name string) *ImageVariant {
+	for _, variant := range info.Variants {
+		if variant.Name == name {
+			return variant
+		}
+	}
+
+	return nil
+}
+
+// newImageRecord returns the record persisting the metadata of an image
+func newImageRecord(imageID string, info *ImageInfo) *pb.ImageRecord {
+	record := &pb.ImageRecord{
+		Id:       imageID,
+		LaptopId: info.LaptopID,
+		Type:     info.Type,
+		Path:     info.Path,
+		Size:     uint64(info.Size),
+		MimeType: info.MimeType,
+		Width:    uint32(info.Width),
+		Height:   uint32(info.Height),
+	}
+	if !info.UploadedAt.IsZero() {
+		record.UploadedAt = timestamppb.New(info.UploadedAt)
+	}
+	for _, variant := range info.Variants {
+		record.Variants = append(record.Variants, &pb.ImageVariantRecord{
+			Name:     variant.Name,
+			Type:     variant.Type,
+			Path:     variant.Path,
+			Size:     uint64(variant.Size),
+			MimeType: variant.MimeType,
+			Width:    uint32(variant.Width),
+			Height:   uint32(variant.Height),
+		})
+	}
+
+	return record
+}
+
+// imageInfoFromRecord returns the metadata of an image persisted as a record
+func imageInfoFromRecord(record *pb.ImageRecord) *ImageInfo {
+	info := &ImageInfo{
+		LaptopID: record.GetLaptopId(),
+		Type:     record.GetType(),
+		Path:     record.GetPath(),
+		Size:     int64(record.GetSize()),
+		MimeType: record.GetMimeType(),
+		Width:    int(record.GetWidth()),
+		Height:   int(record.GetHeight()),
+	}
+	if record.GetUploadedAt() != nil {
+		info.UploadedAt = record.GetUploadedAt().AsTime()
+	}
+	for _, variant := range record.GetVariants() {
+		info.Variants = append(info.Variants, &ImageVariant{
+			Name:     variant.GetName(),
+			Type:     variant.GetType(),
+			Path:     variant.GetPath(),
+			Size:     int64(variant.GetSize()),
+			MimeType: variant.GetMimeType(),
+			Width:    int(variant.GetWidth()),
+			Height:   int(variant.GetHeight()),
+		})
+	}
+
+	return info
+}
+
+// importedImageInfo returns the metadata of an image record imported in the image folder.
+// The paths of the record are not trusted: they are rebuilt from its id and types,
+// so that an archive cannot make the store serve or remove files outside the folder.
+func importedImageInfo(imageFolder string, record *pb.ImageRecord) (*ImageInfo, error) {
+	_, err := uuid.Parse(record.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("%w: image id is invalid: %v", ErrInvalidImage, err)
+	}
+
+	info := imageInfoFromRecord(record)
+	info.Type, err = imageExtension(info.Type)
+	if err != nil {
+		return nil, err
+	}
+	info.Path = imageFilePath(imageFolder, record.GetId(), info.Type)
+
+	for _, variant := range info.Variants {
+		if variant.Name == "" || strings.ContainsAny(variant.Name, `/\`) {
+			return nil, fmt.Errorf("%w: variant name %q is not allowed", ErrInvalidImage, variant.Name)
+		}
+
+		variant.Type, err = imageExtension(variant.Type)
+		if err != nil {
+			return nil, err
+		}
+		variant.Path = variantFilePath(imageFolder, record.GetId(), variant.Name, variant.Type)
+	}
+
+	return info, nil
+}
+
+// imageExtension returns the extension of the saved files of an accepted image type
+func imageExtension(imageType string) (string, error) {
+	name, ok := imageTypes[strings.ToLower(imageType)]
+	if !ok {
+		return "", fmt.Errorf("%w: image type %q is not allowed", ErrInvalidImage, imageType)
+	}
+
+	return findImageFormat(name).extension, nil
+}
+
+// imageFilePath is the path of the file of an image in the image folder
+func imageFilePath(imageFolder string, imageID string, extension string) string {
+	return fmt.Sprintf("%s/%s%s", imageFolder, imageID, extension)
+}
+
+// variantFilePath is the path of the file of an image variant in the image folder
+func variantFilePath(imageFolder string, imageID string, name string, extension string) string {
+	return fmt.Sprintf("%s/%s-%s%s", imageFolder, imageID, name, extension)
 }
 
 func NewDiskImageStore(imageFolder string) ImageStore {
+	return NewDiskImageStoreWithIndex(imageFolder, NewInMemoryImageIndex())
+}
+
+// NewDiskImageStoreWithIndex returns a DiskImageStore keeping the image metadata in index.
+// It removes the temporary files of the uploads and variants interrupted by a restart.
+func NewDiskImageStoreWithIndex(imageFolder string, index ImageIndex) ImageStore {
+	removeTempImages(imageFolder)
+
 	return &DiskImageStore{
 		imageFolder: imageFolder,
-		images:      make(map[string]*ImageInfo),
+		index:       index,
+	}
+}
+
+// removeTempImages removes the temporary files left in the image folder.
+// A file that cannot be removed only takes space, so the error is logged.
+func removeTempImages(imageFolder string) {
+	for _, pattern := range []string{"upload-*.tmp", "variant-*.tmp"} {
+		paths, err := filepath.Glob(filepath.Join(imageFolder, pattern))
+		if err != nil {
+			log.Printf("cannot list temporary images: %v", err)
+			continue
+		}
+
+		for _, path := range paths {
+			err := os.Remove(path)
+			if err != nil {
+				log.Printf("cannot remove temporary image %s: %v", path, err)
+			}
+		}
+	}
+}
+
+func (store *DiskImageStore) Save(laptopID string, imageType string, imageData io.Reader) (string, error) {
+	writer, err := store.Create(laptopID, imageType)
+	if err != nil {
+		return "", err
+	}
+	defer writer.Abort()
+
+	_, err = io.Copy(writer, imageData)
+	if err != nil {
+		return "", fmt.Errorf("cannot write data to file: %w", err)
+	}
+
+	return writer.Commit()
+}
+
+// Create writes the image to a temporary file of the image folder,
+// renamed into place on commit so a partial image is never visible
+func (store *DiskImageStore) Create(laptopID string, imageType string) (ImageWriter, error) {
+	// The type is checked now to fail early, the file extension comes from the content
+	err := checkImageType(imageType)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(store.imageFolder, "upload-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create file: %w", err)
+	}
+
+	writer := &diskImageWriter{
+		store:     store,
+		laptopID:  laptopID,
+		imageType: imageType,
+		file:      file,
 	}
+
+	return writer, nil
+}
+
+type diskImageWriter struct {
+	store     *DiskImageStore
+	laptopID  string
+	imageType string
+	// file is the temporary file, nil once the image is committed or aborted
+	file *os.File
+	size int64
 }
 
-func (store *DiskImageStore) Save(laptopID string, imageType string, imageData bytes.Buffer) (string, error) {
+func (writer *diskImageWriter) Write(data []byte) (int, error) {
+	if writer.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	n, err := writer.file.Write(data)
+	writer.size += int64(n)
+	return n, err
+}
+
+func (writer *diskImageWriter) Commit() (string, error) {
+	if writer.file == nil {
+		return "", os.ErrClosed
+	}
+
+	_, err := writer.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", fmt.Errorf("cannot seek file: %w", err)
+	}
+
+	content, err := sniffImage(writer.file, writer.imageType)
+	if err != nil {
+		return "", err
+	}
+
 	imageId, err := uuid.NewRandom()
 	if err != nil {
 		return "", fmt.Errorf("cannot generate image id: %w", err)
 	}
-	imagePath := fmt.Sprintf("%s/%s%s", store.imageFolder, imageId, imageType)
+	imagePath := imageFilePath(writer.store.imageFolder, imageId.String(), content.format.extension)
 
-	file, err := os.Create(imagePath)
+	err = writer.file.Sync()
 	if err != nil {
-		return "", fmt.Errorf("cannot create file: %w", err)
+		return "", fmt.Errorf("cannot sync file: %w", err)
 	}
 
-	_, err = imageData.WriteTo(file)
+	err = writer.file.Close()
 	if err != nil {
-		return "", fmt.Errorf("cannot write data to file: %w", err)
+		return "", fmt.Errorf("cannot close file: %w", err)
 	}
 
-	store.mutex.RLock()
-	defer store.mutex.RUnlock()
+	tempPath := writer.file.Name()
+	writer.file = nil
+
+	err = os.Rename(tempPath, imagePath)
+	if err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("cannot rename file: %w", err)
+	}
 
-	store.images[imageId.String()] = &ImageInfo{
-		LaptopID: laptopID,
-		Type:     imageType,
-		Path:     imagePath,
+	err = writer.store.index.Save(imageId.String(), &ImageInfo{
+		LaptopID:   writer.laptopID,
+		Type:       content.format.extension,
+		Path:       imagePath,
+		Size:       writer.size,
+		UploadedAt: time.Now(),
+		MimeType:   content.format.mimeType,
+		Width:      content.width,
+		Height:     content.height,
+	})
+	if err != nil {
+		os.Remove(imagePath)
+		return "", fmt.Errorf("cannot save image info: %w", err)
 	}
 
 	return imageId.String(), nil
 }
+
+func (writer *diskImageWriter) Abort() error {
+	if writer.file == nil {
+		return nil
+	}
+
+	file := writer.file
+	writer.file = nil
+	file.Close()
+
+	err := os.Remove(file.Name())
+	if err != nil {
+		return fmt.Errorf("cannot remove file: %w", err)
+	}
+
+	return nil
+}
+
+func (store *DiskImageStore) ListLaptopImages(laptopID string, found func(imageID string, info *ImageInfo) error) error {
+	return store.index.List(func(imageID string, info *ImageInfo) error {
+		if info.LaptopID != laptopID {
+			return nil
+		}
+		return found(imageID, info)
+	})
+}
+
+func (store *DiskImageStore) Open(imageID string, variant string) (*ImageInfo, io.ReadCloser, error) {
+	info, err := store.index.Find(imageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if variant != "" {
+		found := info.variant(variant)
+		if found == nil {
+			return nil, nil, fmt.Errorf("%w: no variant %q", ErrImageNotFound, variant)
+		}
+
+		info = &ImageInfo{
+			LaptopID:   info.LaptopID,
+			Type:       found.Type,
+			Path:       found.Path,
+			Size:       found.Size,
+			UploadedAt: info.UploadedAt,
+			MimeType:   found.MimeType,
+			Width:      found.Width,
+			Height:     found.Height,
+		}
+	}
+
+	file, err := os.Open(info.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		// The file was removed while deleting the images of its laptop
+		return nil, nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open file: %w", err)
+	}
+
+	return info, file, nil
+}
+
+// SaveVariant writes the variant next to the image, as a file named after both
+func (store *DiskImageStore) SaveVariant(imageID string, variant *ImageVariant, data io.Reader) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	info, err := store.index.Find(imageID)
+	if err != nil {
+		return err
+	}
+
+	variantPath := variantFilePath(store.imageFolder, imageID, variant.Name, variant.Type)
+	file, err := os.CreateTemp(store.imageFolder, "variant-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, data)
+	if err != nil {
+		return fmt.Errorf("cannot write data to file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("cannot close file: %w", err)
+	}
+
+	err = os.Rename(file.Name(), variantPath)
+	if err != nil {
+		return fmt.Errorf("cannot rename file: %w", err)
+	}
+
+	saved := *variant
+	saved.Path = variantPath
+	saved.Size = size
+
+	// The index may share info, so the variants are updated on a copy
+	updated := *info
+	updated.Variants = []*ImageVariant{}
+	for _, existing := range info.Variants {
+		if existing.Name != variant.Name {
+			updated.Variants = append(updated.Variants, existing)
+		}
+	}
+	updated.Variants = append(updated.Variants, &saved)
+
+	err = store.index.Save(imageID, &updated)
+	if err != nil {
+		os.Remove(variantPath)
+		return fmt.Errorf("cannot save image info: %w", err)
+	}
+
+	return nil
+}
+
+func (store *DiskImageStore) DeleteLaptopImages(laptopID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	images := map[string]*ImageInfo{}
+	err := store.ListLaptopImages(laptopID, func(imageID string, info *ImageInfo) error {
+		images[imageID] = info
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot list images: %w", err)
+	}
+
+	for imageID, info := range images {
+		// The files go first, so a failure leaves the image listed and its deletion is retried
+		paths := []string{info.Path}
+		for _, variant := range info.Variants {
+			paths = append(paths, variant.Path)
+		}
+
+		for _, path := range paths {
+			err := os.Remove(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("cannot remove image file: %w", err)
+			}
+		}
+
+		err = store.index.Delete(imageID)
+		if err != nil {
+			return fmt.Errorf("cannot delete image info: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type InMemoryImageIndex struct {
+	mutex  sync.RWMutex
+	images map[string]*ImageInfo
+}
+
+func NewInMemoryImageIndex() ImageIndex {
+	return &InMemoryImageIndex{
+		images: make(map[string]*ImageInfo),
+	}
+}
+
+func (index *InMemoryImageIndex) Save(imageID string, info *ImageInfo) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.images[imageID] = info
+	return nil
+}
+
+func (index *InMemoryImageIndex) Find(imageID string) (*ImageInfo, error) {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	info := index.images[imageID]
+	if info == nil {
+		return nil, ErrImageNotFound
+	}
+
+	return info, nil
+}
+
+func (index *InMemoryImageIndex) List(found func(imageID string, info *ImageInfo) error) error {
+	index.mutex.RLock()
+	images := make(map[string]*ImageInfo, len(index.images))
+	for imageID, info := range index.images {
+		images[imageID] = info
+	}
+	index.mutex.RUnlock()
+
+	for imageID, info := range images {
+		err := found(imageID, info)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (index *InMemoryImageIndex) Delete(imageID string) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	delete(index.images, imageID)
+	return nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskImageStoreWriter(t *testing.T) {
+	folder := t.TempDir()
+	index := NewInMemoryImageIndex()
+	store := NewDiskImageStoreWithIndex(folder, index)
+
+	writer, err := store.Create("laptop", ".jpg")
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("partial"))
+	require.NoError(t, err)
+
+	// A partial image is removed on abort
+	require.NoError(t, writer.Abort())
+	require.NoError(t, writer.Abort())
+	_, err = writer.Commit()
+	require.ErrorIs(t, err, os.ErrClosed)
+	requireFolderFiles(t, folder)
+
+	image := newTestImage(t, "jpeg", 6, 4)
+	writer, err = store.Create("laptop", ".jpeg")
+	require.NoError(t, err)
+	for _, chunk := range [][]byte{image[:10], image[10:]} {
+		_, err = writer.Write(chunk)
+		require.NoError(t, err)
+	}
+	imageID, err := writer.Commit()
+	require.NoError(t, err)
+
+	// Aborting a committed image keeps it
+	require.NoError(t, writer.Abort())
+	requireFolderFiles(t, folder, imageID+".jpg")
+
+	info, err := index.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(image)), info.Size)
+	require.Equal(t, ".jpg", info.Type)
+	require.Equal(t, "image/jpeg", info.MimeType)
+	require.Equal(t, 6, info.Width)
+	require.Equal(t, 4, info.Height)
+	data, err := os.ReadFile(info.Path)
+	require.NoError(t, err)
+	require.Equal(t, image, data)
+
+	otherID, err := store.Save("laptop", "", bytes.NewReader(testWebP))
+	require.NoError(t, err)
+	requireFolderFiles(t, folder, imageID+".jpg", otherID+".webp")
+
+	// Unsafe types are rejected before writing, content not matching its type when committing
+	_, err = store.Create("laptop", "/../../etc/x")
+	require.ErrorIs(t, err, ErrInvalidImage)
+	_, err = store.Save("laptop", ".png", bytes.NewReader(image))
+	require.ErrorIs(t, err, ErrInvalidImage)
+	requireFolderFiles(t, folder, imageID+".jpg", otherID+".webp")
+}
+
+// requireFolderFiles checks the names of the files in folder
+func requireFolderFiles(t *testing.T, folder string, names ...string) {
+	entries, err := os.ReadDir(folder)
+	require.NoError(t, err)
+
+	found := []string{}
+	for _, entry := range entries {
+		found = append(found, entry.Name())
+	}
+	require.ElementsMatch(t, names, found)
+}
+
+func TestDiskImageStoreRemovesTempImages(t *testing.T) {
+	folder := t.TempDir()
+	for _, name := range []string{"upload-1.tmp", "variant-2.tmp", "image.jpg"} {
+		require.NoError(t, os.WriteFile(filepath.Join(folder, name), []byte("data"), 0600))
+	}
+
+	// The temporary files of a previous run are removed, the images are kept
+	NewDiskImageStore(folder)
+	requireFolderFiles(t, folder, "image.jpg")
+}
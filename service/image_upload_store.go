@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultImageUploadTTL is how long a resumable upload is kept after its last chunk
+const DefaultImageUploadTTL = 24 * time.Hour
+
+const (
+	// maxOpenImageUploads is the number of uploads that may be in progress at the same time,
+	// each of them keeps a temporary file open
+	maxOpenImageUploads = 100
+	// imageUploadSweepInterval is how often the expired uploads are removed, at most
+	imageUploadSweepInterval = time.Minute
+)
+
+var (
+	// ErrUploadNotFound is returned when an upload does not exist or expired
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrUploadOffsetMismatch is returned when a chunk does not start at the committed offset
+	ErrUploadOffsetMismatch = errors.New("chunk offset does not match the committed offset")
+	// ErrUploadChecksumMismatch is returned when a chunk or the whole image does not match its checksum
+	ErrUploadChecksumMismatch = errors.New("checksum does not match the data")
+	// ErrUploadFinished is returned when a chunk is sent to a finished upload
+	ErrUploadFinished = errors.New("upload is already finished")
+	// ErrImageTooBig is returned when an upload goes over the maximum image size
+	ErrImageTooBig = errors.New("image is too big")
+	// ErrTooManyUploads is returned when an upload is started while too many are in progress
+	ErrTooManyUploads = errors.New("too many uploads in progress")
+)
+
+// castagnoliTable computes the CRC-32C checksums of the chunks
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// imageUploadStore keeps the resumable uploads in progress, writing their chunks
+// to an ImageWriter committed once the whole image is verified.
+// The uploads live in the memory of the instance that started them, so behind a load balancer
+// all the calls of an upload must reach that instance: the client sets the upload-route
+// metadata to the laptop id, and nginx.conf routes by it.
+type imageUploadStore struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	maxSize int64
+	maxOpen int
+	uploads map[string]*imageUpload
+	// open is the number of uploads not finished yet
+	open int
+	// sweeper removes the expired uploads while there are some
+	sweeper *time.Timer
+	now     func() time.Time
+}
+
+type imageUpload struct {
+	mutex     sync.Mutex
+	laptopID  string
+	imageType string
+	writer    ImageWriter
+	// hash is the SHA-256 of the data received so far
+	hash      hash.Hash
+	offset    int64
+	expiresAt time.Time
+	// imageID and digest are set once the upload is finished
+	imageID string
+	digest  []byte
+}
+
+// imageUploadStatus is the progress of a resumable upload
+type imageUploadStatus struct {
+	LaptopID  string
+	ImageType string
+	Offset    int64
+	ExpiresAt time.Time
+	// ImageID is set once the upload is finished
+	ImageID string
+}
+
+func newImageUploadStore(ttl time.Duration, maxSize int64) *imageUploadStore {
+	return &imageUploadStore{
+		ttl:     ttl,
+		maxSize: maxSize,
+		maxOpen: maxOpenImageUploads,
+		uploads: make(map[string]*imageUpload),
+		now:     time.Now,
+	}
+}
+
+// start begins an upload writing to writer and returns its id and expiry.
+// It returns ErrTooManyUploads if the maximum number of uploads are in progress.
+func (store *imageUploadStore) start(laptopID string, imageType string, writer ImageWriter) (string, time.Time, error) {
+	uploadID, err := uuid.NewRandom()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("cannot generate upload id: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	if store.open >= store.maxOpen {
+		store.sweep(now)
+		if store.open >= store.maxOpen {
+			return "", time.Time{}, ErrTooManyUploads
+		}
+	}
+
+	upload := &imageUpload{
+		laptopID:  laptopID,
+		imageType: imageType,
+		writer:    writer,
+		hash:      sha256.New(),
+		expiresAt: now.Add(store.ttl),
+	}
+	store.uploads[uploadID.String()] = upload
+	store.open++
+	store.scheduleSweep()
+
+	return uploadID.String(), upload.expiresAt, nil
+}
+
+// find returns the upload with the id, locked, or nil if it does not exist or expired
+func (store *imageUploadStore) find(uploadID string) *imageUpload {
+	store.mutex.Lock()
+	upload := store.uploads[uploadID]
+	store.mutex.Unlock()
+
+	if upload == nil {
+		return nil
+	}
+
+	upload.mutex.Lock()
+	if !store.now().Before(upload.expiresAt) {
+		upload.mutex.Unlock()
+		return nil
+	}
+
+	return upload
+}
+
+// write appends a chunk at offset and returns the committed offset. A chunk received
+// already, resent because its response was lost, is acknowledged without being written again.
+func (store *imageUploadStore) write(uploadID string, offset int64, data []byte, checksum uint32) (int64, error) {
+	if crc32.Checksum(data, castagnoliTable) != checksum {
+		return 0, ErrUploadChecksumMismatch
+	}
+
+	upload := store.find(uploadID)
+	if upload == nil {
+		return 0, ErrUploadNotFound
+	}
+	defer upload.mutex.Unlock()
+
+	if upload.imageID != "" {
+		return upload.offset, ErrUploadFinished
+	}
+	if offset >= 0 && offset+int64(len(data)) <= upload.offset {
+		return upload.offset, nil
+	}
+	if offset != upload.offset {
+		return upload.offset, ErrUploadOffsetMismatch
+	}
+	if upload.offset+int64(len(data)) > store.maxSize {
+		return upload.offset, ErrImageTooBig
+	}
+
+	// A failed write may leave part of the chunk in the file, so the upload is dropped
+	_, err := upload.writer.Write(data)
+	if err != nil {
+		store.remove(uploadID, upload)
+		return upload.offset, fmt.Errorf("cannot write chunk: %w", err)
+	}
+
+	upload.hash.Write(data)
+	upload.offset += int64(len(data))
+	upload.expiresAt = store.now().Add(store.ttl)
+
+	return upload.offset, nil
+}
+
+// status returns the progress of the upload
+func (store *imageUploadStore) status(uploadID string) (*imageUploadStatus, error) {
+	upload := store.find(uploadID)
+	if upload == nil {
+		return nil, ErrUploadNotFound
+	}
+	defer upload.mutex.Unlock()
+
+	status := &imageUploadStatus{
+		LaptopID:  upload.laptopID,
+		ImageType: upload.imageType,
+		Offset:    upload.offset,
+		ExpiresAt: upload.expiresAt,
+		ImageID:   upload.imageID,
+	}
+
+	return status, nil
+}
+
+// finish commits the image if its SHA-256 matches digest and returns the image id and size.
+// A mismatching image is discarded. Finishing a finished upload again returns the same image.
+func (store *imageUploadStore) finish(uploadID string, digest []byte) (string, int64, error) {
+	upload := store.find(uploadID)
+	if upload == nil {
+		return "", 0, ErrUploadNotFound
+	}
+	defer upload.mutex.Unlock()
+
+	if upload.imageID != "" {
+		if !bytes.Equal(upload.digest, digest) {
+			return "", 0, ErrUploadChecksumMismatch
+		}
+		return upload.imageID, upload.offset, nil
+	}
+
+	if !bytes.Equal(upload.hash.Sum(nil), digest) {
+		store.remove(uploadID, upload)
+		return "", 0, ErrUploadChecksumMismatch
+	}
+
+	imageID, err := upload.writer.Commit()
+	if err != nil {
+		store.remove(uploadID, upload)
+		return "", 0, err
+	}
+
+	// The finished upload is kept until it expires, so a lost response can be fetched again
+	upload.imageID = imageID
+	upload.digest = digest
+	upload.expiresAt = store.now().Add(store.ttl)
+
+	store.mutex.Lock()
+	store.open--
+	store.mutex.Unlock()
+
+	return imageID, upload.offset, nil
+}
+
+// remove discards an unfinished upload locked by the caller
+func (store *imageUploadStore) remove(uploadID string, upload *imageUpload) {
+	upload.writer.Abort()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.uploads[uploadID] == upload {
+		delete(store.uploads, uploadID)
+		store.open--
+	}
+}
+
+// sweep discards the expired uploads. The caller holds the store mutex.
+func (store *imageUploadStore) sweep(now time.Time) {
+	for uploadID, upload := range store.uploads {
+		// An upload in use is left to the next sweep
+		if !upload.mutex.TryLock() {
+			continue
+		}
+		if !now.Before(upload.expiresAt) {
+			if upload.imageID == "" {
+				upload.writer.Abort()
+				store.open--
+			}
+			delete(store.uploads, uploadID)
+		}
+		upload.mutex.Unlock()
+	}
+}
+
+// scheduleSweep starts the timer sweeping the expired uploads, if there are uploads
+// and it is not running. The caller holds the store mutex.
+func (store *imageUploadStore) scheduleSweep() {
+	if store.sweeper != nil || len(store.uploads) == 0 {
+		return
+	}
+
+	interval := imageUploadSweepInterval
+	if store.ttl < interval {
+		interval = store.ttl
+	}
+
+	store.sweeper = time.AfterFunc(interval, func() {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+
+		store.sweeper = nil
+		store.sweep(store.now())
+		store.scheduleSweep()
+	})
+}
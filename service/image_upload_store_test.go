@@ -0,0 +1,195 @@
+package service
+
+import (
+	"crypto/sha256"
+	"hash/crc32"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageUploadStore(t *testing.T) {
+	folder := t.TempDir()
+	imageStore := NewDiskImageStore(folder)
+	image := newTestImage(t, "png", 4, 4)
+	store := newImageUploadStore(time.Hour, int64(len(image)))
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	writer, err := imageStore.Create("laptop", ".png")
+	require.NoError(t, err)
+	uploadID, expiresAt, err := store.start("laptop", ".png", writer)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Hour), expiresAt)
+
+	write := func(offset int, data []byte) (int64, error) {
+		return store.write(uploadID, int64(offset), data, crc32.Checksum(data, castagnoliTable))
+	}
+
+	offset, err := write(0, image[:10])
+	require.NoError(t, err)
+	require.Equal(t, int64(10), offset)
+
+	// A chunk sent again is acknowledged, a gap or an overlap is rejected
+	offset, err = write(0, image[:10])
+	require.NoError(t, err)
+	require.Equal(t, int64(10), offset)
+	_, err = write(11, image[11:])
+	require.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	_, err = write(8, image[8:])
+	require.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	_, err = store.write(uploadID, 10, image[10:], 0)
+	require.ErrorIs(t, err, ErrUploadChecksumMismatch)
+	_, err = write(10, append(image[10:len(image):len(image)], 0))
+	require.ErrorIs(t, err, ErrImageTooBig)
+
+	offset, err = write(10, image[10:])
+	require.NoError(t, err)
+	require.Equal(t, int64(len(image)), offset)
+
+	status, err := store.status(uploadID)
+	require.NoError(t, err)
+	expected := &imageUploadStatus{
+		LaptopID:  "laptop",
+		ImageType: ".png",
+		Offset:    int64(len(image)),
+		ExpiresAt: now.Add(time.Hour),
+	}
+	require.Equal(t, expected, status)
+
+	digest := sha256.Sum256(image)
+	imageID, size, err := store.finish(uploadID, digest[:])
+	require.NoError(t, err)
+	require.Equal(t, int64(len(image)), size)
+	requireFolderFiles(t, folder, imageID+".png")
+
+	// Finishing again returns the same image
+	finishedID, _, err := store.finish(uploadID, digest[:])
+	require.NoError(t, err)
+	require.Equal(t, imageID, finishedID)
+	_, err = write(len(image), []byte{0})
+	require.ErrorIs(t, err, ErrUploadFinished)
+
+	_, err = store.status("unknown")
+	require.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestImageUploadStoreDiscard(t *testing.T) {
+	folder := t.TempDir()
+	imageStore := NewDiskImageStore(folder)
+	store := newImageUploadStore(time.Hour, 1<<20)
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	start := func(data []byte) string {
+		writer, err := imageStore.Create("laptop", "")
+		require.NoError(t, err)
+		uploadID, _, err := store.start("laptop", "", writer)
+		require.NoError(t, err)
+		_, err = store.write(uploadID, 0, data, crc32.Checksum(data, castagnoliTable))
+		require.NoError(t, err)
+		return uploadID
+	}
+
+	// An image not matching its digest is discarded
+	uploadID := start(newTestImage(t, "gif", 2, 2))
+	digest := sha256.Sum256([]byte("other"))
+	_, _, err := store.finish(uploadID, digest[:])
+	require.ErrorIs(t, err, ErrUploadChecksumMismatch)
+	_, err = store.status(uploadID)
+	require.ErrorIs(t, err, ErrUploadNotFound)
+	requireFolderFiles(t, folder)
+
+	// So is a file that is not an image
+	data := []byte("not an image")
+	uploadID = start(data)
+	digest = sha256.Sum256(data)
+	_, _, err = store.finish(uploadID, digest[:])
+	require.ErrorIs(t, err, ErrInvalidImage)
+	_, err = store.status(uploadID)
+	require.ErrorIs(t, err, ErrUploadNotFound)
+	requireFolderFiles(t, folder)
+
+	// An expired upload is discarded by the next sweep
+	uploadID = start(data)
+	now = now.Add(time.Hour)
+	_, err = store.status(uploadID)
+	require.ErrorIs(t, err, ErrUploadNotFound)
+
+	entries, err := os.ReadDir(folder)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	store.mutex.Lock()
+	store.sweep(now)
+	store.mutex.Unlock()
+	requireFolderFiles(t, folder)
+	require.Empty(t, store.uploads)
+	require.Zero(t, store.open)
+}
+
+func TestImageUploadStoreLimit(t *testing.T) {
+	imageStore := NewDiskImageStore(t.TempDir())
+	image := newTestImage(t, "png", 2, 2)
+	store := newImageUploadStore(time.Hour, 1<<20)
+	store.maxOpen = 2
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	start := func() (string, error) {
+		writer, err := imageStore.Create("laptop", "")
+		require.NoError(t, err)
+		uploadID, _, err := store.start("laptop", "", writer)
+		if err != nil {
+			writer.Abort()
+		}
+		return uploadID, err
+	}
+
+	uploadID, err := start()
+	require.NoError(t, err)
+	_, err = start()
+	require.NoError(t, err)
+	_, err = start()
+	require.ErrorIs(t, err, ErrTooManyUploads)
+
+	// A finished upload no longer counts
+	_, err = store.write(uploadID, 0, image, crc32.Checksum(image, castagnoliTable))
+	require.NoError(t, err)
+	digest := sha256.Sum256(image)
+	_, _, err = store.finish(uploadID, digest[:])
+	require.NoError(t, err)
+	_, err = start()
+	require.NoError(t, err)
+	_, err = start()
+	require.ErrorIs(t, err, ErrTooManyUploads)
+
+	// Nor do the expired ones
+	now = now.Add(time.Hour)
+	_, err = start()
+	require.NoError(t, err)
+}
+
+func TestImageUploadStoreSweeper(t *testing.T) {
+	folder := t.TempDir()
+	imageStore := NewDiskImageStore(folder)
+	store := newImageUploadStore(50*time.Millisecond, 1<<20)
+
+	writer, err := imageStore.Create("laptop", "")
+	require.NoError(t, err)
+	_, _, err = store.start("laptop", "", writer)
+	require.NoError(t, err)
+
+	// The expired upload is removed without any other call to the store
+	require.Eventually(t, func() bool {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		return len(store.uploads) == 0 && store.sweeper == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	requireFolderFiles(t, folder)
+}
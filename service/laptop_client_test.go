@@ -36,8 +36,11 @@ func TestClientCreateLaptop(t *testing.T) {
 }
 
 func startTestLaptopServer(t *testing.T, laptopStore LaptopStore) string {
-	laptopServer := NewLaptopServer(laptopStore, nil, nil)
+	return serveTestLaptopServer(t, NewLaptopServer(laptopStore, nil, nil))
+}
 
+// serveTestLaptopServer serves laptopServer on a random port and returns its address
+func serveTestLaptopServer(t *testing.T, laptopServer pb.LaptopServiceServer) string {
 	grpcServer := grpc.NewServer()
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
 
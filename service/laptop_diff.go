@@ -0,0 +1,173 @@
+package service
+
+import (
+	"fmt"
+	"gobook/pb"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timestampName is the message formatted as a time rather than compared field by field
+const timestampName protoreflect.FullName = "google.protobuf.Timestamp"
+
+// diffLaptops returns the fields that differ between two versions of a laptop,
+// in field number order, going into the nested messages and lists
+func diffLaptops(oldLaptop *pb.Laptop, newLaptop *pb.Laptop) []*pb.FieldChange {
+	return diffMessages("", oldLaptop.ProtoReflect(), newLaptop.ProtoReflect())
+}
+
+func diffMessages(prefix string, oldMessage protoreflect.Message, newMessage protoreflect.Message) []*pb.FieldChange {
+	changes := []*pb.FieldChange{}
+
+	fields := oldMessage.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := string(field.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		changes = append(changes, diffField(path, field, oldMessage, newMessage)...)
+	}
+
+	return changes
+}
+
+func diffField(
+	path string,
+	field protoreflect.FieldDescriptor,
+	oldMessage protoreflect.Message,
+	newMessage protoreflect.Message,
+) []*pb.FieldChange {
+	oldSet := oldMessage.Has(field)
+	newSet := newMessage.Has(field)
+	if !oldSet && !newSet {
+		return nil
+	}
+
+	oldValue := oldMessage.Get(field)
+	newValue := newMessage.Get(field)
+
+	switch {
+	case field.IsList():
+		return diffLists(path, field, oldValue.List(), newValue.List())
+	case isNestedMessage(field) && oldSet && newSet:
+		return diffMessages(path, oldValue.Message(), newValue.Message())
+	}
+
+	oldText := formatFieldValue(field, oldValue, oldSet)
+	newText := formatFieldValue(field, newValue, newSet)
+	if oldText == newText {
+		return nil
+	}
+
+	return []*pb.FieldChange{{Path: path, OldValue: oldText, NewValue: newText}}
+}
+
+func diffLists(
+	path string,
+	field protoreflect.FieldDescriptor,
+	oldList protoreflect.List,
+	newList protoreflect.List,
+) []*pb.FieldChange {
+	changes := []*pb.FieldChange{}
+
+	for i := 0; i < oldList.Len() || i < newList.Len(); i++ {
+		elementPath := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+		case i >= oldList.Len():
+			changes = append(changes, &pb.FieldChange{Path: elementPath, NewValue: formatValue(field, newList.Get(i))})
+		case i >= newList.Len():
+			changes = append(changes, &pb.FieldChange{Path: elementPath, OldValue: formatValue(field, oldList.Get(i))})
+		case isNestedMessage(field):
+			changes = append(changes, diffMessages(elementPath, oldList.Get(i).Message(), newList.Get(i).Message())...)
+		default:
+			oldText := formatValue(field, oldList.Get(i))
+			newText := formatValue(field, newList.Get(i))
+			if oldText != newText {
+				changes = append(changes, &pb.FieldChange{Path: elementPath, OldValue: oldText, NewValue: newText})
+			}
+		}
+	}
+
+	return changes
+}
+
+// isNestedMessage tells if the field is a message compared field by field
+func isNestedMessage(field protoreflect.FieldDescriptor) bool {
+	return field.Message() != nil && field.Message().FullName() != timestampName
+}
+
+// formatFieldValue formats the value of a field, or returns an empty string if the field tracks
+// its presence and is not set. Proto3 scalars are formatted even when zero.
+func formatFieldValue(field protoreflect.FieldDescriptor, value protoreflect.Value, set bool) string {
+	if !set && field.HasPresence() {
+		return ""
+	}
+
+	if field.IsList() {
+		elements := []string{}
+		for i := 0; i < value.List().Len(); i++ {
+			elements = append(elements, formatValue(field, value.List().Get(i)))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	}
+
+	if field.IsMap() {
+		entries := []string{}
+		value.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			entries = append(entries, key.String()+": "+formatValue(field.MapValue(), value))
+			return true
+		})
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ", ") + "}"
+	}
+
+	return formatValue(field, value)
+}
+
+// formatValue formats a single value in text format: strings are quoted, enums named,
+// timestamps in RFC 3339 and messages as their set fields in braces
+func formatValue(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return strconv.Quote(value.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(string(value.Bytes()))
+	case protoreflect.EnumKind:
+		enumValue := field.Enum().Values().ByNumber(value.Enum())
+		if enumValue == nil {
+			return strconv.Itoa(int(value.Enum()))
+		}
+		return string(enumValue.Name())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return formatMessage(value.Message())
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+func formatMessage(message protoreflect.Message) string {
+	if timestamp, ok := message.Interface().(*timestamppb.Timestamp); ok {
+		return timestamp.AsTime().Format(time.RFC3339Nano)
+	}
+
+	fields := message.Descriptor().Fields()
+	parts := []string{}
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !message.Has(field) {
+			continue
+		}
+
+		parts = append(parts, string(field.Name())+": "+formatFieldValue(field, message.Get(field), true))
+	}
+
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"gobook/pb"
+	"gobook/sample"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDiffLaptops(t *testing.T) {
+	laptop := sample.NewLaptop()
+	laptop.Brand = "Dell"
+	laptop.Cpu.NumberCores = 4
+	laptop.Weight = &pb.Laptop_WeightKg{WeightKg: 1.5}
+	laptop.Storages = laptop.Storages[:1]
+	laptop.Storages[0].Memory.Value = 256
+
+	testCases := []struct {
+		name    string
+		modify  func(laptop *pb.Laptop)
+		changes []*pb.FieldChange
+	}{
+		{
+			name:   "same",
+			modify: func(laptop *pb.Laptop) {},
+		},
+		{
+			name: "scalars",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Brand = "Apple"
+				laptop.Cpu.NumberCores = 8
+			},
+			changes: []*pb.FieldChange{
+				{Path: "brand", OldValue: `"Dell"`, NewValue: `"Apple"`},
+				{Path: "cpu.number_cores", OldValue: "4", NewValue: "8"},
+			},
+		},
+		{
+			name: "oneof",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 3}
+			},
+			changes: []*pb.FieldChange{
+				{Path: "weight_kg", OldValue: "1.5", NewValue: ""},
+				{Path: "weight_lb", OldValue: "", NewValue: "3"},
+			},
+		},
+		{
+			name: "list",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Storages[0].Memory.Value = 512
+				laptop.Storages = append(laptop.Storages, &pb.Storage{
+					Driver: pb.Storage_HDD,
+					Memory: &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE},
+				})
+			},
+			changes: []*pb.FieldChange{
+				{Path: "storages[0].memory.value", OldValue: "256", NewValue: "512"},
+				{Path: "storages[1]", OldValue: "", NewValue: "{driver: HDD, memory: {value: 1, unit: TERABYTE}}"},
+			},
+		},
+		{
+			name: "message_removed",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Keyboard = nil
+			},
+			changes: []*pb.FieldChange{
+				{Path: "keyboard", OldValue: formatMessage(laptop.Keyboard.ProtoReflect()), NewValue: ""},
+			},
+		},
+		{
+			name: "timestamp",
+			modify: func(laptop *pb.Laptop) {
+				laptop.DeletedAt = &timestamppb.Timestamp{Seconds: 1700000000}
+			},
+			changes: []*pb.FieldChange{
+				{Path: "deleted_at", OldValue: "", NewValue: "2023-11-14T22:13:20Z"},
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			modified := proto.Clone(laptop).(*pb.Laptop)
+			tc.modify(modified)
+
+			changes := diffLaptops(laptop, modified)
+			require.Len(t, changes, len(tc.changes))
+			for i, change := range changes {
+				require.True(t, proto.Equal(tc.changes[i], change), "got %v", change)
+			}
+		})
+	}
+}
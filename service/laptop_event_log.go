@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gobook/pb"
+	"sync"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// laptopEventHistory is how many events are kept for watchers to resume from
+const laptopEventHistory = 1024
+
+// ErrEventsExpired is returned when a watch resumes after an event that is no longer kept
+var ErrEventsExpired = errors.New("laptop events to resume from have expired")
+
+// changeNotifier wakes up the goroutines waiting for the next change of a store
+type changeNotifier struct {
+	mutex sync.Mutex
+	// changed is closed and replaced on every change
+	changed chan struct{}
+}
+
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{
+		changed: make(chan struct{}),
+	}
+}
+
+// wait returns a channel closed on the next change
+func (notifier *changeNotifier) wait() <-chan struct{} {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	return notifier.changed
+}
+
+func (notifier *changeNotifier) notify() {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	close(notifier.changed)
+	notifier.changed = make(chan struct{})
+}
+
+// watchLaptopEvents calls found with the events returned by since, then waits for the
+// notifier to read the next ones, until the context is done
+func watchLaptopEvents(
+	ctx context.Context,
+	afterSequence uint64,
+	notifier *changeNotifier,
+	since func(afterSequence uint64) ([]*pb.LaptopEvent, error),
+	found func(event *pb.LaptopEvent) error,
+) error {
+	for {
+		// Wait on the channel taken before reading, so no change can be missed in between
+		changed := notifier.wait()
+
+		events, err := since(afterSequence)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			err := found(event)
+			if err != nil {
+				return err
+			}
+			afterSequence = event.GetSequence()
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// laptopEventLog numbers the changes of a laptop store and keeps the latest ones,
+// so that watchers can catch up after reconnecting
+type laptopEventLog struct {
+	mutex    sync.Mutex
+	capacity int
+	events   []*pb.LaptopEvent
+	sequence uint64
+	notifier *changeNotifier
+}
+
+func newLaptopEventLog(capacity int) *laptopEventLog {
+	return &laptopEventLog{
+		capacity: capacity,
+		notifier: newChangeNotifier(),
+	}
+}
+
+// append records a change of the laptop and wakes up the watchers
+func (events *laptopEventLog) append(eventType pb.LaptopEvent_Type, laptop *pb.Laptop) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	events.sequence++
+	event := &pb.LaptopEvent{
+		Sequence: events.sequence,
+		Type:     eventType,
+		Laptop:   laptop,
+		Time:     timestamppb.Now(),
+	}
+
+	if len(events.events) == events.capacity {
+		events.events = append(events.events[:0], events.events[1:]...)
+	}
+	events.events = append(events.events, event)
+
+	events.notifier.notify()
+}
+
+// current returns the sequence of the last event
+func (events *laptopEventLog) current() uint64 {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	return events.sequence
+}
+
+// resume continues the numbering after the given sequence, whose events are not kept,
+// so watchers resuming before it get ErrEventsExpired
+func (events *laptopEventLog) resume(sequence uint64) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	if sequence > events.sequence {
+		events.sequence = sequence
+		events.events = nil
+		events.notifier.notify()
+	}
+}
+
+// since returns the events after the given sequence
+func (events *laptopEventLog) since(afterSequence uint64) ([]*pb.LaptopEvent, error) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	first := events.sequence + 1
+	if len(events.events) > 0 {
+		first = events.events[0].Sequence
+	}
+
+	// A sequence ahead of ours comes from before a restart of the store
+	if afterSequence+1 < first || afterSequence > events.sequence {
+		return nil, ErrEventsExpired
+	}
+
+	start := len(events.events) - int(events.sequence-afterSequence)
+	if start < 0 {
+		start = 0
+	}
+
+	pending := make([]*pb.LaptopEvent, len(events.events)-start)
+	copy(pending, events.events[start:])
+
+	return pending, nil
+}
+
+// watch calls found with every event after the given sequence, then with the new ones
+// as they happen, until the context is done. A zero sequence starts from the current one.
+func (events *laptopEventLog) watch(
+	ctx context.Context,
+	afterSequence uint64,
+	found func(event *pb.LaptopEvent) error,
+) error {
+	if afterSequence == 0 {
+		events.mutex.Lock()
+		afterSequence = events.sequence
+		events.mutex.Unlock()
+	}
+
+	return watchLaptopEvents(ctx, afterSequence, events.notifier, events.since, found)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"gobook/pb"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const defaultPriceBucketUsd = 500
+
+// computeLaptopFacets counts the laptops per brand, CPU brand, RAM size and screen panel,
+// and builds a histogram of their prices with buckets of the given width
+func computeLaptopFacets(laptops []*pb.Laptop, priceBucketUsd float64) *pb.SearchFacetsResponse {
+	if priceBucketUsd <= 0 {
+		priceBucketUsd = defaultPriceBucketUsd
+	}
+
+	brands := make(map[string]uint32)
+	cpuBrands := make(map[string]uint32)
+	ramSizes := make(map[string]uint32)
+	screenPanels := make(map[string]uint32)
+	prices := make(map[int64]uint32)
+
+	for _, laptop := range laptops {
+		brands[laptop.GetBrand()]++
+		cpuBrands[laptop.GetCpu().GetBrand()]++
+		ramSizes[ramSizeBucket(laptop.GetRam())]++
+		screenPanels[laptop.GetScreen().GetPanel().String()]++
+		prices[int64(math.Floor(laptop.GetPriceUsd()/priceBucketUsd))]++
+	}
+
+	return &pb.SearchFacetsResponse{
+		TotalCount:     uint32(len(laptops)),
+		Brands:         facetCounts(brands),
+		CpuBrands:      facetCounts(cpuBrands),
+		RamSizes:       facetCounts(ramSizes),
+		ScreenPanels:   facetCounts(screenPanels),
+		PriceHistogram: priceHistogram(prices, priceBucketUsd),
+	}
+}
+
+// ramSizeBucket rounds the memory up to the next power of two gigabytes, like "8GB"
+func ramSizeBucket(memory *pb.Memory) string {
+	const gigabyte = 1 << 33
+
+	// Round up to whole gigabytes without overflowing, then to the power of two
+	ram := toBit(memory)
+	gigabytes := ram / gigabyte
+	if ram%gigabyte != 0 {
+		gigabytes++
+	}
+	if gigabytes <= 1 {
+		return "1GB"
+	}
+
+	return fmt.Sprintf("%dGB", uint64(1)<<bits.Len64(gigabytes-1))
+}
+
+// facetCounts sorts the counts from the most to the least frequent value
+func facetCounts(counts map[string]uint32) []*pb.FacetCount {
+	facets := make([]*pb.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, &pb.FacetCount{
+			Value: value,
+			Count: count,
+		})
+	}
+
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+
+	return facets
+}
+
+// priceHistogram returns the non-empty price buckets from the cheapest to the most expensive
+func priceHistogram(counts map[int64]uint32, priceBucketUsd float64) []*pb.PriceBucket {
+	buckets := make([]*pb.PriceBucket, 0, len(counts))
+	for index, count := range counts {
+		buckets = append(buckets, &pb.PriceBucket{
+			MinPriceUsd: float64(index) * priceBucketUsd,
+			MaxPriceUsd: float64(index+1) * priceBucketUsd,
+			Count:       count,
+		})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].MinPriceUsd < buckets[j].MinPriceUsd
+	})
+
+	return buckets
+}
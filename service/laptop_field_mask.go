@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"gobook/pb"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// immutableLaptopPaths are laptop fields that cannot be changed by an update mask.
+// deleted_at is only changed by deleting and undeleting the laptop.
+var immutableLaptopPaths = []string{"id", "updated_at", "revision", "deleted_at"}
+
+// validateLaptopFieldMask checks that every path of the mask exists in the Laptop message
+// and is allowed to be updated
+func validateLaptopFieldMask(mask *fieldmaskpb.FieldMask) error {
+	if !mask.IsValid(&pb.Laptop{}) {
+		return fmt.Errorf("update mask %v contains unknown laptop fields", mask.GetPaths())
+	}
+
+	for _, path := range mask.GetPaths() {
+		for _, immutable := range immutableLaptopPaths {
+			if path == immutable || strings.HasPrefix(path, immutable+".") {
+				return fmt.Errorf("field %s cannot be updated", path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyLaptopFieldMask copies the fields listed in the mask from src into dst.
+// A listed field that is not set in src is cleared in dst.
+func applyLaptopFieldMask(dst *pb.Laptop, src *pb.Laptop, mask *fieldmaskpb.FieldMask) {
+	src = proto.Clone(src).(*pb.Laptop)
+
+	for _, path := range mask.GetPaths() {
+		names := strings.Split(path, ".")
+
+		dstMessage := dst.ProtoReflect()
+		srcMessage := src.ProtoReflect()
+
+		for _, name := range names[:len(names)-1] {
+			field := dstMessage.Descriptor().Fields().ByName(protoreflect.Name(name))
+			dstMessage = dstMessage.Mutable(field).Message()
+			srcMessage = srcMessage.Get(field).Message()
+		}
+
+		field := dstMessage.Descriptor().Fields().ByName(protoreflect.Name(names[len(names)-1]))
+		if srcMessage.Has(field) {
+			dstMessage.Set(field, srcMessage.Get(field))
+		} else {
+			dstMessage.Clear(field)
+		}
+	}
+}
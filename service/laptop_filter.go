@@ -0,0 +1,235 @@
+package service
+
+import (
+	"gobook/pb"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// isQualified reports whether the laptop matches the filter.
+// Unset filter fields do not constrain the result.
+func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.MaxPriceUsd != nil && laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
+		return false
+	}
+
+	if filter.MinPriceUsd != nil && laptop.GetPriceUsd() < filter.GetMinPriceUsd() {
+		return false
+	}
+
+	if filter.MinCpuCores != nil && laptop.GetCpu().GetNumberCores() < filter.GetMinCpuCores() {
+		return false
+	}
+
+	if filter.MinCpuGhz != nil && laptop.GetCpu().GetMinGhz() < filter.GetMinCpuGhz() {
+		return false
+	}
+
+	if filter.MinRam != nil && toBit(laptop.GetRam()) < toBit(filter.GetMinRam()) {
+		return false
+	}
+
+	if len(filter.GetBrands()) > 0 && !containsFold(filter.GetBrands(), laptop.GetBrand()) {
+		return false
+	}
+
+	if filter.Name != nil && !strings.Contains(strings.ToLower(laptop.GetName()), strings.ToLower(filter.GetName())) {
+		return false
+	}
+
+	if filter.MinGpuMemory != nil && maxGPUMemory(laptop) < toBit(filter.GetMinGpuMemory()) {
+		return false
+	}
+
+	if filter.SsdOnly != nil && isSSDOnly(laptop) != filter.GetSsdOnly() {
+		return false
+	}
+
+	if filter.MinTotalStorage != nil && totalStorage(laptop) < toBit(filter.GetMinTotalStorage()) {
+		return false
+	}
+
+	if !isScreenQualified(filter, laptop.GetScreen()) {
+		return false
+	}
+
+	if filter.MaxWeightKg != nil && weightKg(laptop) > filter.GetMaxWeightKg() {
+		return false
+	}
+
+	if filter.MinReleaseYear != nil && laptop.GetReleaseYear() < filter.GetMinReleaseYear() {
+		return false
+	}
+
+	if filter.MaxReleaseYear != nil && laptop.GetReleaseYear() > filter.GetMaxReleaseYear() {
+		return false
+	}
+
+	if !isKeyboardQualified(filter, laptop.GetKeyboard()) {
+		return false
+	}
+
+	return true
+}
+
+func isScreenQualified(filter *pb.Filter, screen *pb.Screen) bool {
+	if filter.MinScreenSizeInch != nil && screen.GetSizeInch() < filter.GetMinScreenSizeInch() {
+		return false
+	}
+
+	if filter.MaxScreenSizeInch != nil && screen.GetSizeInch() > filter.GetMaxScreenSizeInch() {
+		return false
+	}
+
+	if screen.GetResolution().GetWidth() < filter.GetMinScreenResolution().GetWidth() ||
+		screen.GetResolution().GetHeight() < filter.GetMinScreenResolution().GetHeight() {
+		return false
+	}
+
+	if len(filter.GetScreenPanels()) > 0 && !containsPanel(filter.GetScreenPanels(), screen.GetPanel()) {
+		return false
+	}
+
+	if filter.Multitouch != nil && screen.GetMultitouch() != filter.GetMultitouch() {
+		return false
+	}
+
+	return true
+}
+
+func isKeyboardQualified(filter *pb.Filter, keyboard *pb.Keyboard) bool {
+	if len(filter.GetKeyboardLayouts()) > 0 && !containsLayout(filter.GetKeyboardLayouts(), keyboard.GetLayout()) {
+		return false
+	}
+
+	if filter.KeyboardBacklit != nil && keyboard.GetBacklit() != filter.GetKeyboardBacklit() {
+		return false
+	}
+
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// foldKey returns the same key for the strings strings.EqualFold finds equal,
+// so stores can compare them by equality
+func foldKey(value string) string {
+	return strings.Map(func(r rune) rune {
+		// The smallest rune of its case folding orbit stands for all of them
+		key := r
+		for folded := unicode.SimpleFold(r); folded != r; folded = unicode.SimpleFold(folded) {
+			if folded < key {
+				key = folded
+			}
+		}
+		return key
+	}, value)
+}
+
+func containsPanel(panels []pb.Screen_Panel, panel pb.Screen_Panel) bool {
+	for _, p := range panels {
+		if p == panel {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsLayout(layouts []pb.Keyboard_Layout, layout pb.Keyboard_Layout) bool {
+	for _, l := range layouts {
+		if l == layout {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxGPUMemory returns the memory in bits of the laptop's biggest GPU
+func maxGPUMemory(laptop *pb.Laptop) uint64 {
+	var max uint64
+	for _, gpu := range laptop.GetGpus() {
+		memory := toBit(gpu.GetMemory())
+		if memory > max {
+			max = memory
+		}
+	}
+
+	return max
+}
+
+// isSSDOnly reports whether the laptop has storages and all of them are SSD
+func isSSDOnly(laptop *pb.Laptop) bool {
+	if len(laptop.GetStorages()) == 0 {
+		return false
+	}
+
+	for _, storage := range laptop.GetStorages() {
+		if storage.GetDriver() != pb.Storage_SSD {
+			return false
+		}
+	}
+
+	return true
+}
+
+// totalStorage returns the sum of the laptop storages in bits
+func totalStorage(laptop *pb.Laptop) uint64 {
+	var total uint64
+	for _, storage := range laptop.GetStorages() {
+		total += toBit(storage.GetMemory())
+	}
+
+	return total
+}
+
+const kgPerLb = 0.45359237
+
+// weightKg returns the laptop weight in kilograms whichever unit it was given in
+func weightKg(laptop *pb.Laptop) float64 {
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		return weight.WeightKg
+	case *pb.Laptop_WeightLb:
+		return weight.WeightLb * kgPerLb
+	default:
+		return 0
+	}
+}
+
+// memoryUnitShifts are the powers of two of the number of bits in the memory units
+var memoryUnitShifts = map[pb.Memory_Unit]uint{
+	pb.Memory_BIT:      0,
+	pb.Memory_BYTE:     3,  // 2^3
+	pb.Memory_KILOBYTE: 13, // 2^3 * 2^10
+	pb.Memory_MEGABYTE: 23,
+	pb.Memory_GIGABYTE: 33,
+	pb.Memory_TERABYTE: 43,
+}
+
+// toBit returns the memory in bits, or the largest uint64 if they overflow it
+func toBit(memory *pb.Memory) uint64 {
+	shift, ok := memoryUnitShifts[memory.GetUnit()]
+	if !ok {
+		return 0
+	}
+	if memory.GetValue() > math.MaxUint64>>shift {
+		return math.MaxUint64
+	}
+
+	return memory.GetValue() << shift
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"gobook/pb"
+	"gobook/sample"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsQualified(t *testing.T) {
+	laptop := sample.NewLaptop()
+	laptop.Brand = "Lenovo"
+	laptop.Name = "Thinkpad X1"
+	laptop.PriceUsd = 2000
+	laptop.ReleaseYear = 2018
+	laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 4}
+	laptop.Gpus = []*pb.GPU{{Memory: &pb.Memory{Value: 4, Unit: pb.Memory_GIGABYTE}}}
+	laptop.Storages = []*pb.Storage{
+		{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 512, Unit: pb.Memory_GIGABYTE}},
+		{Driver: pb.Storage_HDD, Memory: &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}},
+	}
+	laptop.Screen = &pb.Screen{
+		SizeInch:   14,
+		Resolution: &pb.Screen_Resolution{Width: 1920, Height: 1080},
+		Panel:      pb.Screen_OLED,
+		Multitouch: false,
+	}
+	laptop.Ram = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}
+	laptop.Keyboard = &pb.Keyboard{Layout: pb.Keyboard_QWERTY, Backlit: true}
+
+	newFilter := func(update func(filter *pb.Filter)) *pb.Filter {
+		filter := &pb.Filter{}
+		update(filter)
+		return filter
+	}
+
+	testCases := []struct {
+		name      string
+		filter    *pb.Filter
+		qualified bool
+	}{
+		{"empty", &pb.Filter{}, true},
+		{"nil", nil, true},
+		{"max_price", newFilter(func(f *pb.Filter) { f.MaxPriceUsd = proto.Float64(1999) }), false},
+		{"ram", newFilter(func(f *pb.Filter) { f.MinRam = &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE} }), true},
+		{"big_ram", newFilter(func(f *pb.Filter) { f.MinRam = &pb.Memory{Value: 64, Unit: pb.Memory_GIGABYTE} }), false},
+		{"price_range", newFilter(func(f *pb.Filter) { f.MinPriceUsd = proto.Float64(1500) }), true},
+		{"min_price", newFilter(func(f *pb.Filter) { f.MinPriceUsd = proto.Float64(2500) }), false},
+		{"brand", newFilter(func(f *pb.Filter) { f.Brands = []string{"dell", "lenovo"} }), true},
+		{"other_brand", newFilter(func(f *pb.Filter) { f.Brands = []string{"Dell"} }), false},
+		{"name", newFilter(func(f *pb.Filter) { f.Name = proto.String("x1") }), true},
+		{"gpu_memory", newFilter(func(f *pb.Filter) { f.MinGpuMemory = &pb.Memory{Value: 4096, Unit: pb.Memory_MEGABYTE} }), true},
+		{"big_gpu_memory", newFilter(func(f *pb.Filter) { f.MinGpuMemory = &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE} }), false},
+		{"ssd_only", newFilter(func(f *pb.Filter) { f.SsdOnly = proto.Bool(true) }), false},
+		{"total_storage", newFilter(func(f *pb.Filter) { f.MinTotalStorage = &pb.Memory{Value: 1536, Unit: pb.Memory_GIGABYTE} }), true},
+		{"big_total_storage", newFilter(func(f *pb.Filter) { f.MinTotalStorage = &pb.Memory{Value: 2, Unit: pb.Memory_TERABYTE} }), false},
+		{"screen_size", newFilter(func(f *pb.Filter) { f.MinScreenSizeInch = proto.Float32(13); f.MaxScreenSizeInch = proto.Float32(15) }), true},
+		{"small_screen", newFilter(func(f *pb.Filter) { f.MaxScreenSizeInch = proto.Float32(13) }), false},
+		{"resolution", newFilter(func(f *pb.Filter) { f.MinScreenResolution = &pb.Screen_Resolution{Width: 2560} }), false},
+		{"panel", newFilter(func(f *pb.Filter) { f.ScreenPanels = []pb.Screen_Panel{pb.Screen_IPS} }), false},
+		{"weight_lb", newFilter(func(f *pb.Filter) { f.MaxWeightKg = proto.Float64(2) }), true},
+		{"heavy", newFilter(func(f *pb.Filter) { f.MaxWeightKg = proto.Float64(1.5) }), false},
+		{"release_year", newFilter(func(f *pb.Filter) { f.MinReleaseYear = proto.Uint32(2017); f.MaxReleaseYear = proto.Uint32(2018) }), true},
+		{"old_release_year", newFilter(func(f *pb.Filter) { f.MaxReleaseYear = proto.Uint32(2017) }), false},
+		{"keyboard", newFilter(func(f *pb.Filter) {
+			f.KeyboardLayouts = []pb.Keyboard_Layout{pb.Keyboard_QWERTY}
+			f.KeyboardBacklit = proto.Bool(true)
+		}), true},
+		{"keyboard_layout", newFilter(func(f *pb.Filter) { f.KeyboardLayouts = []pb.Keyboard_Layout{pb.Keyboard_AZERTY} }), false},
+		{"not_ssd_only", newFilter(func(f *pb.Filter) { f.SsdOnly = proto.Bool(false) }), true},
+		{"not_multitouch", newFilter(func(f *pb.Filter) { f.Multitouch = proto.Bool(false) }), true},
+		{"multitouch", newFilter(func(f *pb.Filter) { f.Multitouch = proto.Bool(true) }), false},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.qualified, isQualified(tc.filter, laptop))
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"gobook/pb"
+	"sort"
+)
+
+// laptopIndex keeps the laptop ids sorted by one numeric value,
+// so range filters only have to look at the matching laptops
+type laptopIndex struct {
+	value   func(laptop *pb.Laptop) float64
+	entries []indexEntry
+}
+
+type indexEntry struct {
+	value float64
+	id    string
+}
+
+// indexRange is a bound of a filter on the value of an index
+type indexRange struct {
+	min, max       float64
+	hasMin, hasMax bool
+}
+
+func newLaptopIndex(value func(laptop *pb.Laptop) float64) *laptopIndex {
+	return &laptopIndex{
+		value: value,
+	}
+}
+
+func (index *laptopIndex) position(entry indexEntry) int {
+	return sort.Search(len(index.entries), func(i int) bool {
+		current := index.entries[i]
+		if current.value != entry.value {
+			return current.value > entry.value
+		}
+		return current.id >= entry.id
+	})
+}
+
+func (index *laptopIndex) insert(laptop *pb.Laptop) {
+	entry := indexEntry{value: index.value(laptop), id: laptop.GetId()}
+	i := index.position(entry)
+
+	index.entries = append(index.entries, indexEntry{})
+	copy(index.entries[i+1:], index.entries[i:])
+	index.entries[i] = entry
+}
+
+func (index *laptopIndex) remove(laptop *pb.Laptop) {
+	entry := indexEntry{value: index.value(laptop), id: laptop.GetId()}
+	i := index.position(entry)
+
+	if i >= len(index.entries) || index.entries[i] != entry {
+		// The laptop was changed since it was indexed, fall back to a scan by id
+		for i = range index.entries {
+			if index.entries[i].id == entry.id {
+				break
+			}
+		}
+		if i >= len(index.entries) || index.entries[i].id != entry.id {
+			return
+		}
+	}
+
+	index.entries = append(index.entries[:i], index.entries[i+1:]...)
+}
+
+// lookup returns the entries whose value is within the range
+func (index *laptopIndex) lookup(bounds indexRange) []indexEntry {
+	start := 0
+	if bounds.hasMin {
+		start = sort.Search(len(index.entries), func(i int) bool {
+			return index.entries[i].value >= bounds.min
+		})
+	}
+
+	end := len(index.entries)
+	if bounds.hasMax {
+		end = sort.Search(len(index.entries), func(i int) bool {
+			return index.entries[i].value > bounds.max
+		})
+	}
+
+	if start > end {
+		return nil
+	}
+
+	return index.entries[start:end]
+}
+
+// laptopIndexes are the indexes maintained by InMemoryLaptopStore,
+// with the filter bounds each of them can answer
+var laptopIndexes = []struct {
+	name   string
+	value  func(laptop *pb.Laptop) float64
+	bounds func(filter *pb.Filter) (indexRange, bool)
+}{
+	{
+		name: "price_usd",
+		value: func(laptop *pb.Laptop) float64 {
+			return laptop.GetPriceUsd()
+		},
+		bounds: func(filter *pb.Filter) (indexRange, bool) {
+			bounds := indexRange{
+				min:    filter.GetMinPriceUsd(),
+				max:    filter.GetMaxPriceUsd(),
+				hasMin: filter.MinPriceUsd != nil,
+				hasMax: filter.MaxPriceUsd != nil,
+			}
+			return bounds, bounds.hasMin || bounds.hasMax
+		},
+	},
+	{
+		name: "cpu_cores",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(laptop.GetCpu().GetNumberCores())
+		},
+		bounds: func(filter *pb.Filter) (indexRange, bool) {
+			return indexRange{min: float64(filter.GetMinCpuCores()), hasMin: true}, filter.MinCpuCores != nil
+		},
+	},
+	{
+		name: "cpu_ghz",
+		value: func(laptop *pb.Laptop) float64 {
+			return laptop.GetCpu().GetMinGhz()
+		},
+		bounds: func(filter *pb.Filter) (indexRange, bool) {
+			return indexRange{min: filter.GetMinCpuGhz(), hasMin: true}, filter.MinCpuGhz != nil
+		},
+	},
+	{
+		name: "ram",
+		value: func(laptop *pb.Laptop) float64 {
+			return float64(toBit(laptop.GetRam()))
+		},
+		bounds: func(filter *pb.Filter) (indexRange, bool) {
+			return indexRange{min: float64(toBit(filter.GetMinRam())), hasMin: true}, filter.MinRam != nil
+		},
+	},
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gobook/pb"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// laptopOrderFields maps the accepted order_by field names to the value laptops are sorted by
+var laptopOrderFields = map[string]func(laptop *pb.Laptop, rating *Rating) float64{
+	"id": func(laptop *pb.Laptop, rating *Rating) float64 {
+		return 0
+	},
+	"price_usd": func(laptop *pb.Laptop, rating *Rating) float64 {
+		return laptop.GetPriceUsd()
+	},
+	"release_year": func(laptop *pb.Laptop, rating *Rating) float64 {
+		return float64(laptop.GetReleaseYear())
+	},
+	"cpu.number_cores": func(laptop *pb.Laptop, rating *Rating) float64 {
+		return float64(laptop.GetCpu().GetNumberCores())
+	},
+	"average_rating": func(laptop *pb.Laptop, rating *Rating) float64 {
+		return rating.Average()
+	},
+	"updated_at": func(laptop *pb.Laptop, rating *Rating) float64 {
+		return float64(laptop.GetUpdatedAt().AsTime().UnixMicro())
+	},
+}
+
+// laptopOrder sorts laptops by one field, using the laptop id to break ties
+// so that the order is stable across pages
+type laptopOrder struct {
+	field string
+	desc  bool
+	value func(laptop *pb.Laptop, rating *Rating) float64
+}
+
+// laptopSortKey is the position of a laptop in a laptopOrder
+type laptopSortKey struct {
+	Value float64 `json:"value"`
+	ID    string  `json:"id"`
+}
+
+// pageToken is the opaque cursor returned to clients as next_page_token
+type pageToken struct {
+	OrderBy string        `json:"order_by"`
+	Filter  string        `json:"filter"`
+	After   laptopSortKey `json:"after"`
+}
+
+// parseLaptopOrder parses an order_by string like "price_usd" or "release_year desc".
+// An empty string orders laptops by id.
+func parseLaptopOrder(orderBy string) (*laptopOrder, error) {
+	parts := strings.Fields(orderBy)
+	if len(parts) == 0 {
+		parts = []string{"id"}
+	}
+
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("order by %q must be a field optionally followed by asc or desc", orderBy)
+	}
+
+	value, ok := laptopOrderFields[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("cannot order laptops by %q", parts[0])
+	}
+
+	order := &laptopOrder{
+		field: parts[0],
+		value: value,
+	}
+
+	if len(parts) == 2 {
+		switch strings.ToLower(parts[1]) {
+		case "asc":
+		case "desc":
+			order.desc = true
+		default:
+			return nil, fmt.Errorf("unknown sort direction %q", parts[1])
+		}
+	}
+
+	return order, nil
+}
+
+// String returns the normalized form of the order
+func (order *laptopOrder) String() string {
+	if order.desc {
+		return order.field + " desc"
+	}
+
+	return order.field
+}
+
+func (order *laptopOrder) less(a, b laptopSortKey) bool {
+	if a.Value != b.Value {
+		return (a.Value < b.Value) != order.desc
+	}
+
+	return a.ID < b.ID
+}
+
+// sortLaptops sorts the laptops in place and returns their sort keys in the same order
+func (order *laptopOrder) sortLaptops(laptops []*pb.Laptop, ratingStore RatingStore) []laptopSortKey {
+	keys := make([]laptopSortKey, len(laptops))
+	for i, laptop := range laptops {
+		var rating *Rating
+		if ratingStore != nil && order.field == "average_rating" {
+			rating = ratingStore.Find(laptop.GetId())
+		}
+
+		keys[i] = laptopSortKey{
+			Value: order.value(laptop, rating),
+			ID:    laptop.GetId(),
+		}
+	}
+
+	sort.Sort(&laptopSorter{order: order, laptops: laptops, keys: keys})
+
+	return keys
+}
+
+type laptopSorter struct {
+	order   *laptopOrder
+	laptops []*pb.Laptop
+	keys    []laptopSortKey
+}
+
+func (sorter *laptopSorter) Len() int {
+	return len(sorter.laptops)
+}
+
+func (sorter *laptopSorter) Less(i, j int) bool {
+	return sorter.order.less(sorter.keys[i], sorter.keys[j])
+}
+
+func (sorter *laptopSorter) Swap(i, j int) {
+	sorter.laptops[i], sorter.laptops[j] = sorter.laptops[j], sorter.laptops[i]
+	sorter.keys[i], sorter.keys[j] = sorter.keys[j], sorter.keys[i]
+}
+
+// filterFingerprint identifies a filter and query so a page token cannot be reused with other ones
+func filterFingerprint(filter *pb.Filter, query string) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal filter: %w", err)
+	}
+
+	sum := sha256.Sum256(append(data, query...))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+func encodePageToken(token *pageToken) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(value string) (*pageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("page token is malformed")
+	}
+
+	token := &pageToken{}
+	err = json.Unmarshal(data, token)
+	if err != nil {
+		return nil, fmt.Errorf("page token is malformed")
+	}
+
+	return token, nil
+}
+
+// collectLaptops returns every laptop of the store matching both the filter and the query
+func collectLaptops(ctx context.Context, store LaptopStore, filter *pb.Filter, query *LaptopQuery) ([]*pb.Laptop, error) {
+	laptops := []*pb.Laptop{}
+
+	err := store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		if query.Match(laptop) {
+			laptops = append(laptops, laptop)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return laptops, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gobook/pb"
+	"log"
+	"time"
+)
+
+// LaptopPurger removes for good the laptops deleted for longer than the retention,
+// with their images, ratings and revisions
+type LaptopPurger struct {
+	laptopStore   LaptopStore
+	imageStore    ImageStore
+	ratingStore   RatingStore
+	revisionStore LaptopRevisionStore
+	retention     time.Duration
+	now           func() time.Time
+}
+
+func NewLaptopPurger(
+	laptopStore LaptopStore,
+	imageStore ImageStore,
+	ratingStore RatingStore,
+	revisionStore LaptopRevisionStore,
+	retention time.Duration,
+) *LaptopPurger {
+	return &LaptopPurger{
+		laptopStore:   laptopStore,
+		imageStore:    imageStore,
+		ratingStore:   ratingStore,
+		revisionStore: revisionStore,
+		retention:     retention,
+		now:           time.Now,
+	}
+}
+
+// Run purges the expired laptops every interval until ctx is done
+func (purger *LaptopPurger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := purger.Purge(ctx)
+		if err != nil {
+			log.Printf("cannot purge deleted laptops: %v", err)
+		} else if count > 0 {
+			log.Printf("purged %d deleted laptops", count)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Purge removes the expired laptops of the trash and returns how many were removed
+func (purger *LaptopPurger) Purge(ctx context.Context) (int, error) {
+	expiry := purger.now().Add(-purger.retention)
+
+	expired := []string{}
+	err := purger.laptopStore.ListDeleted(ctx, func(laptop *pb.Laptop) error {
+		if laptop.GetDeletedAt().AsTime().Before(expiry) {
+			expired = append(expired, laptop.GetId())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot list deleted laptops: %w", err)
+	}
+
+	count := 0
+	for _, id := range expired {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+
+		err := purger.purgeLaptop(id)
+		if err != nil {
+			return count, fmt.Errorf("cannot purge laptop %s: %w", id, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// purgeLaptop removes the laptop from the trash, then its images, ratings and revisions.
+// The store only purges a laptop still in the trash, so one undeleted meanwhile keeps them.
+// If removing them fails, they are left without their laptop.
+func (purger *LaptopPurger) purgeLaptop(id string) error {
+	err := purger.laptopStore.Purge(id)
+	if errors.Is(err, ErrNotFound) {
+		if purger.laptopStore.Find(id) != nil || purger.laptopStore.FindDeleted(id) != nil {
+			log.Printf("laptop %s was undeleted before it was purged", id)
+			return nil
+		}
+		// Another purger, such as the one of another cluster node, removed it already
+	} else if err != nil {
+		return err
+	}
+
+	if purger.imageStore != nil {
+		err := purger.imageStore.DeleteLaptopImages(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if purger.ratingStore != nil {
+		err := purger.ratingStore.Delete(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if purger.revisionStore != nil {
+		err := purger.revisionStore.Delete(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"gobook/sample"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaptopPurger(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	ratingStore := NewInMemoryRatingStore()
+	imageIndex := NewInMemoryImageIndex()
+	imageStore := NewDiskImageStoreWithIndex(t.TempDir(), imageIndex)
+
+	kept := sample.NewLaptop()
+	purged := sample.NewLaptop()
+	for _, laptop := range []string{kept.Id, purged.Id} {
+		_, err := imageStore.Save(laptop, ".jpg", bytes.NewReader(newTestImage(t, "jpeg", 2, 2)))
+		require.NoError(t, err)
+		_, err = ratingStore.Add(laptop, 5)
+		require.NoError(t, err)
+	}
+	require.NoError(t, laptopStore.Save(kept))
+	require.NoError(t, laptopStore.Save(purged))
+	require.NoError(t, laptopStore.Delete(purged.Id, 0))
+
+	revisionStore := NewInMemoryLaptopRevisionStore()
+	require.NoError(t, revisionStore.Save(purged))
+
+	purger := NewLaptopPurger(laptopStore, imageStore, ratingStore, revisionStore, time.Hour)
+
+	// The laptop is kept in the trash for the retention
+	count, err := purger.Purge(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+	require.NotNil(t, laptopStore.FindDeleted(purged.Id))
+
+	purger.now = func() time.Time { return time.Now().Add(time.Hour) }
+	count, err = purger.Purge(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	require.Nil(t, laptopStore.FindDeleted(purged.Id))
+	require.Nil(t, ratingStore.Find(purged.Id))
+	require.Nil(t, revisionStore.Find(purged.Id, 1))
+	require.NotNil(t, laptopStore.Find(kept.Id))
+	require.NotNil(t, ratingStore.Find(kept.Id))
+
+	images := 0
+	err = imageIndex.List(func(imageID string, info *ImageInfo) error {
+		require.Equal(t, kept.Id, info.LaptopID)
+		_, err := os.Stat(info.Path)
+		require.NoError(t, err)
+		images++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, images)
+}
+
+func TestLaptopPurgerUndeleted(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	ratingStore := NewInMemoryRatingStore()
+	revisionStore := NewInMemoryLaptopRevisionStore()
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+	require.NoError(t, revisionStore.Save(laptop))
+	_, err := ratingStore.Add(laptop.Id, 5)
+	require.NoError(t, err)
+
+	purger := NewLaptopPurger(laptopStore, nil, ratingStore, revisionStore, time.Hour)
+
+	// The laptop was undeleted after the purger listed the trash
+	err = purger.purgeLaptop(laptop.Id)
+	require.NoError(t, err)
+	require.NotNil(t, laptopStore.Find(laptop.Id))
+	require.NotNil(t, ratingStore.Find(laptop.Id))
+	require.NotNil(t, revisionStore.Find(laptop.Id, 1))
+
+	// The dependents of a laptop purged by another purger are still removed
+	require.NoError(t, laptopStore.Delete(laptop.Id, 0))
+	require.NoError(t, laptopStore.Purge(laptop.Id))
+	err = purger.purgeLaptop(laptop.Id)
+	require.NoError(t, err)
+	require.Nil(t, ratingStore.Find(laptop.Id))
+	require.Nil(t, revisionStore.Find(laptop.Id, 1))
+}
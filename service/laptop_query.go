@@ -0,0 +1,573 @@
+package service
+
+import (
+	"fmt"
+	"gobook/pb"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// LaptopQuery is a boolean filter expression over the fields of pb.Laptop, in a subset of
+// the AIP-160 syntax, for example:
+//
+//	(brand = "Lenovo" OR brand = "Dell") AND screen.panel = OLED AND (price_usd < 2000 OR gpus.name : "RTX")
+//
+// Comparisons are =, !=, <, <=, >, >= and : (contains, case-insensitive, for strings).
+// They can be combined with AND, OR, NOT and parentheses; two comparisons next to each
+// other are joined with AND. A path going through a repeated field matches if any element does.
+type LaptopQuery struct {
+	root queryNode
+}
+
+const (
+	// maxQueryLength is the number of bytes of the longest query accepted
+	maxQueryLength = 4096
+	// maxQueryDepth is how deep parentheses and NOT may be nested in a query
+	maxQueryDepth = 32
+)
+
+// QueryError points at the token of a query that cannot be parsed or validated
+type QueryError struct {
+	Position int
+	Token    string
+	Message  string
+}
+
+func (err *QueryError) Error() string {
+	if len(err.Token) == 0 {
+		return fmt.Sprintf("query error at position %d: %s", err.Position, err.Message)
+	}
+
+	return fmt.Sprintf("query error at position %d near %q: %s", err.Position, err.Token, err.Message)
+}
+
+// ParseLaptopQuery parses and validates a query against the pb.Laptop schema.
+// An empty query returns nil, which matches every laptop.
+func ParseLaptopQuery(query string) (*LaptopQuery, error) {
+	if len(query) > maxQueryLength {
+		return nil, &QueryError{
+			Position: 0,
+			Message:  fmt.Sprintf("query is longer than %d bytes", maxQueryLength),
+		}
+	}
+
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 1 {
+		return nil, nil
+	}
+
+	parser := &queryParser{tokens: tokens}
+
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.peek().kind != tokenEOF {
+		return nil, parser.errorf(parser.peek(), "unexpected token")
+	}
+
+	return &LaptopQuery{root: root}, nil
+}
+
+// Match reports whether the laptop satisfies the query. A nil query matches everything.
+func (query *LaptopQuery) Match(laptop *pb.Laptop) bool {
+	if query == nil {
+		return true
+	}
+
+	return query.root.match(laptop.ProtoReflect())
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type queryToken struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+func tokenizeQuery(query string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(' || r == ')':
+			kind := tokenLeftParen
+			if r == ')' {
+				kind = tokenRightParen
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: string(r), pos: i})
+			i++
+
+		case strings.ContainsRune("=!<>:", r):
+			start := i
+			i++
+			if i < len(runes) && runes[i] == '=' && r != '=' && r != ':' {
+				i++
+			}
+
+			text := string(runes[start:i])
+			if text == "!" {
+				return nil, &QueryError{Position: start, Token: text, Message: "expected !="}
+			}
+			tokens = append(tokens, queryToken{kind: tokenOperator, text: text, pos: start})
+
+		case r == '"' || r == '\'':
+			start := i
+			value := strings.Builder{}
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value.WriteRune(runes[i])
+			}
+
+			if i >= len(runes) {
+				return nil, &QueryError{Position: start, Token: string(runes[start:]), Message: "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, queryToken{kind: tokenString, text: string(runes[start:i]), value: value.String(), pos: start})
+
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+
+			text := string(runes[start:i])
+			_, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, &QueryError{Position: start, Token: text, Message: "invalid number"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenNumber, text: text, value: text, pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+
+			text := string(runes[start:i])
+			kind := tokenIdent
+			switch text {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: text, value: text, pos: start})
+
+		default:
+			return nil, &QueryError{Position: i, Token: string(r), Message: "unexpected character"}
+		}
+	}
+
+	tokens = append(tokens, queryToken{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	next   int
+	// depth is the number of parentheses and NOT being parsed
+	depth int
+}
+
+func (parser *queryParser) peek() queryToken {
+	return parser.tokens[parser.next]
+}
+
+func (parser *queryParser) consume() queryToken {
+	token := parser.tokens[parser.next]
+	if token.kind != tokenEOF {
+		parser.next++
+	}
+
+	return token
+}
+
+func (parser *queryParser) errorf(token queryToken, format string, args ...interface{}) error {
+	return &QueryError{
+		Position: token.pos,
+		Token:    token.text,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// nest enters a parenthesis or NOT, returning an error if it is nested too deeply.
+// The caller must call leave once it is parsed.
+func (parser *queryParser) nest(token queryToken) error {
+	if parser.depth >= maxQueryDepth {
+		return parser.errorf(token, "query is nested more than %d levels deep", maxQueryDepth)
+	}
+
+	parser.depth++
+	return nil
+}
+
+func (parser *queryParser) leave() {
+	parser.depth--
+}
+
+func (parser *queryParser) parseOr() (queryNode, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek().kind == tokenOr {
+		parser.consume()
+
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (parser *queryParser) parseAnd() (queryNode, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch parser.peek().kind {
+		case tokenAnd:
+			parser.consume()
+		case tokenIdent, tokenNot, tokenLeftParen:
+			// Terms next to each other are implicitly joined with AND
+		default:
+			return left, nil
+		}
+
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+}
+
+func (parser *queryParser) parseUnary() (queryNode, error) {
+	if parser.peek().kind == tokenNot {
+		token := parser.consume()
+		if err := parser.nest(token); err != nil {
+			return nil, err
+		}
+		defer parser.leave()
+
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	return parser.parsePrimary()
+}
+
+func (parser *queryParser) parsePrimary() (queryNode, error) {
+	token := parser.consume()
+
+	switch token.kind {
+	case tokenLeftParen:
+		if err := parser.nest(token); err != nil {
+			return nil, err
+		}
+		defer parser.leave()
+
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		closing := parser.consume()
+		if closing.kind != tokenRightParen {
+			return nil, parser.errorf(closing, "expected )")
+		}
+		return node, nil
+
+	case tokenIdent:
+		return parser.parseComparison(token)
+
+	case tokenEOF:
+		return nil, parser.errorf(token, "unexpected end of query")
+
+	default:
+		return nil, parser.errorf(token, "expected a field or (")
+	}
+}
+
+func (parser *queryParser) parseComparison(fieldToken queryToken) (queryNode, error) {
+	fields, err := resolveLaptopPath(fieldToken.text)
+	if err != nil {
+		return nil, parser.errorf(fieldToken, "%v", err)
+	}
+
+	opToken := parser.consume()
+	if opToken.kind != tokenOperator {
+		return nil, parser.errorf(opToken, "expected a comparison operator after %s", fieldToken.text)
+	}
+
+	valueToken := parser.consume()
+	if valueToken.kind != tokenString && valueToken.kind != tokenNumber && valueToken.kind != tokenIdent {
+		return nil, parser.errorf(valueToken, "expected a value")
+	}
+
+	node := &comparisonNode{
+		fields:   fields,
+		operator: opToken.text,
+	}
+
+	field := fields[len(fields)-1]
+	err = node.setValue(field, opToken.text, valueToken)
+	if err != nil {
+		return nil, parser.errorf(valueToken, "%v", err)
+	}
+
+	return node, nil
+}
+
+// resolveLaptopPath returns the fields named by a dotted path starting from pb.Laptop
+func resolveLaptopPath(path string) ([]protoreflect.FieldDescriptor, error) {
+	message := (&pb.Laptop{}).ProtoReflect().Descriptor()
+	names := strings.Split(path, ".")
+	fields := make([]protoreflect.FieldDescriptor, 0, len(names))
+
+	for i, name := range names {
+		if message == nil {
+			return nil, fmt.Errorf("field %s has no sub-field %s", strings.Join(names[:i], "."), name)
+		}
+
+		field := message.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			return nil, fmt.Errorf("unknown field %s in %s", name, message.Name())
+		}
+
+		fields = append(fields, field)
+		message = field.Message()
+	}
+
+	last := fields[len(fields)-1]
+	if last.Kind() == protoreflect.MessageKind || last.Kind() == protoreflect.GroupKind {
+		return nil, fmt.Errorf("field %s is a message, compare one of its fields instead", path)
+	}
+
+	return fields, nil
+}
+
+type queryNode interface {
+	match(message protoreflect.Message) bool
+}
+
+type andNode struct {
+	left, right queryNode
+}
+
+func (node *andNode) match(message protoreflect.Message) bool {
+	return node.left.match(message) && node.right.match(message)
+}
+
+type orNode struct {
+	left, right queryNode
+}
+
+func (node *orNode) match(message protoreflect.Message) bool {
+	return node.left.match(message) || node.right.match(message)
+}
+
+type notNode struct {
+	operand queryNode
+}
+
+func (node *notNode) match(message protoreflect.Message) bool {
+	return !node.operand.match(message)
+}
+
+type comparisonNode struct {
+	fields   []protoreflect.FieldDescriptor
+	operator string
+	text     string
+	number   float64
+	boolean  bool
+}
+
+func (node *comparisonNode) setValue(field protoreflect.FieldDescriptor, operator string, token queryToken) error {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		if token.kind == tokenNumber {
+			return fmt.Errorf("field %s expects a string", field.Name())
+		}
+		node.text = token.value
+
+	case protoreflect.BoolKind:
+		if operator != "=" && operator != "!=" {
+			return fmt.Errorf("field %s only supports = and !=", field.Name())
+		}
+
+		value, err := strconv.ParseBool(token.value)
+		if token.kind != tokenIdent || err != nil {
+			return fmt.Errorf("field %s expects true or false", field.Name())
+		}
+		node.boolean = value
+
+	case protoreflect.EnumKind:
+		if operator == ":" {
+			return fmt.Errorf("field %s does not support :", field.Name())
+		}
+
+		if token.kind == tokenNumber {
+			value, err := strconv.ParseFloat(token.value, 64)
+			if err != nil {
+				return err
+			}
+			node.number = value
+			return nil
+		}
+
+		value := field.Enum().Values().ByName(protoreflect.Name(token.value))
+		if value == nil {
+			return fmt.Errorf("%s is not a value of %s", token.value, field.Enum().Name())
+		}
+		node.number = float64(value.Number())
+
+	case protoreflect.BytesKind:
+		return fmt.Errorf("field %s cannot be compared", field.Name())
+
+	default:
+		if operator == ":" {
+			return fmt.Errorf("field %s does not support :", field.Name())
+		}
+
+		value, err := strconv.ParseFloat(token.value, 64)
+		if token.kind != tokenNumber || err != nil {
+			return fmt.Errorf("field %s expects a number", field.Name())
+		}
+		node.number = value
+	}
+
+	return nil
+}
+
+func (node *comparisonNode) match(message protoreflect.Message) bool {
+	return node.matchPath(message, node.fields)
+}
+
+func (node *comparisonNode) matchPath(message protoreflect.Message, fields []protoreflect.FieldDescriptor) bool {
+	field := fields[0]
+
+	if field.IsList() {
+		list := message.Get(field).List()
+		for i := 0; i < list.Len(); i++ {
+			if node.matchValue(list.Get(i), fields) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A field of an unset oneof or message does not match anything
+	if field.ContainingOneof() != nil && !message.Has(field) {
+		return false
+	}
+
+	return node.matchValue(message.Get(field), fields)
+}
+
+func (node *comparisonNode) matchValue(value protoreflect.Value, fields []protoreflect.FieldDescriptor) bool {
+	field := fields[0]
+
+	if len(fields) > 1 {
+		if !value.Message().IsValid() {
+			return false
+		}
+		return node.matchPath(value.Message(), fields[1:])
+	}
+
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return compareString(node.operator, value.String(), node.text)
+	case protoreflect.BoolKind:
+		return (value.Bool() == node.boolean) == (node.operator == "=")
+	case protoreflect.EnumKind:
+		return compareNumber(node.operator, float64(value.Enum()), node.number)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return compareNumber(node.operator, value.Float(), node.number)
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		return compareNumber(node.operator, float64(value.Uint()), node.number)
+	default:
+		return compareNumber(node.operator, float64(value.Int()), node.number)
+	}
+}
+
+func compareString(operator string, a, b string) bool {
+	switch operator {
+	case ":":
+		return strings.Contains(strings.ToLower(a), strings.ToLower(b))
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+func compareNumber(operator string, a, b float64) bool {
+	switch operator {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"gobook/pb"
+	"gobook/sample"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaptopQuery(t *testing.T) {
+	laptop := sample.NewLaptop()
+	laptop.Brand = "Lenovo"
+	laptop.PriceUsd = 2500
+	laptop.Screen.Panel = pb.Screen_OLED
+	laptop.Keyboard.Backlit = true
+	laptop.Weight = &pb.Laptop_WeightKg{WeightKg: 1.2}
+	laptop.Gpus = []*pb.GPU{
+		{Brand: "Intel", Name: "Iris Xe"},
+		{Brand: "Nvidia", Name: "GeForce RTX 3060"},
+	}
+
+	testCases := []struct {
+		query string
+		match bool
+	}{
+		{`brand = "Lenovo"`, true},
+		{`brand = "lenovo"`, false},
+		{`brand : "leno"`, true},
+		{`(brand = "Lenovo" OR brand = "Dell") AND screen.panel = OLED AND (price_usd < 2000 OR gpus.name : "RTX")`, true},
+		{`(brand = "Lenovo" OR brand = "Dell") AND screen.panel = OLED AND price_usd < 2000`, false},
+		{`brand = "Dell" OR price_usd >= 2500`, true},
+		{`NOT brand = "Lenovo"`, false},
+		{`brand = "Lenovo" price_usd > 3000`, false},
+		{`gpus.brand = "Nvidia"`, true},
+		{`gpus.brand = "AMD"`, false},
+		{`keyboard.backlit = true`, true},
+		{`screen.panel != IPS`, true},
+		{`weight_kg <= 1.5`, true},
+		{`weight_lb <= 10`, false},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.query, func(t *testing.T) {
+			query, err := ParseLaptopQuery(tc.query)
+			require.NoError(t, err)
+			require.Equal(t, tc.match, query.Match(laptop))
+		})
+	}
+
+	query, err := ParseLaptopQuery("  ")
+	require.NoError(t, err)
+	require.True(t, query.Match(laptop))
+}
+
+func TestLaptopQueryError(t *testing.T) {
+	testCases := []struct {
+		query    string
+		position int
+		token    string
+	}{
+		{`brnd = "Lenovo"`, 0, "brnd"},
+		{`brand = "Lenovo" AND screen.pannel = OLED`, 21, "screen.pannel"},
+		{`screen.panel = LCD`, 15, "LCD"},
+		{`price_usd < "cheap"`, 12, `"cheap"`},
+		{`cpu = 4`, 0, "cpu"},
+		{`keyboard.backlit > true`, 19, "true"},
+		{`(brand = "Lenovo"`, 17, ""},
+		{`brand = "Lenovo`, 8, `"Lenovo`},
+		{`brand "Lenovo"`, 6, `"Lenovo"`},
+		{`brand = "Lenovo" )`, 17, ")"},
+		{`brand # "Lenovo"`, 6, "#"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.query, func(t *testing.T) {
+			_, err := ParseLaptopQuery(tc.query)
+			require.Error(t, err)
+
+			var queryErr *QueryError
+			require.True(t, errors.As(err, &queryErr))
+			require.Equal(t, tc.position, queryErr.Position)
+			require.Equal(t, tc.token, queryErr.Token)
+		})
+	}
+}
+
+func TestLaptopQueryLimits(t *testing.T) {
+	nested := strings.Repeat("(", maxQueryDepth) + `brand = "Dell"` + strings.Repeat(")", maxQueryDepth)
+	_, err := ParseLaptopQuery(nested)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		query    string
+		position int
+		token    string
+	}{
+		{"too many parentheses", strings.Repeat("(", 1000), maxQueryDepth, "("},
+		{"too many NOT", strings.Repeat("NOT ", 1000) + `brand = "Dell"`, 4 * maxQueryDepth, "NOT"},
+		{"too long", strings.Repeat(`brand = "Dell" `, 1000), 0, ""},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseLaptopQuery(tc.query)
+			require.Error(t, err)
+
+			var queryErr *QueryError
+			require.True(t, errors.As(err, &queryErr))
+			require.Equal(t, tc.position, queryErr.Position)
+			require.Equal(t, tc.token, queryErr.Token)
+		})
+	}
+}
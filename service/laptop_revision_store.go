@@ -0,0 +1,192 @@
+package service
+
+import (
+	"gobook/pb"
+	"log"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// LaptopRevisionStore keeps every version of the laptops, by revision
+type LaptopRevisionStore interface {
+	// Save keeps a copy of the laptop as the version of its revision
+	Save(laptop *pb.Laptop) error
+	// Find returns the version of the laptop at the revision, or nil
+	Find(id string, revision uint64) *pb.Laptop
+	// List calls found with every version of the laptop, oldest first
+	List(id string, found func(laptop *pb.Laptop) error) error
+	// Delete removes every version of the laptop
+	Delete(id string) error
+}
+
+type InMemoryLaptopRevisionStore struct {
+	mutex sync.RWMutex
+	// revisions has the versions of every laptop, sorted by revision
+	revisions map[string][]*pb.Laptop
+	// wal is the optional write-ahead log of the changes
+	wal *storeLog
+}
+
+func NewInMemoryLaptopRevisionStore() LaptopRevisionStore {
+	return &InMemoryLaptopRevisionStore{
+		revisions: make(map[string][]*pb.Laptop),
+	}
+}
+
+// NewInMemoryLaptopRevisionStoreWithWAL returns an InMemoryLaptopRevisionStore that logs its changes
+// in the laptop_revisions files of the options dir, after restoring the versions they hold
+func NewInMemoryLaptopRevisionStoreWithWAL(options WALOptions) (*InMemoryLaptopRevisionStore, error) {
+	store := NewInMemoryLaptopRevisionStore().(*InMemoryLaptopRevisionStore)
+
+	wal, err := openStoreLog(
+		options,
+		"laptop_revisions",
+		func() proto.Message {
+			return &pb.LaptopLogEntry{}
+		},
+		func(entry proto.Message) {
+			// put is a saved version, deleted_id is logged by Delete
+			record := entry.(*pb.LaptopLogEntry)
+			if laptop := record.GetPut(); laptop != nil {
+				store.put(laptop)
+				return
+			}
+			delete(store.revisions, record.GetDeletedId())
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	store.wal = wal
+	return store, nil
+}
+
+// Close closes the write-ahead log of the store, if any
+func (store *InMemoryLaptopRevisionStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.wal == nil {
+		return nil
+	}
+
+	return store.wal.close()
+}
+
+func (store *InMemoryLaptopRevisionStore) Save(laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	saved := proto.Clone(laptop).(*pb.Laptop)
+	if store.wal != nil {
+		err := store.wal.append(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: saved}})
+		if err != nil {
+			return err
+		}
+	}
+
+	store.put(saved)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+// put adds the version of the laptop, or replaces the one with the same revision
+func (store *InMemoryLaptopRevisionStore) put(saved *pb.Laptop) {
+	revisions := store.revisions[saved.GetId()]
+
+	i := sort.Search(len(revisions), func(i int) bool {
+		return revisions[i].GetRevision() >= saved.GetRevision()
+	})
+	if i < len(revisions) && revisions[i].GetRevision() == saved.GetRevision() {
+		revisions[i] = saved
+		return
+	}
+
+	revisions = append(revisions, nil)
+	copy(revisions[i+1:], revisions[i:])
+	revisions[i] = saved
+	store.revisions[saved.GetId()] = revisions
+}
+
+func (store *InMemoryLaptopRevisionStore) Find(id string, revision uint64) *pb.Laptop {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	revisions := store.revisions[id]
+	i := sort.Search(len(revisions), func(i int) bool {
+		return revisions[i].GetRevision() >= revision
+	})
+	if i == len(revisions) || revisions[i].GetRevision() != revision {
+		return nil
+	}
+
+	return revisions[i]
+}
+
+func (store *InMemoryLaptopRevisionStore) List(id string, found func(laptop *pb.Laptop) error) error {
+	store.mutex.RLock()
+	revisions := append([]*pb.Laptop{}, store.revisions[id]...)
+	store.mutex.RUnlock()
+
+	for _, laptop := range revisions {
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *InMemoryLaptopRevisionStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.wal != nil {
+		err := store.wal.append(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_DeletedId{DeletedId: id}})
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(store.revisions, id)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+// snapshotIfDue writes a snapshot of the versions once enough changes were logged
+func (store *InMemoryLaptopRevisionStore) snapshotIfDue() {
+	if store.wal == nil || !store.wal.snapshotDue() {
+		return
+	}
+
+	entries := []proto.Message{}
+	for _, revisions := range store.revisions {
+		for _, laptop := range revisions {
+			entries = append(entries, &pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: laptop}})
+		}
+	}
+
+	err := store.wal.snapshot(entries)
+	if err != nil {
+		log.Printf("cannot snapshot laptop revisions: %v", err)
+	}
+}
+
+// all returns every version of every laptop
+func (store *InMemoryLaptopRevisionStore) all() []*pb.Laptop {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	laptops := []*pb.Laptop{}
+	for _, revisions := range store.revisions {
+		laptops = append(laptops, revisions...)
+	}
+
+	return laptops
+}
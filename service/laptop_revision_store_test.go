@@ -0,0 +1,53 @@
+package service
+
+import (
+	"gobook/pb"
+	"gobook/sample"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInMemoryLaptopRevisionStore(t *testing.T) {
+	testLaptopRevisionStore(t, NewInMemoryLaptopRevisionStore())
+}
+
+// testLaptopRevisionStore checks a LaptopRevisionStore implementation
+func testLaptopRevisionStore(t *testing.T, store LaptopRevisionStore) {
+	laptop := sample.NewLaptop()
+	other := sample.NewLaptop()
+
+	versions := []*pb.Laptop{}
+	for _, revision := range []uint64{2, 1, 3} {
+		version := proto.Clone(laptop).(*pb.Laptop)
+		version.Revision = revision
+		version.PriceUsd = float64(1000 * revision)
+		require.NoError(t, store.Save(version))
+		versions = append(versions, version)
+	}
+	other.Revision = 1
+	require.NoError(t, store.Save(other))
+
+	// Saving a revision again replaces it
+	versions[2].PriceUsd = 3500
+	require.NoError(t, store.Save(versions[2]))
+
+	require.True(t, proto.Equal(versions[0], store.Find(laptop.Id, 2)))
+	require.Equal(t, 3500.0, store.Find(laptop.Id, 3).GetPriceUsd())
+	require.Nil(t, store.Find(laptop.Id, 4))
+	require.Nil(t, store.Find(sample.NewLaptop().Id, 1))
+
+	revisions := []uint64{}
+	err := store.List(laptop.Id, func(laptop *pb.Laptop) error {
+		revisions = append(revisions, laptop.GetRevision())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3}, revisions)
+
+	require.NoError(t, store.Delete(laptop.Id))
+	require.NoError(t, store.Delete(laptop.Id))
+	require.Nil(t, store.Find(laptop.Id, 1))
+	require.NotNil(t, store.Find(other.Id, 1))
+}
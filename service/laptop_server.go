@@ -1,89 +1,848 @@
 package service
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"gobook/pb"
 	"io"
 	"log"
+	"math"
+	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-const maxImageSize = 1 << 20
+const (
+	// maxImageSize is the size of the largest image accepted, streamed to disk as it is uploaded
+	maxImageSize = 512 << 20
+	// imageChunkSize is the size of the chunks of a downloaded image
+	imageChunkSize = 32 << 10
+	// maxUpdateAttempts is how many times a masked update is retried when the laptop changes concurrently
+	maxUpdateAttempts = 3
+)
+
+type LaptopServer struct {
+	pb.UnimplementedLaptopServiceServer
+	laptopStore LaptopStore
+	imageStore  ImageStore
+	ratingStore RatingStore
+	// idempotencyStore remembers the responses of the create and upload requests with an idempotency key
+	idempotencyStore IdempotencyStore
+	// revisionStore keeps every version of the laptops written through the server
+	revisionStore LaptopRevisionStore
+	// imageUploads keeps the resumable image uploads in progress
+	imageUploads *imageUploadStore
+	// imageResizer generates the variants of the uploaded images, if any
+	imageResizer *ImageResizer
+}
+
+// LaptopServerOption configures an optional part of a LaptopServer
+type LaptopServerOption func(server *LaptopServer)
+
+// WithIdempotencyStore replaces the store remembering the responses of requests with an idempotency key
+func WithIdempotencyStore(store IdempotencyStore) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.idempotencyStore = store
+	}
+}
+
+// WithImageUploadTTL sets how long a resumable image upload is kept after its last chunk
+func WithImageUploadTTL(ttl time.Duration) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.imageUploads = newImageUploadStore(ttl, maxImageSize)
+	}
+}
+
+// WithImageResizer generates the variants of every uploaded image with resizer
+func WithImageResizer(resizer *ImageResizer) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.imageResizer = resizer
+	}
+}
+
+// WithRevisionStore replaces the store keeping every version of the laptops, nil keeps none
+func WithRevisionStore(store LaptopRevisionStore) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.revisionStore = store
+	}
+}
+
+func NewLaptopServer(
+	laptopStore LaptopStore,
+	imageStore ImageStore,
+	ratingStore RatingStore,
+	options ...LaptopServerOption,
+) pb.LaptopServiceServer {
+	server := &LaptopServer{
+		laptopStore:      laptopStore,
+		imageStore:       imageStore,
+		ratingStore:      ratingStore,
+		idempotencyStore: NewInMemoryIdempotencyStore(DefaultIdempotencyTTL),
+		revisionStore:    NewInMemoryLaptopRevisionStore(),
+		imageUploads:     newImageUploadStore(DefaultImageUploadTTL, maxImageSize),
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	return server
+}
+
+func (server *LaptopServer) CreateLaptopService(
+	ctx context.Context,
+	req *pb.CreateLaptopRequest,
+) (*pb.CreateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+
+	log.Printf("create a new laptop id: %s", laptop.GetId())
+
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot marshal request: %v", err)
+	}
+
+	rsp := &pb.CreateLaptopResponse{}
+	err = server.idempotent(ctx, "CreateLaptopService", fingerprint, rsp, func() error {
+		err := prepareNewLaptop(laptop)
+		if err != nil {
+			return err
+		}
+
+		// Save the laptop to in memory store
+		err = server.laptopStore.Save(laptop)
+		if err != nil {
+			code := codes.Internal
+			if errors.Is(err, ErrAlreadyExists) {
+				code = codes.AlreadyExists
+			}
+			return status.Errorf(code, "cannot save laptop to in-memory store %v", err)
+		}
+		server.saveRevision(laptop)
+
+		rsp.Id = laptop.Id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp, nil
+}
+
+// idempotent runs create once per idempotency key of the request: a retry with the same key
+// and fingerprint gets the response of the first request in res, without running create again.
+// A failed request does not keep its key, and requests without key always run create.
+func (server *LaptopServer) idempotent(
+	ctx context.Context,
+	method string,
+	fingerprint []byte,
+	res proto.Message,
+	create func() error,
+) error {
+	key := idempotencyKey(ctx)
+	if key == "" || server.idempotencyStore == nil {
+		return create()
+	}
+
+	// Keys of different users and methods do not collide
+	username := ""
+	if claims := claimsFromContext(ctx); claims != nil {
+		username = claims.Username
+	}
+	key = username + "/" + method + "/" + key
+
+	saved, err := server.idempotencyStore.Begin(key, fingerprint)
+	if errors.Is(err, ErrIdempotencyKeyReused) {
+		return status.Errorf(codes.InvalidArgument, "cannot use idempotency key: %v", err)
+	}
+	if errors.Is(err, ErrIdempotencyKeyInUse) {
+		return status.Errorf(codes.Aborted, "cannot use idempotency key: %v", err)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot use idempotency key: %v", err)
+	}
+
+	if saved != nil {
+		log.Printf("replay the response of idempotency key %s", key)
+		err = proto.Unmarshal(saved, res)
+		if err != nil {
+			return status.Errorf(codes.Internal, "cannot unmarshal saved response: %v", err)
+		}
+		return nil
+	}
+
+	err = create()
+	if err != nil {
+		server.idempotencyStore.Cancel(key)
+		return err
+	}
+
+	data, err := proto.Marshal(res)
+	if err != nil {
+		// The request succeeded, only a retry of it will run again
+		log.Printf("cannot save the response of idempotency key %s: %v", key, err)
+		server.idempotencyStore.Cancel(key)
+		return nil
+	}
+
+	server.idempotencyStore.Finish(key, data)
+	return nil
+}
+
+// CreateLaptops creates the laptops of the stream and reports the result of each one.
+// A failed laptop does not stop the others, unless the options ask for an atomic batch:
+// then every laptop is checked first and they are saved together, or none of them if any laptop fails.
+func (server *LaptopServer) CreateLaptops(stream pb.LaptopService_CreateLaptopsServer) error {
+	log.Print("receive a create-laptops request")
+
+	atomic := false
+	laptops := []*pb.Laptop{}
+	res := &pb.CreateLaptopsResponse{}
+
+	for {
+		err := contextError(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Unknown, "cannot receive laptop: %v", err)
+		}
+
+		if options := req.GetOptions(); options != nil {
+			if len(laptops) > 0 {
+				return status.Errorf(codes.InvalidArgument, "options must be sent before the laptops")
+			}
+			atomic = options.GetAtomic()
+			continue
+		}
+
+		laptop := req.GetLaptop()
+		result := &pb.CreateLaptopResult{
+			Index: uint32(len(laptops)),
+		}
+		laptops = append(laptops, laptop)
+		res.Results = append(res.Results, result)
+
+		err = prepareNewLaptop(laptop)
+		if err == nil && !atomic {
+			err = server.saveNewLaptop(laptop)
+		}
+		setCreateLaptopResult(result, laptop, err)
+		if err == nil && !atomic {
+			res.CreatedCount++
+		}
+	}
+
+	if atomic {
+		server.createLaptopsAtomically(laptops, res)
+	}
+
+	log.Printf("created %d of %d laptops", res.CreatedCount, len(laptops))
+	return stream.SendAndClose(res)
+}
+
+// createLaptopsAtomically saves the prepared laptops in a single store write
+// if none of them failed or already exists
+func (server *LaptopServer) createLaptopsAtomically(laptops []*pb.Laptop, res *pb.CreateLaptopsResponse) {
+	ids := make(map[string]bool)
+	failed := false
+
+	for i, result := range res.Results {
+		if result.Code != int32(codes.OK) {
+			failed = true
+			continue
+		}
+
+		id := laptops[i].GetId()
+		if ids[id] || server.laptopStore.Find(id) != nil || server.laptopStore.FindDeleted(id) != nil {
+			setCreateLaptopResult(result, laptops[i], status.Errorf(codes.AlreadyExists, "laptop %s already exists", id))
+			failed = true
+		}
+		ids[id] = true
+	}
+
+	if !failed {
+		err := server.laptopStore.SaveAll(laptops)
+		if err == nil {
+			for _, laptop := range laptops {
+				server.saveRevision(laptop)
+			}
+			res.CreatedCount = uint32(len(laptops))
+			return
+		}
+
+		// Another request may have created one of the laptops since they were checked
+		for i, result := range res.Results {
+			setCreateLaptopResult(result, laptops[i], status.Errorf(storeErrorCode(err), "cannot save laptops: %v", err))
+		}
+		return
+	}
+
+	for _, result := range res.Results {
+		if result.Code == int32(codes.OK) {
+			result.Code = int32(codes.Aborted)
+			result.Message = "laptop is not created since another laptop of the atomic batch failed"
+		}
+	}
+}
+
+func (server *LaptopServer) saveNewLaptop(laptop *pb.Laptop) error {
+	err := server.laptopStore.Save(laptop)
+	if err != nil {
+		return status.Errorf(storeErrorCode(err), "cannot save laptop: %v", err)
+	}
+	server.saveRevision(laptop)
+
+	return nil
+}
+
+// saveRevision keeps the version of a laptop just written. The write already succeeded,
+// so a failure only loses the version.
+func (server *LaptopServer) saveRevision(laptop *pb.Laptop) {
+	if server.revisionStore == nil || laptop == nil {
+		return
+	}
+
+	err := server.revisionStore.Save(laptop)
+	if err != nil {
+		log.Printf("cannot save revision %d of laptop %s: %v", laptop.GetRevision(), laptop.GetId(), err)
+	}
+}
+
+// prepareNewLaptop checks the id and the fields of a laptop to create, generates an id if it has none,
+// and sets its update time
+func prepareNewLaptop(laptop *pb.Laptop) error {
+	if laptop == nil {
+		return status.Errorf(codes.InvalidArgument, "laptop is required")
+	}
+
+	if len(laptop.Id) > 0 {
+		//Check if id is valid or not
+		_, err := uuid.Parse(laptop.Id)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "id is invalid %v", err)
+		}
+	}
+
+	err := validateLaptop(laptop)
+	if err != nil {
+		return err
+	}
+
+	if len(laptop.Id) == 0 {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return status.Errorf(codes.Internal, "cannot random id %v", err)
+
+		}
+		laptop.Id = id.String()
+	}
+
+	laptop.UpdatedAt = timestamppb.Now()
+	return nil
+}
+
+func setCreateLaptopResult(result *pb.CreateLaptopResult, laptop *pb.Laptop, err error) {
+	st := status.Convert(err)
+
+	result.Id = laptop.GetId()
+	result.Code = int32(st.Code())
+	result.Message = st.Message()
+}
+
+func (server *LaptopServer) GetLaptop(
+	ctx context.Context,
+	req *pb.GetLaptopRequest,
+) (*pb.GetLaptopResponse, error) {
+	id := req.GetId()
+
+	log.Printf("receive a get-laptop request with id: %s", id)
+
+	err := checkLaptopID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	laptop := server.laptopStore.Find(id)
+	if laptop == nil {
+		return nil, status.Errorf(codes.NotFound, "cannot find laptop %s", id)
+	}
+
+	rsp := &pb.GetLaptopResponse{
+		Laptop: laptop,
+	}
+
+	return rsp, nil
+}
+
+func (server *LaptopServer) UpdateLaptop(
+	ctx context.Context,
+	req *pb.UpdateLaptopRequest,
+) (*pb.UpdateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+	if laptop == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop is required")
+	}
+
+	log.Printf("receive an update-laptop request with id: %s", laptop.GetId())
+
+	err := checkLaptopID(laptop.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	mask := req.GetUpdateMask()
+	if len(mask.GetPaths()) > 0 {
+		err = validateLaptopFieldMask(mask)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid update mask: %v", err)
+		}
+	}
+
+	expectedRevision := req.GetExpectedRevision()
+
+	for attempt := 1; ; attempt++ {
+		// The revision and the deletion time of a full update are kept by the store, not sent by the client
+		updated := proto.Clone(laptop).(*pb.Laptop)
+		updated.Revision = 0
+		updated.DeletedAt = nil
+		revision := expectedRevision
+
+		if len(mask.GetPaths()) > 0 {
+			stored := server.laptopStore.Find(laptop.GetId())
+			if stored == nil {
+				return nil, status.Errorf(codes.NotFound, "cannot find laptop %s", laptop.GetId())
+			}
+
+			// Apply the mask on a copy so the stored laptop is only changed by the store
+			updated = proto.Clone(stored).(*pb.Laptop)
+			applyLaptopFieldMask(updated, laptop, mask)
+
+			if revision == 0 {
+				// Without an expected revision, the mask must still be applied on the revision we read
+				revision = stored.GetRevision()
+			}
+		}
+
+		// The whole laptop is validated, a mask may leave a required field empty
+		err = validateLaptop(updated)
+		if err != nil {
+			return nil, err
+		}
+
+		updated.UpdatedAt = timestamppb.Now()
+
+		err = server.laptopStore.Update(updated, revision)
+		if errors.Is(err, ErrRevisionMismatch) && expectedRevision == 0 && attempt < maxUpdateAttempts {
+			log.Printf("laptop %s changed while updating, retry attempt %d", laptop.GetId(), attempt)
+			continue
+		}
+		if err != nil {
+			return nil, status.Errorf(storeErrorCode(err), "cannot update laptop %v", err)
+		}
+
+		laptop = updated
+		break
+	}
+	server.saveRevision(laptop)
+
+	rsp := &pb.UpdateLaptopResponse{
+		Laptop: laptop,
+	}
+
+	return rsp, nil
+}
+
+func (server *LaptopServer) DeleteLaptop(
+	ctx context.Context,
+	req *pb.DeleteLaptopRequest,
+) (*pb.DeleteLaptopResponse, error) {
+	id := req.GetId()
+
+	log.Printf("receive a delete-laptop request with id: %s", id)
+
+	err := checkLaptopID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = server.laptopStore.Delete(id, req.GetExpectedRevision())
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "cannot delete laptop %v", err)
+	}
+	server.saveRevision(server.laptopStore.FindDeleted(id))
+
+	rsp := &pb.DeleteLaptopResponse{
+		Id: id,
+	}
+
+	return rsp, nil
+}
+
+// ListDeletedLaptops sends the laptops of the trash, until they are purged
+func (server *LaptopServer) ListDeletedLaptops(
+	req *pb.ListDeletedLaptopsRequest,
+	stream pb.LaptopService_ListDeletedLaptopsServer,
+) error {
+	log.Print("receive a list-deleted-laptops request")
+
+	err := server.laptopStore.ListDeleted(stream.Context(), func(laptop *pb.Laptop) error {
+		return stream.Send(&pb.ListDeletedLaptopsResponse{Laptop: laptop})
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot list deleted laptops: %v", err)
+	}
+
+	return contextError(stream.Context())
+}
+
+// UndeleteLaptop moves a laptop back from the trash
+func (server *LaptopServer) UndeleteLaptop(
+	ctx context.Context,
+	req *pb.UndeleteLaptopRequest,
+) (*pb.UndeleteLaptopResponse, error) {
+	id := req.GetId()
+
+	log.Printf("receive an undelete-laptop request with id: %s", id)
+
+	err := checkLaptopID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = server.laptopStore.Undelete(id, req.GetExpectedRevision())
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "cannot undelete laptop %v", err)
+	}
+
+	laptop := server.laptopStore.Find(id)
+	if laptop == nil {
+		return nil, status.Errorf(codes.NotFound, "laptop %s was deleted again", id)
+	}
+	server.saveRevision(laptop)
+
+	rsp := &pb.UndeleteLaptopResponse{
+		Laptop: laptop,
+	}
+
+	return rsp, nil
+}
+
+// ListLaptopRevisions returns every version of a laptop, oldest first
+func (server *LaptopServer) ListLaptopRevisions(
+	ctx context.Context,
+	req *pb.ListLaptopRevisionsRequest,
+) (*pb.ListLaptopRevisionsResponse, error) {
+	id := req.GetId()
+
+	log.Printf("receive a list-laptop-revisions request with id: %s", id)
+
+	err := checkLaptopID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if server.revisionStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "laptop revisions are not kept")
+	}
+
+	rsp := &pb.ListLaptopRevisionsResponse{}
+	err = server.revisionStore.List(id, func(laptop *pb.Laptop) error {
+		rsp.Revisions = append(rsp.Revisions, laptop)
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot list laptop revisions: %v", err)
+	}
+
+	if len(rsp.Revisions) == 0 && server.laptopStore.Find(id) == nil && server.laptopStore.FindDeleted(id) == nil {
+		return nil, status.Errorf(codes.NotFound, "laptop %s is not found", id)
+	}
+
+	return rsp, nil
+}
+
+// GetLaptopRevision returns the version of a laptop at a revision
+func (server *LaptopServer) GetLaptopRevision(
+	ctx context.Context,
+	req *pb.GetLaptopRevisionRequest,
+) (*pb.GetLaptopRevisionResponse, error) {
+	log.Printf("receive a get-laptop-revision request with id: %s, revision: %d", req.GetId(), req.GetRevision())
+
+	laptop, err := server.findRevision(req.GetId(), req.GetRevision())
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &pb.GetLaptopRevisionResponse{
+		Laptop: laptop,
+	}
+
+	return rsp, nil
+}
+
+// DiffLaptopRevisions returns the fields changed from a version of a laptop to another
+func (server *LaptopServer) DiffLaptopRevisions(
+	ctx context.Context,
+	req *pb.DiffLaptopRevisionsRequest,
+) (*pb.DiffLaptopRevisionsResponse, error) {
+	log.Printf(
+		"receive a diff-laptop-revisions request with id: %s, from revision %d to %d",
+		req.GetId(),
+		req.GetFromRevision(),
+		req.GetToRevision(),
+	)
+
+	from, err := server.findRevision(req.GetId(), req.GetFromRevision())
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := server.findRevision(req.GetId(), req.GetToRevision())
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &pb.DiffLaptopRevisionsResponse{
+		Changes: diffLaptops(from, to),
+	}
+
+	return rsp, nil
+}
+
+func (server *LaptopServer) findRevision(id string, revision uint64) (*pb.Laptop, error) {
+	err := checkLaptopID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "revision is required")
+	}
+
+	if server.revisionStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "laptop revisions are not kept")
+	}
+
+	laptop := server.revisionStore.Find(id, revision)
+	if laptop == nil {
+		return nil, status.Errorf(codes.NotFound, "revision %d of laptop %s is not found", revision, id)
+	}
+
+	return laptop, nil
+}
+
+func (server *LaptopServer) SearchLaptopService(
+	req *pb.SearchLaptopRequest,
+	stream pb.LaptopService_SearchLaptopServiceServer,
+) error {
+	filter := req.GetFilter()
+
+	log.Printf("receive a search-laptop request with filter: %v, query: %q", filter, req.GetQuery())
+
+	query, err := ParseLaptopQuery(req.GetQuery())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid query: %v", err)
+	}
+
+	send := func(laptop *pb.Laptop) error {
+		if !query.Match(laptop) {
+			return nil
+		}
 
-type LaptopServer struct {
-	pb.UnimplementedLaptopServiceServer
-	laptopStore LaptopStore
-	imageStore  ImageStore
-	ratingStore RatingStore
-}
+		res := &pb.SearchLaptopResponse{
+			Laptop: laptop,
+		}
+
+		err := stream.Send(res)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Sent laptop with id %s", laptop.GetId())
+		return nil
+	}
+
+	if len(req.GetOrderBy()) == 0 {
+		err = server.laptopStore.Search(stream.Context(), filter, send)
+		if err != nil {
+			return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		}
+
+		return nil
+	}
+
+	// Sorting needs every match before the first one can be sent
+	order, err := parseLaptopOrder(req.GetOrderBy())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid order by: %v", err)
+	}
+
+	laptops, err := collectLaptops(stream.Context(), server.laptopStore, filter, query)
+	if err != nil {
+		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	order.sortLaptops(laptops, server.ratingStore)
 
-func NewLaptopServer(laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) pb.LaptopServiceServer {
-	return &LaptopServer{
-		laptopStore: laptopStore,
-		imageStore:  imageStore,
-		ratingStore: ratingStore,
+	for _, laptop := range laptops {
+		err := send(laptop)
+		if err != nil {
+			return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		}
 	}
+
+	return nil
 }
 
-func (server *LaptopServer) CreateLaptopService(
+func (server *LaptopServer) ListLaptops(
 	ctx context.Context,
-	req *pb.CreateLaptopRequest,
-) (*pb.CreateLaptopResponse, error) {
-	laptop := req.GetLaptop()
+	req *pb.ListLaptopsRequest,
+) (*pb.ListLaptopsResponse, error) {
+	filter := req.GetFilter()
 
-	log.Printf("create a new laptop id: %s", laptop.Id)
+	log.Printf("receive a list-laptops request with filter: %v, query: %q, order by: %q", filter, req.GetQuery(), req.GetOrderBy())
 
-	if len(laptop.Id) > 0 {
-		//Check if id is valid or not
-		_, err := uuid.Parse(laptop.Id)
+	query, err := ParseLaptopQuery(req.GetQuery())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid query: %v", err)
+	}
+
+	order, err := parseLaptopOrder(req.GetOrderBy())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid order by: %v", err)
+	}
+
+	fingerprint, err := filterFingerprint(filter, req.GetQuery())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	var after *laptopSortKey
+	if len(req.GetPageToken()) > 0 {
+		token, err := decodePageToken(req.GetPageToken())
 		if err != nil {
-			return nil, status.Errorf(codes.InvalidArgument, "id is invalid %v", err)
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
 		}
-	} else {
-		id, err := uuid.NewRandom()
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "cannot random id %v", err)
 
+		if token.OrderBy != order.String() || token.Filter != fingerprint {
+			return nil, status.Errorf(codes.InvalidArgument, "page token does not match the filter or order by")
 		}
-		laptop.Id = id.String()
+
+		after = &token.After
 	}
 
-	// Save the laptop to in memory store
-	err := server.laptopStore.Save(laptop)
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	laptops, err := collectLaptops(ctx, server.laptopStore, filter, query)
 	if err != nil {
-		code := codes.Internal
-		if errors.Is(err, ErrAlreadyExists) {
-			code = codes.AlreadyExists
-		}
-		return nil, status.Errorf(code, "cannot save laptop to in-memory store %v", err)
+		return nil, status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	keys := order.sortLaptops(laptops, server.ratingStore)
+
+	// The page starts right after the last laptop of the previous page, so laptops
+	// added in the meantime never shift or repeat the ones already returned
+	start := 0
+	if after != nil {
+		start = sort.Search(len(keys), func(i int) bool {
+			return order.less(*after, keys[i])
+		})
+	}
+
+	end := start + pageSize
+	if end > len(laptops) {
+		end = len(laptops)
+	}
+
+	rsp := &pb.ListLaptopsResponse{
+		Laptops: laptops[start:end],
 	}
 
-	rsp := &pb.CreateLaptopResponse{
-		Id: laptop.Id,
+	if end < len(laptops) {
+		token := &pageToken{
+			OrderBy: order.String(),
+			Filter:  fingerprint,
+			After:   keys[end-1],
+		}
+
+		rsp.NextPageToken, err = encodePageToken(token)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "%v", err)
+		}
 	}
 
 	return rsp, nil
 }
 
-func (server *LaptopServer) SearchLaptopService(
-	req *pb.SearchLaptopRequest,
-	stream pb.LaptopService_SearchLaptopServiceServer,
+func (server *LaptopServer) SearchFacets(
+	ctx context.Context,
+	req *pb.SearchFacetsRequest,
+) (*pb.SearchFacetsResponse, error) {
+	filter := req.GetFilter()
+
+	log.Printf("receive a search-facets request with filter: %v, query: %q", filter, req.GetQuery())
+
+	query, err := ParseLaptopQuery(req.GetQuery())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid query: %v", err)
+	}
+
+	priceBucketUsd := req.GetPriceBucketUsd()
+	if priceBucketUsd < 0 || math.IsNaN(priceBucketUsd) || math.IsInf(priceBucketUsd, 0) {
+		return nil, status.Errorf(codes.InvalidArgument, "price bucket must be a positive number")
+	}
+
+	laptops, err := collectLaptops(ctx, server.laptopStore, filter, query)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	return computeLaptopFacets(laptops, priceBucketUsd), nil
+}
+
+func (server *LaptopServer) WatchLaptops(
+	req *pb.WatchLaptopsRequest,
+	stream pb.LaptopService_WatchLaptopsServer,
 ) error {
 	filter := req.GetFilter()
 
-	log.Printf("receive a search-laptop request with filter: %v", filter)
+	log.Printf("receive a watch-laptops request after sequence %d with filter: %v, query: %q",
+		req.GetAfterSequence(), filter, req.GetQuery())
+
+	query, err := ParseLaptopQuery(req.GetQuery())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid query: %v", err)
+	}
 
-	err := server.laptopStore.Search(
+	err = server.laptopStore.Watch(
 		stream.Context(),
-		filter,
-		func(laptop *pb.Laptop) error {
-			res := &pb.SearchLaptopResponse{
-				Laptop: laptop,
+		req.GetAfterSequence(),
+		func(event *pb.LaptopEvent) error {
+			if !isQualified(filter, event.GetLaptop()) || !query.Match(event.GetLaptop()) {
+				return nil
+			}
+
+			res := &pb.WatchLaptopsResponse{
+				Event: event,
 			}
 
 			err := stream.Send(res)
@@ -91,16 +850,19 @@ func (server *LaptopServer) SearchLaptopService(
 				return err
 			}
 
-			log.Printf("Sent laptop with id %s", laptop.GetId())
+			log.Printf("sent %s event %d for laptop %s", event.GetType(), event.GetSequence(), event.GetLaptop().GetId())
 			return nil
 		},
 	)
 
+	if errors.Is(err, ErrEventsExpired) {
+		return status.Errorf(codes.OutOfRange, "cannot resume watch after sequence %d: %v", req.GetAfterSequence(), err)
+	}
 	if err != nil {
 		return status.Errorf(codes.Internal, "unexpected error: %v", err)
 	}
 
-	return nil
+	return contextError(stream.Context())
 }
 
 func (server *LaptopServer) UploadImageService(stream pb.LaptopService_UploadImageServiceServer) error {
@@ -114,14 +876,37 @@ func (server *LaptopServer) UploadImageService(stream pb.LaptopService_UploadIma
 	imageType := req.GetInfo().GetImageType()
 	log.Printf("receive an upload-image request for laptop %s with image type %s", laptopID, imageType)
 
+	if server.imageStore == nil {
+		return status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
 	laptop := server.laptopStore.Find(laptopID)
 
 	if laptop == nil {
 		return status.Errorf(codes.InvalidArgument, "cannot found laptop %s", laptopID)
 	}
 
-	imageData := bytes.Buffer{}
-	imageSize := 0
+	// The fingerprint of an upload covers its info and image data
+	fingerprint := sha256.New()
+	info, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.GetInfo())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "cannot marshal image info: %v", err)
+	}
+	fingerprint.Write(info)
+
+	// The chunks go straight to disk, the image is only stored once they are all received
+	imageWriter, err := server.imageStore.Create(laptopID, imageType)
+	if err != nil {
+		return status.Errorf(uploadErrorCode(err), "cannot create image: %v", err)
+	}
+	defer func() {
+		err := imageWriter.Abort()
+		if err != nil {
+			log.Printf("cannot abort image upload: %v", err)
+		}
+	}()
+
+	var imageSize int64
 
 	for {
 		err := contextError(stream.Context())
@@ -148,28 +933,37 @@ func (server *LaptopServer) UploadImageService(stream pb.LaptopService_UploadIma
 
 		log.Printf("receive chunk data size %d ", size)
 
-		imageSize += size
+		imageSize += int64(size)
 
 		if imageSize > maxImageSize {
 			return status.Errorf(codes.InvalidArgument, "image is too big %d > %d", imageSize, maxImageSize)
 		}
 
-		_, err = imageData.Write(chunk)
+		_, err = imageWriter.Write(chunk)
 
 		if err != nil {
-			return status.Errorf(codes.Unknown, "cannot write data: %v", err)
+			return status.Errorf(codes.Internal, "cannot write data: %v", err)
 		}
+		fingerprint.Write(chunk)
 	}
 
-	imageID, err := server.imageStore.Save(laptopID, imageType, imageData)
+	// A replayed upload is not committed, so its copy of the image is discarded
+	res := &pb.UploadImageResponse{}
+	err = server.idempotent(stream.Context(), "UploadImageService", fingerprint.Sum(nil), res, func() error {
+		imageID, err := imageWriter.Commit()
 
-	if err != nil {
-		return status.Errorf(codes.Internal, "cannot store image %v", err)
-	}
+		if err != nil {
+			return status.Errorf(uploadErrorCode(err), "cannot store image %v", err)
+		}
 
-	res := &pb.UploadImageResponse{
-		Id:   imageID,
-		Size: uint32(imageSize),
+		server.resizeImage(imageID)
+
+		res.Id = imageID
+		res.Size = uint32(imageSize)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	err = stream.SendAndClose(res)
@@ -178,11 +972,289 @@ func (server *LaptopServer) UploadImageService(stream pb.LaptopService_UploadIma
 		return status.Errorf(codes.Unknown, "cannot send response: %v ", err)
 	}
 
-	log.Printf("save image with id: %s with size: %d", imageID, imageSize)
+	log.Printf("save image with id: %s with size: %d", res.Id, res.Size)
 
 	return nil
 }
 
+// StartImageUpload begins a resumable upload of an image for a laptop
+func (server *LaptopServer) StartImageUpload(
+	ctx context.Context,
+	req *pb.StartImageUploadRequest,
+) (*pb.StartImageUploadResponse, error) {
+	laptopID := req.GetInfo().GetLaptopId()
+	imageType := req.GetInfo().GetImageType()
+
+	log.Printf("receive a start-image-upload request for laptop %s with image type %s", laptopID, imageType)
+
+	if server.imageStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
+	laptop := server.laptopStore.Find(laptopID)
+	if laptop == nil {
+		return nil, status.Errorf(codes.NotFound, "cannot find laptop %s", laptopID)
+	}
+
+	imageWriter, err := server.imageStore.Create(laptopID, imageType)
+	if err != nil {
+		return nil, status.Errorf(uploadErrorCode(err), "cannot create image: %v", err)
+	}
+
+	uploadID, expiresAt, err := server.imageUploads.start(laptopID, imageType, imageWriter)
+	if err != nil {
+		imageWriter.Abort()
+		return nil, status.Errorf(uploadErrorCode(err), "cannot start upload: %v", err)
+	}
+
+	rsp := &pb.StartImageUploadResponse{
+		UploadId:  uploadID,
+		ExpiresAt: timestamppb.New(expiresAt),
+	}
+
+	return rsp, nil
+}
+
+// UploadImageChunk adds a chunk to a resumable upload at the committed offset
+func (server *LaptopServer) UploadImageChunk(
+	ctx context.Context,
+	req *pb.UploadImageChunkRequest,
+) (*pb.UploadImageChunkResponse, error) {
+	uploadID := req.GetUploadId()
+
+	log.Printf("receive an upload-image-chunk request for upload %s at offset %d with size %d",
+		uploadID, req.GetOffset(), len(req.GetData()))
+
+	if server.imageStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
+	offset, err := server.imageUploads.write(uploadID, int64(req.GetOffset()), req.GetData(), req.GetCrc32C())
+	if err != nil {
+		return nil, status.Errorf(uploadErrorCode(err), "cannot upload chunk at offset %d: %v (committed offset %d)",
+			req.GetOffset(), err, offset)
+	}
+
+	rsp := &pb.UploadImageChunkResponse{
+		CommittedOffset: uint64(offset),
+	}
+
+	return rsp, nil
+}
+
+// GetImageUpload returns the progress of a resumable upload, to resume it after reconnecting
+func (server *LaptopServer) GetImageUpload(
+	ctx context.Context,
+	req *pb.GetImageUploadRequest,
+) (*pb.GetImageUploadResponse, error) {
+	uploadID := req.GetUploadId()
+
+	log.Printf("receive a get-image-upload request for upload %s", uploadID)
+
+	if server.imageStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
+	upload, err := server.imageUploads.status(uploadID)
+	if err != nil {
+		return nil, status.Errorf(uploadErrorCode(err), "cannot get upload: %v", err)
+	}
+
+	rsp := &pb.GetImageUploadResponse{
+		Info: &pb.ImageInfo{
+			LaptopId:  upload.LaptopID,
+			ImageType: upload.ImageType,
+		},
+		CommittedOffset: uint64(upload.Offset),
+		ExpiresAt:       timestamppb.New(upload.ExpiresAt),
+		ImageId:         upload.ImageID,
+	}
+
+	return rsp, nil
+}
+
+// FinishImageUpload stores the uploaded image once its SHA-256 is verified
+func (server *LaptopServer) FinishImageUpload(
+	ctx context.Context,
+	req *pb.FinishImageUploadRequest,
+) (*pb.FinishImageUploadResponse, error) {
+	uploadID := req.GetUploadId()
+
+	log.Printf("receive a finish-image-upload request for upload %s", uploadID)
+
+	if server.imageStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
+	if len(req.GetSha256()) != sha256.Size {
+		return nil, status.Errorf(codes.InvalidArgument, "sha256 must have %d bytes", sha256.Size)
+	}
+
+	imageID, size, err := server.imageUploads.finish(uploadID, req.GetSha256())
+	if err != nil {
+		return nil, status.Errorf(uploadErrorCode(err), "cannot finish upload: %v", err)
+	}
+
+	log.Printf("save image with id: %s with size: %d", imageID, size)
+	server.resizeImage(imageID)
+
+	rsp := &pb.FinishImageUploadResponse{
+		Id:   imageID,
+		Size: uint64(size),
+	}
+
+	return rsp, nil
+}
+
+// ListLaptopImages returns the images of a laptop, oldest first
+func (server *LaptopServer) ListLaptopImages(
+	ctx context.Context,
+	req *pb.ListLaptopImagesRequest,
+) (*pb.ListLaptopImagesResponse, error) {
+	laptopID := req.GetLaptopId()
+
+	log.Printf("receive a list-laptop-images request for laptop %s", laptopID)
+
+	err := checkLaptopID(laptopID)
+	if err != nil {
+		return nil, err
+	}
+
+	if server.imageStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
+	// The images of a deleted laptop are kept until it is purged
+	if server.laptopStore.Find(laptopID) == nil && server.laptopStore.FindDeleted(laptopID) == nil {
+		return nil, status.Errorf(codes.NotFound, "cannot find laptop %s", laptopID)
+	}
+
+	images := []*pb.ImageInfo{}
+	err = server.imageStore.ListLaptopImages(laptopID, func(imageID string, info *ImageInfo) error {
+		images = append(images, newImageInfo(imageID, info))
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot list images: %v", err)
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		uploadedAt1 := images[i].GetUploadedAt().AsTime()
+		uploadedAt2 := images[j].GetUploadedAt().AsTime()
+		if !uploadedAt1.Equal(uploadedAt2) {
+			return uploadedAt1.Before(uploadedAt2)
+		}
+		return images[i].GetId() < images[j].GetId()
+	})
+
+	rsp := &pb.ListLaptopImagesResponse{
+		Images: images,
+	}
+
+	return rsp, nil
+}
+
+// DownloadImage sends the info of an image, then its data in chunks
+func (server *LaptopServer) DownloadImage(
+	req *pb.DownloadImageRequest,
+	stream pb.LaptopService_DownloadImageServer,
+) error {
+	imageID := req.GetId()
+
+	log.Printf("receive a download-image request with id: %s and variant: %q", imageID, req.GetVariant())
+
+	if len(imageID) == 0 {
+		return status.Errorf(codes.InvalidArgument, "image id is required")
+	}
+
+	if server.imageStore == nil {
+		return status.Errorf(codes.Unimplemented, "images are not stored")
+	}
+
+	info, file, err := server.imageStore.Open(imageID, req.GetVariant())
+	if errors.Is(err, ErrImageNotFound) {
+		return status.Errorf(codes.NotFound, "cannot find image %s", imageID)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot open image: %v", err)
+	}
+	defer file.Close()
+
+	imageInfo := newImageInfo(imageID, info)
+	imageInfo.Variant = req.GetVariant()
+
+	err = stream.Send(&pb.DownloadImageResponse{
+		Data: &pb.DownloadImageResponse_Info{
+			Info: imageInfo,
+		},
+	})
+	if err != nil {
+		return status.Errorf(codes.Unknown, "cannot send image info: %v", err)
+	}
+
+	buffer := make([]byte, imageChunkSize)
+	for {
+		err := contextError(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		n, err := file.Read(buffer)
+		if n > 0 {
+			sendErr := stream.Send(&pb.DownloadImageResponse{
+				Data: &pb.DownloadImageResponse_ChunkData{
+					ChunkData: buffer[:n],
+				},
+			})
+			if sendErr != nil {
+				return status.Errorf(codes.Unknown, "cannot send chunk data: %v", sendErr)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "cannot read image: %v", err)
+		}
+	}
+}
+
+// resizeImage queues a new image to generate its variants
+func (server *LaptopServer) resizeImage(imageID string) {
+	if server.imageResizer != nil {
+		server.imageResizer.Add(imageID)
+	}
+}
+
+// newImageInfo returns the info sent to the clients about an image
+func newImageInfo(imageID string, info *ImageInfo) *pb.ImageInfo {
+	imageInfo := &pb.ImageInfo{
+		Id:        imageID,
+		LaptopId:  info.LaptopID,
+		ImageType: info.Type,
+		Size:      uint64(info.Size),
+		MimeType:  info.MimeType,
+		Width:     uint32(info.Width),
+		Height:    uint32(info.Height),
+	}
+	if !info.UploadedAt.IsZero() {
+		imageInfo.UploadedAt = timestamppb.New(info.UploadedAt)
+	}
+	for _, variant := range info.Variants {
+		imageInfo.Variants = append(imageInfo.Variants, &pb.ImageVariant{
+			Name:      variant.Name,
+			ImageType: variant.Type,
+			Size:      uint64(variant.Size),
+			MimeType:  variant.MimeType,
+			Width:     uint32(variant.Width),
+			Height:    uint32(variant.Height),
+		})
+	}
+
+	return imageInfo
+}
+
 func (server *LaptopServer) RateLaptopService(stream pb.LaptopService_RateLaptopServiceServer) error {
 	for {
 		err := contextError(stream.Context())
@@ -233,6 +1305,50 @@ func (server *LaptopServer) RateLaptopService(stream pb.LaptopService_RateLaptop
 	return nil
 }
 
+// storeErrorCode maps an error returned by the laptop store to a gRPC code
+func storeErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, ErrAlreadyExists):
+		return codes.AlreadyExists
+	case errors.Is(err, ErrRevisionMismatch):
+		return codes.Aborted
+	default:
+		return codes.Internal
+	}
+}
+
+// uploadErrorCode maps an error returned by an image upload to a gRPC code
+func uploadErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		return codes.NotFound
+	case errors.Is(err, ErrUploadChecksumMismatch), errors.Is(err, ErrImageTooBig), errors.Is(err, ErrInvalidImage):
+		return codes.InvalidArgument
+	case errors.Is(err, ErrUploadOffsetMismatch), errors.Is(err, ErrUploadFinished):
+		return codes.FailedPrecondition
+	case errors.Is(err, ErrTooManyUploads):
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+// checkLaptopID returns an InvalidArgument error if id is empty or not a UUID
+func checkLaptopID(id string) error {
+	if len(id) == 0 {
+		return status.Errorf(codes.InvalidArgument, "laptop id is required")
+	}
+
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "id is invalid %v", err)
+	}
+
+	return nil
+}
+
 func contextError(ctx context.Context) error {
 	switch ctx.Err() {
 	case context.Canceled:
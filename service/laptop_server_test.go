@@ -1,14 +1,26 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"gobook/pb"
 	"gobook/sample"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestLaptopServer(t *testing.T) {
@@ -83,3 +95,750 @@ func TestLaptopServer(t *testing.T) {
 		})
 	}
 }
+
+func TestLaptopServerGetUpdateDelete(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	server := NewLaptopServer(store, nil, nil)
+
+	_, err = server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: "invalid-id"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: sample.NewLaptop().Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	getRes, err := server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	require.Equal(t, laptop.Id, getRes.GetLaptop().GetId())
+
+	updated := sample.NewLaptop()
+	updated.Id = laptop.Id
+	updated.Name = "fixed name"
+	updated.Revision = 42
+	updated.DeletedAt = timestamppb.Now()
+	updateRes, err := server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: updated})
+	require.NoError(t, err)
+	require.Equal(t, "fixed name", updateRes.GetLaptop().GetName())
+	require.Equal(t, "fixed name", store.Find(laptop.Id).GetName())
+	// A full update cannot trash the laptop or set its revision
+	require.Nil(t, updateRes.GetLaptop().GetDeletedAt())
+	require.Nil(t, store.Find(laptop.Id).GetDeletedAt())
+	require.Equal(t, uint64(2), store.Find(laptop.Id).GetRevision())
+
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	invalid := proto.Clone(updated).(*pb.Laptop)
+	invalid.PriceUsd = -5
+	invalid.Cpu = nil
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: invalid})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, "fixed name", store.Find(laptop.Id).GetName())
+	require.NotNil(t, store.Find(laptop.Id).GetCpu())
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	require.Nil(t, store.Find(laptop.Id))
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestLaptopServerTrash(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	client := newTestLaptopClient(t, startTestLaptopServer(t, store))
+
+	_, err = client.UndeleteLaptop(context.Background(), &pb.UndeleteLaptopRequest{Id: laptop.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+
+	stream, err := client.ListDeletedLaptops(context.Background(), &pb.ListDeletedLaptopsRequest{})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, laptop.Id, res.GetLaptop().GetId())
+	require.NotNil(t, res.GetLaptop().GetDeletedAt())
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	_, err = client.UndeleteLaptop(context.Background(), &pb.UndeleteLaptopRequest{Id: laptop.Id, ExpectedRevision: 1})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	undeleteRes, err := client.UndeleteLaptop(context.Background(), &pb.UndeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), undeleteRes.GetLaptop().GetRevision())
+	require.Nil(t, undeleteRes.GetLaptop().GetDeletedAt())
+	require.NotNil(t, store.Find(laptop.Id))
+}
+
+func TestLaptopServerUpdateMask(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	server := NewLaptopServer(store, nil, nil)
+
+	patch := &pb.Laptop{
+		Id:          laptop.Id,
+		PriceUsd:    999,
+		ReleaseYear: 2021,
+		Cpu:         &pb.CPU{NumberCores: 16, NumberThreads: 32},
+	}
+	req := &pb.UpdateLaptopRequest{
+		Laptop:     patch,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "release_year", "cpu.number_cores", "cpu.number_threads", "gpus"}},
+	}
+
+	res, err := server.UpdateLaptop(context.Background(), req)
+	require.NoError(t, err)
+
+	updated := res.GetLaptop()
+	require.Equal(t, 999.0, updated.GetPriceUsd())
+	require.Equal(t, uint32(2021), updated.GetReleaseYear())
+	require.Equal(t, uint32(16), updated.GetCpu().GetNumberCores())
+	require.Equal(t, laptop.GetCpu().GetName(), updated.GetCpu().GetName())
+	require.Equal(t, laptop.GetName(), updated.GetName())
+	require.Empty(t, updated.GetGpus())
+	require.NotNil(t, updated.GetUpdatedAt())
+
+	// The mask must leave a valid laptop
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:     &pb.Laptop{Id: laptop.Id},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"cpu"}},
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.NotNil(t, store.Find(laptop.Id).GetCpu())
+
+	invalidPaths := [][]string{{"unknown_field"}, {"cpu.unknown"}, {"id"}, {"updated_at"}, {"revision"}, {"deleted_at"}}
+	for _, paths := range invalidPaths {
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: paths}
+		_, err = server.UpdateLaptop(context.Background(), req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), paths)
+	}
+}
+
+func TestLaptopServerRevision(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), laptop.GetRevision())
+
+	server := NewLaptopServer(store, nil, nil)
+
+	req := &pb.UpdateLaptopRequest{
+		Laptop:           &pb.Laptop{Id: laptop.Id, PriceUsd: 1000},
+		UpdateMask:       &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}},
+		ExpectedRevision: 1,
+	}
+	res, err := server.UpdateLaptop(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.GetLaptop().GetRevision())
+
+	// Another editor still holds revision 1
+	req.Laptop.PriceUsd = 2000
+	_, err = server.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.Aborted, status.Code(err))
+	require.Equal(t, 1000.0, store.Find(laptop.Id).GetPriceUsd())
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id, ExpectedRevision: 1})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id, ExpectedRevision: 2})
+	require.NoError(t, err)
+}
+
+func TestLaptopServerListLaptops(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	server := NewLaptopServer(store, nil, nil)
+	filter := &pb.Filter{}
+
+	for i := 0; i < 7; i++ {
+		err := store.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+
+	req := &pb.ListLaptopsRequest{
+		Filter:   filter,
+		PageSize: 3,
+		OrderBy:  "price_usd desc",
+	}
+
+	seen := make(map[string]bool)
+	lastPrice := 10000.0
+	pages := 0
+
+	for {
+		res, err := server.ListLaptops(context.Background(), req)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.GetLaptops()), 3)
+		pages++
+
+		for _, laptop := range res.GetLaptops() {
+			require.False(t, seen[laptop.GetId()])
+			require.LessOrEqual(t, laptop.GetPriceUsd(), lastPrice)
+			seen[laptop.GetId()] = true
+			lastPrice = laptop.GetPriceUsd()
+		}
+
+		if pages == 1 {
+			// A laptop added before the cursor must not shift the next pages
+			expensive := sample.NewLaptop()
+			expensive.PriceUsd = 4000
+			err = store.Save(expensive)
+			require.NoError(t, err)
+		}
+
+		if len(res.GetNextPageToken()) == 0 {
+			break
+		}
+		req.PageToken = res.GetNextPageToken()
+	}
+
+	require.Equal(t, 7, len(seen))
+	require.Equal(t, 3, pages)
+
+	req.OrderBy = "release_year"
+	_, err := server.ListLaptops(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.PageToken = "not-a-token"
+	_, err = server.ListLaptops(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.PageToken = ""
+	req.OrderBy = "weight"
+	_, err = server.ListLaptops(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.OrderBy = ""
+	req.Query = "price_usd >= 4000"
+	res, err := server.ListLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 1)
+
+	req.Query = "price_usd >="
+	_, err = server.ListLaptops(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestLaptopServerSearchFacets(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	server := NewLaptopServer(store, nil, nil)
+
+	prices := []float64{1200, 1400, 2100, 900}
+	brands := []string{"Dell", "Dell", "Lenovo", "Apple"}
+
+	for i := range prices {
+		laptop := sample.NewLaptop()
+		laptop.Brand = brands[i]
+		laptop.PriceUsd = prices[i]
+		laptop.Ram = &pb.Memory{Value: 12, Unit: pb.Memory_GIGABYTE}
+		err := store.Save(laptop)
+		require.NoError(t, err)
+	}
+
+	req := &pb.SearchFacetsRequest{
+		Filter:         &pb.Filter{MinPriceUsd: proto.Float64(1000)},
+		PriceBucketUsd: 1000,
+	}
+
+	res, err := server.SearchFacets(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), res.GetTotalCount())
+
+	require.Len(t, res.GetBrands(), 2)
+	require.Equal(t, "Dell", res.GetBrands()[0].GetValue())
+	require.Equal(t, uint32(2), res.GetBrands()[0].GetCount())
+	require.Equal(t, "Lenovo", res.GetBrands()[1].GetValue())
+
+	require.Len(t, res.GetRamSizes(), 1)
+	require.Equal(t, "16GB", res.GetRamSizes()[0].GetValue())
+
+	require.Len(t, res.GetPriceHistogram(), 2)
+	require.Equal(t, 1000.0, res.GetPriceHistogram()[0].GetMinPriceUsd())
+	require.Equal(t, uint32(2), res.GetPriceHistogram()[0].GetCount())
+	require.Equal(t, 2000.0, res.GetPriceHistogram()[1].GetMinPriceUsd())
+
+	req.Query = `brand = "Dell"`
+	res, err = server.SearchFacets(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), res.GetTotalCount())
+
+	for _, bucket := range []float64{-1, math.NaN(), math.Inf(1)} {
+		req.PriceBucketUsd = bucket
+		_, err = server.SearchFacets(context.Background(), req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "price bucket %v", bucket)
+	}
+}
+
+func TestRamSizeBucket(t *testing.T) {
+	testCases := []struct {
+		memory *pb.Memory
+		bucket string
+	}{
+		{&pb.Memory{Value: 0, Unit: pb.Memory_GIGABYTE}, "1GB"},
+		{&pb.Memory{Value: 512, Unit: pb.Memory_MEGABYTE}, "1GB"},
+		{&pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, "8GB"},
+		{&pb.Memory{Value: 8193, Unit: pb.Memory_MEGABYTE}, "16GB"},
+		{&pb.Memory{Value: 1 << 63, Unit: pb.Memory_BIT}, "1073741824GB"},
+		{&pb.Memory{Value: 1<<63 + 1, Unit: pb.Memory_BIT}, "2147483648GB"},
+		{&pb.Memory{Value: math.MaxUint64, Unit: pb.Memory_BIT}, "2147483648GB"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.bucket, ramSizeBucket(tc.memory), "memory %v", tc.memory)
+	}
+}
+
+func TestLaptopServerCreateLaptops(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	existing := sample.NewLaptop()
+	err := store.Save(existing)
+	require.NoError(t, err)
+
+	client := newTestLaptopClient(t, startTestLaptopServer(t, store))
+
+	createLaptops := func(options *pb.CreateLaptopsOptions, laptops ...*pb.Laptop) *pb.CreateLaptopsResponse {
+		stream, err := client.CreateLaptops(context.Background())
+		require.NoError(t, err)
+
+		if options != nil {
+			err := stream.Send(&pb.CreateLaptopsRequest{Data: &pb.CreateLaptopsRequest_Options{Options: options}})
+			require.NoError(t, err)
+		}
+		for _, laptop := range laptops {
+			err := stream.Send(&pb.CreateLaptopsRequest{Data: &pb.CreateLaptopsRequest_Laptop{Laptop: laptop}})
+			require.NoError(t, err)
+		}
+
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		require.Len(t, res.GetResults(), len(laptops))
+		return res
+	}
+
+	invalid := sample.NewLaptop()
+	invalid.Id = "invalid-id"
+	noID := sample.NewLaptop()
+	noID.Id = ""
+	atomic := &pb.CreateLaptopsOptions{Atomic: true}
+
+	// A failed laptop does not stop the others
+	created := sample.NewLaptop()
+	res := createLaptops(nil, created, existing, invalid, noID)
+	require.Equal(t, uint32(2), res.GetCreatedCount())
+	expected := []codes.Code{codes.OK, codes.AlreadyExists, codes.InvalidArgument, codes.OK}
+	for i, result := range res.GetResults() {
+		require.Equal(t, uint32(i), result.GetIndex())
+		require.Equal(t, expected[i], codes.Code(result.GetCode()), result.GetMessage())
+	}
+	require.NotNil(t, store.Find(created.Id))
+	require.NotEmpty(t, res.GetResults()[3].GetId())
+	require.NotNil(t, store.Find(res.GetResults()[3].GetId()))
+
+	// An atomic batch with a failed laptop creates nothing
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	res = createLaptops(atomic, laptop1, existing, laptop2)
+	require.Zero(t, res.GetCreatedCount())
+	expected = []codes.Code{codes.Aborted, codes.AlreadyExists, codes.Aborted}
+	for i, result := range res.GetResults() {
+		require.Equal(t, expected[i], codes.Code(result.GetCode()), result.GetMessage())
+	}
+	require.Nil(t, store.Find(laptop1.Id))
+	require.Nil(t, store.Find(laptop2.Id))
+
+	// Twice the same laptop in an atomic batch
+	res = createLaptops(atomic, laptop1, laptop1)
+	require.Zero(t, res.GetCreatedCount())
+	require.Equal(t, codes.AlreadyExists, codes.Code(res.GetResults()[1].GetCode()))
+	require.Nil(t, store.Find(laptop1.Id))
+
+	res = createLaptops(atomic, laptop1, laptop2)
+	require.Equal(t, uint32(2), res.GetCreatedCount())
+	require.NotNil(t, store.Find(laptop1.Id))
+	require.NotNil(t, store.Find(laptop2.Id))
+}
+
+func TestLaptopServerRevisions(t *testing.T) {
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+
+	laptop := sample.NewLaptop()
+	_, err := server.CreateLaptopService(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	updated := proto.Clone(laptop).(*pb.Laptop)
+	updated.PriceUsd = laptop.PriceUsd + 100
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:     updated,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}},
+	})
+	require.NoError(t, err)
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	_, err = server.UndeleteLaptop(context.Background(), &pb.UndeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+
+	listRes, err := server.ListLaptopRevisions(context.Background(), &pb.ListLaptopRevisionsRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	require.Len(t, listRes.GetRevisions(), 4)
+	for i, revision := range listRes.GetRevisions() {
+		require.Equal(t, uint64(i+1), revision.GetRevision())
+	}
+	require.NotNil(t, listRes.GetRevisions()[2].GetDeletedAt())
+
+	_, err = server.ListLaptopRevisions(context.Background(), &pb.ListLaptopRevisionsRequest{Id: sample.NewLaptop().Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	getRes, err := server.GetLaptopRevision(context.Background(), &pb.GetLaptopRevisionRequest{Id: laptop.Id, Revision: 1})
+	require.NoError(t, err)
+	require.Equal(t, laptop.PriceUsd, getRes.GetLaptop().GetPriceUsd())
+
+	_, err = server.GetLaptopRevision(context.Background(), &pb.GetLaptopRevisionRequest{Id: laptop.Id, Revision: 5})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = server.GetLaptopRevision(context.Background(), &pb.GetLaptopRevisionRequest{Id: laptop.Id})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	diffRes, err := server.DiffLaptopRevisions(context.Background(), &pb.DiffLaptopRevisionsRequest{
+		Id:           laptop.Id,
+		FromRevision: 1,
+		ToRevision:   2,
+	})
+	require.NoError(t, err)
+
+	paths := []string{}
+	for _, change := range diffRes.GetChanges() {
+		paths = append(paths, change.GetPath())
+	}
+	require.Contains(t, paths, "price_usd")
+	require.Contains(t, paths, "revision")
+	require.NotContains(t, paths, "brand")
+}
+
+func TestLaptopServerImages(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	imageStore := NewDiskImageStore(t.TempDir())
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	// The image spans several download chunks, with data after the JPEG end the decoders ignore
+	padding := make([]byte, 3*imageChunkSize)
+	_, err := rand.Read(padding)
+	require.NoError(t, err)
+	data := append(newTestImage(t, "jpeg", 16, 9), padding...)
+	imageID, err := imageStore.Save(laptop.Id, ".jpg", bytes.NewReader(data))
+	require.NoError(t, err)
+	otherID, err := imageStore.Save(laptop.Id, ".png", bytes.NewReader(newTestImage(t, "png", 1, 1)))
+	require.NoError(t, err)
+
+	client := newTestLaptopClient(t, serveTestLaptopServer(t, NewLaptopServer(laptopStore, imageStore, nil)))
+
+	listRes, err := client.ListLaptopImages(context.Background(), &pb.ListLaptopImagesRequest{LaptopId: laptop.Id})
+	require.NoError(t, err)
+	require.Len(t, listRes.GetImages(), 2)
+	image := listRes.GetImages()[0]
+	require.Equal(t, imageID, image.GetId())
+	require.Equal(t, laptop.Id, image.GetLaptopId())
+	require.Equal(t, ".jpg", image.GetImageType())
+	require.Equal(t, uint64(len(data)), image.GetSize())
+	require.NotNil(t, image.GetUploadedAt())
+	require.Equal(t, "image/jpeg", image.GetMimeType())
+	require.Equal(t, uint32(16), image.GetWidth())
+	require.Equal(t, uint32(9), image.GetHeight())
+	require.Equal(t, otherID, listRes.GetImages()[1].GetId())
+
+	_, err = client.ListLaptopImages(context.Background(), &pb.ListLaptopImagesRequest{LaptopId: sample.NewLaptop().Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.DownloadImage(context.Background(), &pb.DownloadImageRequest{Id: imageID})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.True(t, proto.Equal(image, res.GetInfo()))
+
+	downloaded := []byte{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.GetChunkData()), imageChunkSize)
+		downloaded = append(downloaded, res.GetChunkData()...)
+	}
+	require.Equal(t, data, downloaded)
+
+	stream, err = client.DownloadImage(context.Background(), &pb.DownloadImageRequest{Id: "unknown"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestLaptopServerWithoutImages(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	client := newTestLaptopClient(t, serveTestLaptopServer(t, NewLaptopServer(laptopStore, nil, nil)))
+	ctx := context.Background()
+
+	_, err := client.StartImageUpload(ctx, &pb.StartImageUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"},
+	})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = client.UploadImageChunk(ctx, &pb.UploadImageChunkRequest{UploadId: "upload"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = client.GetImageUpload(ctx, &pb.GetImageUploadRequest{UploadId: "upload"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = client.FinishImageUpload(ctx, &pb.FinishImageUploadRequest{UploadId: "upload"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = client.ListLaptopImages(ctx, &pb.ListLaptopImagesRequest{LaptopId: laptop.Id})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+
+	stream, err := client.UploadImageService(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"}},
+	}))
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestLaptopServerUploadImage(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	folder := t.TempDir()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	laptopServer := NewLaptopServer(laptopStore, NewDiskImageStore(folder), nil)
+	client := newTestLaptopClient(t, serveTestLaptopServer(t, laptopServer))
+
+	info := &pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{
+			Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"},
+		},
+	}
+	image := newTestImage(t, "jpeg", 8, 8)
+	chunk := func(i int) *pb.UploadImageRequest {
+		size := len(image)/3 + 1
+		end := (i + 1) * size
+		if end > len(image) {
+			end = len(image)
+		}
+		return &pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{ChunkData: image[i*size : end]},
+		}
+	}
+
+	// A cancelled upload leaves no file behind
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.UploadImageService(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(info))
+	require.NoError(t, stream.Send(chunk(0)))
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(folder)
+		return err == nil && len(entries) == 1
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(folder)
+		return err == nil && len(entries) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	upload := func(ctx context.Context) *pb.UploadImageResponse {
+		stream, err := client.UploadImageService(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(info))
+		for i := 0; i < 3; i++ {
+			require.NoError(t, stream.Send(chunk(i)))
+		}
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		return res
+	}
+
+	res := upload(context.Background())
+	require.Equal(t, uint32(len(image)), res.GetSize())
+	requireFolderFiles(t, folder, res.GetId()+".jpg")
+
+	// A replayed upload discards its copy of the image
+	ctx = metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyHeader, "upload")
+	res = upload(ctx)
+	require.Equal(t, res.GetId(), upload(ctx).GetId())
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(folder)
+		return err == nil && len(entries) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLaptopServerResumableUpload(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	laptopServer := NewLaptopServer(laptopStore, NewDiskImageStore(t.TempDir()), nil)
+	client := newTestLaptopClient(t, serveTestLaptopServer(t, laptopServer))
+
+	_, err := client.StartImageUpload(context.Background(), &pb.StartImageUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: sample.NewLaptop().Id, ImageType: ".jpg"},
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.StartImageUpload(context.Background(), &pb.StartImageUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".exe"},
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	startRes, err := client.StartImageUpload(context.Background(), &pb.StartImageUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".png"},
+	})
+	require.NoError(t, err)
+	uploadID := startRes.GetUploadId()
+
+	data := newTestImage(t, "png", 20, 10)
+	uploadChunk := func(offset int, end int) (*pb.UploadImageChunkResponse, error) {
+		return client.UploadImageChunk(context.Background(), &pb.UploadImageChunkRequest{
+			UploadId: uploadID,
+			Offset:   uint64(offset),
+			Data:     data[offset:end],
+			Crc32C:   crc32.Checksum(data[offset:end], castagnoliTable),
+		})
+	}
+
+	_, err = uploadChunk(0, 9)
+	require.NoError(t, err)
+
+	_, err = uploadChunk(12, len(data))
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.UploadImageChunk(context.Background(), &pb.UploadImageChunkRequest{
+		UploadId: uploadID,
+		Offset:   9,
+		Data:     data[9:],
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// After reconnecting, the upload resumes from the committed offset
+	getRes, err := client.GetImageUpload(context.Background(), &pb.GetImageUploadRequest{UploadId: uploadID})
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), getRes.GetCommittedOffset())
+	require.Equal(t, laptop.Id, getRes.GetInfo().GetLaptopId())
+	require.Empty(t, getRes.GetImageId())
+
+	chunkRes, err := uploadChunk(int(getRes.GetCommittedOffset()), len(data))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(data)), chunkRes.GetCommittedOffset())
+
+	_, err = client.FinishImageUpload(context.Background(), &pb.FinishImageUploadRequest{UploadId: uploadID})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	digest := sha256.Sum256(data)
+	finishRes, err := client.FinishImageUpload(context.Background(), &pb.FinishImageUploadRequest{
+		UploadId: uploadID,
+		Sha256:   digest[:],
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(data)), finishRes.GetSize())
+
+	getRes, err = client.GetImageUpload(context.Background(), &pb.GetImageUploadRequest{UploadId: uploadID})
+	require.NoError(t, err)
+	require.Equal(t, finishRes.GetId(), getRes.GetImageId())
+
+	stream, err := client.DownloadImage(context.Background(), &pb.DownloadImageRequest{Id: finishRes.GetId()})
+	require.NoError(t, err)
+	downloaded := []byte{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		downloaded = append(downloaded, res.GetChunkData()...)
+	}
+	require.Equal(t, data, downloaded)
+}
+
+func TestLaptopServerImageVariants(t *testing.T) {
+	laptopStore := NewInMemoryLaptopStore()
+	imageStore := NewDiskImageStore(t.TempDir())
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	resizer := NewImageResizer(imageStore, []ImageVariantSpec{{"thumbnail", 128}}, 1)
+	defer resizer.Close()
+	laptopServer := NewLaptopServer(laptopStore, imageStore, nil, WithImageResizer(resizer))
+	client := newTestLaptopClient(t, serveTestLaptopServer(t, laptopServer))
+
+	stream, err := client.UploadImageService(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{
+			Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".png"},
+		},
+	}))
+	require.NoError(t, stream.Send(&pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_ChunkData{ChunkData: newTestImage(t, "png", 512, 256)},
+	}))
+	uploadRes, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	// The variants are generated in the background
+	var image *pb.ImageInfo
+	require.Eventually(t, func() bool {
+		listRes, err := client.ListLaptopImages(context.Background(), &pb.ListLaptopImagesRequest{LaptopId: laptop.Id})
+		if err != nil || len(listRes.GetImages()) != 1 {
+			return false
+		}
+		image = listRes.GetImages()[0]
+		return len(image.GetVariants()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	variant := image.GetVariants()[0]
+	require.Equal(t, "thumbnail", variant.GetName())
+	require.Equal(t, ".png", variant.GetImageType())
+	require.Equal(t, "image/png", variant.GetMimeType())
+	require.Equal(t, uint32(128), variant.GetWidth())
+	require.Equal(t, uint32(64), variant.GetHeight())
+
+	downloadStream, err := client.DownloadImage(context.Background(), &pb.DownloadImageRequest{
+		Id:      uploadRes.GetId(),
+		Variant: "thumbnail",
+	})
+	require.NoError(t, err)
+	res, err := downloadStream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "thumbnail", res.GetInfo().GetVariant())
+	require.Equal(t, variant.GetSize(), res.GetInfo().GetSize())
+	require.Equal(t, uint32(128), res.GetInfo().GetWidth())
+
+	downloaded := []byte{}
+	for {
+		res, err := downloadStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		downloaded = append(downloaded, res.GetChunkData()...)
+	}
+	require.Len(t, downloaded, int(variant.GetSize()))
+
+	downloadStream, err = client.DownloadImage(context.Background(), &pb.DownloadImageRequest{
+		Id:      uploadRes.GetId(),
+		Variant: "unknown",
+	})
+	require.NoError(t, err)
+	_, err = downloadStream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -3,39 +3,160 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"gobook/pb"
 	"log"
 	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-var ErrAlreadyExists = errors.New("laptop already existed")
+var (
+	ErrAlreadyExists = errors.New("laptop already existed")
+	ErrNotFound      = errors.New("laptop not found")
+	// ErrRevisionMismatch is returned when a write expects another revision than the stored one
+	ErrRevisionMismatch = errors.New("laptop revision mismatch")
+)
 
 type LaptopStore interface {
 	Save(laptop *pb.Laptop) error
+	// SaveAll saves the laptops in a single write, or none of them if one already exists
+	SaveAll(laptops []*pb.Laptop) error
 	Find(id string) *pb.Laptop
+	// Update replaces the stored laptop and bumps its revision.
+	// A non-zero expectedRevision must match the stored revision.
+	Update(laptop *pb.Laptop, expectedRevision uint64) error
+	// Delete moves the laptop to the trash, where Find and Search do not see it,
+	// with deleted_at set and its revision bumped.
+	// A non-zero expectedRevision must match the stored revision.
+	Delete(id string, expectedRevision uint64) error
+	// FindDeleted returns the laptop with the id from the trash, or nil
+	FindDeleted(id string) *pb.Laptop
+	// ListDeleted calls found with every laptop of the trash
+	ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error
+	// Undelete moves the laptop back from the trash and bumps its revision.
+	// A non-zero expectedRevision must match the revision of the deleted laptop.
+	Undelete(id string, expectedRevision uint64) error
+	// Purge removes a laptop of the trash for good
+	Purge(id string) error
 	Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error
+	// Watch calls found with the changes made after the given event sequence until ctx is done.
+	// A zero sequence only watches the changes from now on.
+	Watch(ctx context.Context, afterSequence uint64, found func(event *pb.LaptopEvent) error) error
 }
 
+// InMemoryLaptopStore keeps laptops in a map, with sorted indexes on the values
+// that search filters put a range on. Stored laptops must not be modified:
+// updates replace them, so searches can send them after releasing the lock.
 type InMemoryLaptopStore struct {
 	mutex sync.RWMutex
 	data  map[string]*pb.Laptop
+	// deleted is the trash, it is neither indexed nor searched
+	deleted map[string]*pb.Laptop
+	indexes []*laptopIndex
+	events  *laptopEventLog
+	// wal is the optional write-ahead log of the changes
+	wal *storeLog
 }
 
 func NewInMemoryLaptopStore() LaptopStore {
-	return &InMemoryLaptopStore{
-		data: make(map[string]*pb.Laptop),
+	store := &InMemoryLaptopStore{
+		data:    make(map[string]*pb.Laptop),
+		deleted: make(map[string]*pb.Laptop),
+		events:  newLaptopEventLog(laptopEventHistory),
+	}
+
+	for _, index := range laptopIndexes {
+		store.indexes = append(store.indexes, newLaptopIndex(index.value))
+	}
+
+	return store
+}
+
+// NewInMemoryLaptopStoreWithWAL returns an InMemoryLaptopStore that logs its changes
+// in the laptops files of the options dir, after restoring the laptops they hold
+func NewInMemoryLaptopStoreWithWAL(options WALOptions) (*InMemoryLaptopStore, error) {
+	store := NewInMemoryLaptopStore().(*InMemoryLaptopStore)
+
+	wal, err := openStoreLog(
+		options,
+		"laptops",
+		func() proto.Message {
+			return &pb.LaptopLogEntry{}
+		},
+		func(entry proto.Message) {
+			store.replay(entry.(*pb.LaptopLogEntry))
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	store.wal = wal
+	return store, nil
+}
+
+// Close closes the write-ahead log of the store, if any
+func (store *InMemoryLaptopStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.wal == nil {
+		return nil
 	}
+
+	return store.wal.close()
 }
 
 func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
-	if store.data[laptop.Id] != nil {
+	if store.data[laptop.Id] != nil || store.deleted[laptop.Id] != nil {
 		return ErrAlreadyExists
 	}
 
+	laptop.Revision = 1
+	err := store.writeAhead(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: laptop}})
+	if err != nil {
+		return err
+	}
+
 	store.data[laptop.Id] = laptop
+	store.index(laptop)
+	store.events.append(pb.LaptopEvent_CREATED, laptop)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+func (store *InMemoryLaptopStore) SaveAll(laptops []*pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	ids := make(map[string]bool)
+	for _, laptop := range laptops {
+		if ids[laptop.Id] || store.data[laptop.Id] != nil || store.deleted[laptop.Id] != nil {
+			return ErrAlreadyExists
+		}
+		ids[laptop.Id] = true
+	}
+
+	for _, laptop := range laptops {
+		laptop.Revision = 1
+	}
+	err := store.writeAhead(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Batch{Batch: &pb.LaptopBatch{Laptops: laptops}}})
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		store.data[laptop.Id] = laptop
+		store.index(laptop)
+		store.events.append(pb.LaptopEvent_CREATED, laptop)
+	}
+	store.snapshotIfDue()
 
 	return nil
 }
@@ -53,14 +174,160 @@ func (store *InMemoryLaptopStore) Find(id string) *pb.Laptop {
 	return laptop
 }
 
+func (store *InMemoryLaptopStore) Update(laptop *pb.Laptop, expectedRevision uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	stored := store.data[laptop.Id]
+	if stored == nil {
+		return ErrNotFound
+	}
+
+	err := checkRevision(stored, expectedRevision)
+	if err != nil {
+		return err
+	}
+
+	laptop.Revision = stored.Revision + 1
+	err = store.writeAhead(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: laptop}})
+	if err != nil {
+		return err
+	}
+
+	store.unindex(stored)
+	store.data[laptop.Id] = laptop
+	store.index(laptop)
+	store.events.append(pb.LaptopEvent_UPDATED, laptop)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+func (store *InMemoryLaptopStore) Delete(id string, expectedRevision uint64) error {
+	return store.trash(id, expectedRevision, timestamppb.Now())
+}
+
+// trash moves the laptop to the trash with the given deletion time
+func (store *InMemoryLaptopStore) trash(id string, expectedRevision uint64, deletedAt *timestamppb.Timestamp) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	stored := store.data[id]
+	if stored == nil {
+		return ErrNotFound
+	}
+
+	err := checkRevision(stored, expectedRevision)
+	if err != nil {
+		return err
+	}
+
+	deleted := proto.Clone(stored).(*pb.Laptop)
+	deleted.Revision = stored.Revision + 1
+	deleted.DeletedAt = deletedAt
+	err = store.writeAhead(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: deleted}})
+	if err != nil {
+		return err
+	}
+
+	store.unindex(stored)
+	delete(store.data, id)
+	store.deleted[id] = deleted
+	store.events.append(pb.LaptopEvent_DELETED, deleted)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+func (store *InMemoryLaptopStore) FindDeleted(id string) *pb.Laptop {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.deleted[id]
+}
+
+func (store *InMemoryLaptopStore) ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	store.mutex.RLock()
+	laptops := make([]*pb.Laptop, 0, len(store.deleted))
+	for _, laptop := range store.deleted {
+		laptops = append(laptops, laptop)
+	}
+	store.mutex.RUnlock()
+
+	for _, laptop := range laptops {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *InMemoryLaptopStore) Undelete(id string, expectedRevision uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	deleted := store.deleted[id]
+	if deleted == nil {
+		return ErrNotFound
+	}
+
+	err := checkRevision(deleted, expectedRevision)
+	if err != nil {
+		return err
+	}
+
+	laptop := proto.Clone(deleted).(*pb.Laptop)
+	laptop.Revision = deleted.Revision + 1
+	laptop.DeletedAt = nil
+	err = store.writeAhead(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: laptop}})
+	if err != nil {
+		return err
+	}
+
+	delete(store.deleted, id)
+	store.data[id] = laptop
+	store.index(laptop)
+	store.events.append(pb.LaptopEvent_CREATED, laptop)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+func (store *InMemoryLaptopStore) Purge(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.deleted[id] == nil {
+		return ErrNotFound
+	}
+
+	err := store.writeAhead(&pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_DeletedId{DeletedId: id}})
+	if err != nil {
+		return err
+	}
+
+	delete(store.deleted, id)
+	store.snapshotIfDue()
+
+	return nil
+}
+
 func (store *InMemoryLaptopStore) Search(
 	ctx context.Context,
 	filter *pb.Filter,
 	found func(laptop *pb.Laptop) error,
 ) error {
-	store.mutex.RLock()
-	defer store.mutex.RUnlock()
-	for _, laptop := range store.data {
+	// Only hold the lock while taking a snapshot of the candidates,
+	// found may block on slow clients
+	candidates := store.candidates(filter)
+
+	for _, laptop := range candidates {
 		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
 			log.Print("context is canceled")
 			return nil
@@ -76,43 +343,187 @@ func (store *InMemoryLaptopStore) Search(
 	return nil
 }
 
-func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
-	if laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
-		return false
+func (store *InMemoryLaptopStore) Watch(
+	ctx context.Context,
+	afterSequence uint64,
+	found func(event *pb.LaptopEvent) error,
+) error {
+	return store.events.watch(ctx, afterSequence, found)
+}
+
+// restore replaces the laptops with the given ones and continues the events after the sequence,
+// which is how a raft snapshot is installed. Watchers behind the sequence get ErrEventsExpired,
+// as the events leading to the snapshot are not known.
+func (store *InMemoryLaptopStore) restore(laptops []*pb.Laptop, sequence uint64) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.data = make(map[string]*pb.Laptop)
+	store.deleted = make(map[string]*pb.Laptop)
+	store.indexes = nil
+	for _, index := range laptopIndexes {
+		store.indexes = append(store.indexes, newLaptopIndex(index.value))
+	}
+
+	for _, laptop := range laptops {
+		if laptop.GetDeletedAt() != nil {
+			store.deleted[laptop.Id] = laptop
+			continue
+		}
+
+		store.data[laptop.Id] = laptop
+		store.index(laptop)
+	}
+
+	store.events.resume(sequence)
+}
+
+// writeAhead logs the change before it is applied, if the store has a write-ahead log.
+// The entry records the sequence of the events the change is about to append.
+func (store *InMemoryLaptopStore) writeAhead(entry *pb.LaptopLogEntry) error {
+	if store.wal == nil {
+		return nil
 	}
 
-	if laptop.GetCpu().GetNumberCores() < filter.GetMinCpuCores() {
-		return false
+	entry.Sequence = store.events.current()
+	if entry.GetPut() != nil {
+		entry.Sequence++
 	}
+	entry.Sequence += uint64(len(entry.GetBatch().GetLaptops()))
+
+	return store.wal.append(entry)
+}
 
-	if laptop.GetCpu().GetMinGhz() < filter.GetMinCpuGhz() {
-		return false
+// snapshotIfDue writes a snapshot of the laptops once enough changes were logged.
+// The changes are already logged, so a failed snapshot is retried on the next change.
+func (store *InMemoryLaptopStore) snapshotIfDue() {
+	if store.wal == nil || !store.wal.snapshotDue() {
+		return
 	}
 
-	if toBit(laptop.GetRam()) < filter.GetMinRam().GetValue() {
-		return false
+	// The first entry only holds the sequence, as the events are not kept
+	entries := make([]proto.Message, 0, 1+len(store.data)+len(store.deleted))
+	entries = append(entries, &pb.LaptopLogEntry{Sequence: store.events.current()})
+	for _, laptop := range store.data {
+		entries = append(entries, &pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: laptop}})
+	}
+	for _, laptop := range store.deleted {
+		entries = append(entries, &pb.LaptopLogEntry{Entry: &pb.LaptopLogEntry_Put{Put: laptop}})
 	}
 
-	return true
+	err := store.wal.snapshot(entries)
+	if err != nil {
+		log.Printf("cannot snapshot laptops: %v", err)
+	}
 }
 
-func toBit(memory *pb.Memory) uint64 {
-	value := memory.GetValue()
+// replay applies a change read from the write-ahead log
+func (store *InMemoryLaptopStore) replay(entry *pb.LaptopLogEntry) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
-	switch memory.GetUnit() {
-	case pb.Memory_BIT:
-		return value
-	case pb.Memory_BYTE:
-		return value << 3 // * 2^3
-	case pb.Memory_KILOBYTE:
-		return value << 13 // * 2^3 * 2^10
-	case pb.Memory_MEGABYTE:
-		return value << 23
-	case pb.Memory_GIGABYTE:
-		return value << 33
-	case pb.Memory_TERABYTE:
-		return value << 43
-	default:
-		return 0
+	store.events.resume(entry.GetSequence())
+	if entry.GetEntry() == nil {
+		return
 	}
+
+	if batch := entry.GetBatch(); batch != nil {
+		for _, laptop := range batch.GetLaptops() {
+			store.replayPut(laptop.GetId(), laptop)
+		}
+		return
+	}
+
+	laptop := entry.GetPut()
+	id := entry.GetDeletedId()
+	if laptop != nil {
+		id = laptop.GetId()
+	}
+	store.replayPut(id, laptop)
+}
+
+// replayPut replaces the laptop with the id, or removes it if laptop is nil
+func (store *InMemoryLaptopStore) replayPut(id string, laptop *pb.Laptop) {
+	stored := store.data[id]
+	if stored != nil {
+		store.unindex(stored)
+		delete(store.data, id)
+	}
+	delete(store.deleted, id)
+
+	if laptop == nil {
+		return
+	}
+
+	if laptop.GetDeletedAt() != nil {
+		store.deleted[id] = laptop
+		return
+	}
+
+	store.data[id] = laptop
+	store.index(laptop)
+}
+
+// candidates returns the laptops within the most selective index range of the filter,
+// or every laptop if the filter has no indexed bound
+func (store *InMemoryLaptopStore) candidates(filter *pb.Filter) []*pb.Laptop {
+	// Requests without a filter search every laptop
+	if filter == nil {
+		filter = &pb.Filter{}
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var entries []indexEntry
+	indexed := false
+
+	for i, index := range laptopIndexes {
+		bounds, ok := index.bounds(filter)
+		if !ok {
+			continue
+		}
+
+		matches := store.indexes[i].lookup(bounds)
+		if !indexed || len(matches) < len(entries) {
+			entries = matches
+			indexed = true
+		}
+	}
+
+	if !indexed {
+		laptops := make([]*pb.Laptop, 0, len(store.data))
+		for _, laptop := range store.data {
+			laptops = append(laptops, laptop)
+		}
+		return laptops
+	}
+
+	laptops := make([]*pb.Laptop, 0, len(entries))
+	for _, entry := range entries {
+		laptops = append(laptops, store.data[entry.id])
+	}
+
+	return laptops
+}
+
+func (store *InMemoryLaptopStore) index(laptop *pb.Laptop) {
+	for _, index := range store.indexes {
+		index.insert(laptop)
+	}
+}
+
+func (store *InMemoryLaptopStore) unindex(laptop *pb.Laptop) {
+	for _, index := range store.indexes {
+		index.remove(laptop)
+	}
+}
+
+// checkRevision returns ErrRevisionMismatch if expectedRevision is set and differs from the stored one
+func checkRevision(stored *pb.Laptop, expectedRevision uint64) error {
+	if expectedRevision != 0 && stored.GetRevision() != expectedRevision {
+		return fmt.Errorf("%w: expected %d, current %d", ErrRevisionMismatch, expectedRevision, stored.GetRevision())
+	}
+
+	return nil
 }
@@ -0,0 +1,791 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gobook/pb"
+	"math"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	// Pure Go SQLite driver, registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order, each one exactly once, to bring the schema up to date
+var sqliteMigrations = []string{
+	`CREATE TABLE laptops (
+		id           TEXT PRIMARY KEY,
+		brand        TEXT NOT NULL,
+		name         TEXT NOT NULL,
+		price_usd    REAL NOT NULL,
+		cpu_cores    INTEGER NOT NULL,
+		cpu_min_ghz  REAL NOT NULL,
+		ram_bits     INTEGER NOT NULL,
+		release_year INTEGER NOT NULL,
+		revision     INTEGER NOT NULL,
+		data         BLOB NOT NULL
+	);
+	CREATE INDEX laptops_price_usd ON laptops (price_usd);
+	CREATE INDEX laptops_cpu_cores ON laptops (cpu_cores);
+	CREATE INDEX laptops_cpu_min_ghz ON laptops (cpu_min_ghz);
+	CREATE INDEX laptops_ram_bits ON laptops (ram_bits);`,
+
+	`CREATE TABLE laptop_events (
+		sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+		data     BLOB NOT NULL
+	);`,
+
+	`CREATE TABLE deleted_laptops (
+		id   TEXT PRIMARY KEY,
+		data BLOB NOT NULL
+	);`,
+
+	`CREATE TABLE laptop_revisions (
+		id       TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		data     BLOB NOT NULL,
+		PRIMARY KEY (id, revision)
+	);`,
+
+	// brand_key is the brand folded by foldKey, filled for the existing laptops by backfillSQLiteBrandKeys
+	`ALTER TABLE laptops ADD COLUMN brand_key TEXT NOT NULL DEFAULT '';
+	CREATE INDEX laptops_brand_key ON laptops (brand_key);`,
+}
+
+// SQLiteLaptopStore stores laptops in an SQLite database file.
+// Indexed columns are kept next to the protobuf encoded laptop so that searches
+// can be filtered by SQL before the remaining filter fields are checked.
+type SQLiteLaptopStore struct {
+	db       *sql.DB
+	notifier *changeNotifier
+}
+
+// NewSQLiteLaptopStore opens the database at path, creating and migrating it if needed
+func NewSQLiteLaptopStore(path string) (*SQLiteLaptopStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database %s: %w", path, err)
+	}
+
+	// A single connection serializes the writes, so SQLite never reports the database as busy
+	db.SetMaxOpenConns(1)
+
+	err = migrateSQLite(db)
+	if err == nil {
+		err = backfillSQLiteBrandKeys(db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &SQLiteLaptopStore{
+		db:       db,
+		notifier: newChangeNotifier(),
+	}
+
+	return store, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("cannot create migrations table: %w", err)
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("cannot read schema version: %w", err)
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("cannot begin migration %d: %w", version+1, err)
+		}
+
+		_, err = tx.Exec(sqliteMigrations[version])
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version+1)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("cannot apply migration %d: %w", version+1, err)
+		}
+	}
+
+	return nil
+}
+
+// backfillSQLiteBrandKeys sets the brand key of the laptops saved before the column existed.
+// SQLite only folds ASCII letters, so the keys are folded in Go like the in-memory filter does.
+func backfillSQLiteBrandKeys(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, brand FROM laptops WHERE brand_key = '' AND brand != ''`)
+	if err != nil {
+		return fmt.Errorf("cannot query brands: %w", err)
+	}
+
+	keys := make(map[string]string)
+	for rows.Next() {
+		var id, brand string
+		err = rows.Scan(&id, &brand)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot scan brand: %w", err)
+		}
+		keys[id] = foldKey(brand)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("cannot query brands: %w", err)
+	}
+
+	for id, key := range keys {
+		_, err := db.Exec(`UPDATE laptops SET brand_key = ? WHERE id = ?`, key, id)
+		if err != nil {
+			return fmt.Errorf("cannot set brand key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the database
+func (store *SQLiteLaptopStore) Close() error {
+	return store.db.Close()
+}
+
+func (store *SQLiteLaptopStore) Save(laptop *pb.Laptop) error {
+	return store.SaveAll([]*pb.Laptop{laptop})
+}
+
+func (store *SQLiteLaptopStore) SaveAll(laptops []*pb.Laptop) error {
+	return store.write(func(tx *sql.Tx) error {
+		for _, laptop := range laptops {
+			var count int
+			err := tx.QueryRow(
+				`SELECT (SELECT COUNT(*) FROM laptops WHERE id = ?) + (SELECT COUNT(*) FROM deleted_laptops WHERE id = ?)`,
+				laptop.GetId(),
+				laptop.GetId(),
+			).Scan(&count)
+			if err != nil {
+				return fmt.Errorf("cannot check laptop: %w", err)
+			}
+			if count > 0 {
+				return ErrAlreadyExists
+			}
+
+			laptop.Revision = 1
+			err = insertSQLiteLaptop(tx, laptop)
+			if err != nil {
+				return err
+			}
+
+			err = insertLaptopEvent(tx, pb.LaptopEvent_CREATED, laptop)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (store *SQLiteLaptopStore) Find(id string) *pb.Laptop {
+	var data []byte
+	err := store.db.QueryRow(`SELECT data FROM laptops WHERE id = ?`, id).Scan(&data)
+	if err != nil {
+		return nil
+	}
+
+	laptop := &pb.Laptop{}
+	err = proto.Unmarshal(data, laptop)
+	if err != nil {
+		return nil
+	}
+
+	return laptop
+}
+
+func (store *SQLiteLaptopStore) Update(laptop *pb.Laptop, expectedRevision uint64) error {
+	return store.write(func(tx *sql.Tx) error {
+		stored, err := findSQLiteLaptop(tx, laptop.GetId())
+		if err != nil {
+			return err
+		}
+
+		err = checkRevision(stored, expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		laptop.Revision = stored.Revision + 1
+		data, err := proto.Marshal(laptop)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+
+		_, err = tx.Exec(
+			`UPDATE laptops SET brand = ?, brand_key = ?, name = ?, price_usd = ?, cpu_cores = ?, cpu_min_ghz = ?,
+			ram_bits = ?, release_year = ?, revision = ?, data = ? WHERE id = ?`,
+			laptop.GetBrand(),
+			foldKey(laptop.GetBrand()),
+			laptop.GetName(),
+			laptop.GetPriceUsd(),
+			laptop.GetCpu().GetNumberCores(),
+			laptop.GetCpu().GetMinGhz(),
+			sqliteBits(laptop.GetRam()),
+			laptop.GetReleaseYear(),
+			laptop.GetRevision(),
+			data,
+			laptop.GetId(),
+		)
+		if err != nil {
+			return fmt.Errorf("cannot update laptop: %w", err)
+		}
+
+		return insertLaptopEvent(tx, pb.LaptopEvent_UPDATED, laptop)
+	})
+}
+
+func (store *SQLiteLaptopStore) Delete(id string, expectedRevision uint64) error {
+	return store.write(func(tx *sql.Tx) error {
+		deleted, err := findSQLiteLaptop(tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = checkRevision(deleted, expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM laptops WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("cannot delete laptop: %w", err)
+		}
+
+		deleted.Revision++
+		deleted.DeletedAt = timestamppb.Now()
+		data, err := proto.Marshal(deleted)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO deleted_laptops (id, data) VALUES (?, ?)`, id, data)
+		if err != nil {
+			return fmt.Errorf("cannot insert deleted laptop: %w", err)
+		}
+
+		return insertLaptopEvent(tx, pb.LaptopEvent_DELETED, deleted)
+	})
+}
+
+func (store *SQLiteLaptopStore) FindDeleted(id string) *pb.Laptop {
+	var data []byte
+	err := store.db.QueryRow(`SELECT data FROM deleted_laptops WHERE id = ?`, id).Scan(&data)
+	if err != nil {
+		return nil
+	}
+
+	laptop := &pb.Laptop{}
+	err = proto.Unmarshal(data, laptop)
+	if err != nil {
+		return nil
+	}
+
+	return laptop
+}
+
+func (store *SQLiteLaptopStore) ListDeleted(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	rows, err := store.db.QueryContext(ctx, `SELECT data FROM deleted_laptops ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("cannot list deleted laptops: %w", err)
+	}
+
+	laptops := []*pb.Laptop{}
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot read laptop: %w", err)
+		}
+
+		laptop := &pb.Laptop{}
+		err = proto.Unmarshal(data, laptop)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot unmarshal laptop: %w", err)
+		}
+		laptops = append(laptops, laptop)
+	}
+	rows.Close()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("cannot list deleted laptops: %w", rows.Err())
+	}
+
+	for _, laptop := range laptops {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *SQLiteLaptopStore) Undelete(id string, expectedRevision uint64) error {
+	return store.write(func(tx *sql.Tx) error {
+		var data []byte
+		err := tx.QueryRow(`SELECT data FROM deleted_laptops WHERE id = ?`, id).Scan(&data)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("cannot find deleted laptop: %w", err)
+		}
+
+		laptop := &pb.Laptop{}
+		err = proto.Unmarshal(data, laptop)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal laptop: %w", err)
+		}
+
+		err = checkRevision(laptop, expectedRevision)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM deleted_laptops WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("cannot undelete laptop: %w", err)
+		}
+
+		laptop.Revision++
+		laptop.DeletedAt = nil
+		err = insertSQLiteLaptop(tx, laptop)
+		if err != nil {
+			return err
+		}
+
+		return insertLaptopEvent(tx, pb.LaptopEvent_CREATED, laptop)
+	})
+}
+
+func (store *SQLiteLaptopStore) Purge(id string) error {
+	return store.write(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM deleted_laptops WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("cannot purge laptop: %w", err)
+		}
+
+		count, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("cannot purge laptop: %w", err)
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (store *SQLiteLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	where, args := sqliteFilter(filter)
+
+	rows, err := store.db.QueryContext(ctx, `SELECT data FROM laptops`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return fmt.Errorf("cannot search laptops: %w", err)
+	}
+
+	// Read every row before calling found, so a slow client does not keep the connection busy
+	laptops := []*pb.Laptop{}
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot read laptop: %w", err)
+		}
+
+		laptop := &pb.Laptop{}
+		err = proto.Unmarshal(data, laptop)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot unmarshal laptop: %w", err)
+		}
+		laptops = append(laptops, laptop)
+	}
+	rows.Close()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("cannot search laptops: %w", rows.Err())
+	}
+
+	for _, laptop := range laptops {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// SQL only checks the indexed bounds, the filter decides for the rest
+		if isQualified(filter, laptop) {
+			err := found(laptop)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (store *SQLiteLaptopStore) Watch(
+	ctx context.Context,
+	afterSequence uint64,
+	found func(event *pb.LaptopEvent) error,
+) error {
+	if afterSequence == 0 {
+		err := store.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(sequence), 0) FROM laptop_events`).Scan(&afterSequence)
+		if err != nil {
+			return fmt.Errorf("cannot read last event: %w", err)
+		}
+	}
+
+	since := func(afterSequence uint64) ([]*pb.LaptopEvent, error) {
+		return store.eventsSince(ctx, afterSequence)
+	}
+
+	return watchLaptopEvents(ctx, afterSequence, store.notifier, since, found)
+}
+
+func (store *SQLiteLaptopStore) eventsSince(ctx context.Context, afterSequence uint64) ([]*pb.LaptopEvent, error) {
+	var first, last uint64
+	err := store.db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(MIN(sequence), 0), COALESCE(MAX(sequence), 0) FROM laptop_events`,
+	).Scan(&first, &last)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read events range: %w", err)
+	}
+
+	if afterSequence > last || (first > 0 && afterSequence+1 < first) {
+		return nil, ErrEventsExpired
+	}
+
+	rows, err := store.db.QueryContext(
+		ctx,
+		`SELECT sequence, data FROM laptop_events WHERE sequence > ? ORDER BY sequence`,
+		afterSequence,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*pb.LaptopEvent{}
+	for rows.Next() {
+		var sequence uint64
+		var data []byte
+		err := rows.Scan(&sequence, &data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read event: %w", err)
+		}
+
+		event := &pb.LaptopEvent{}
+		err = proto.Unmarshal(data, event)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unmarshal event: %w", err)
+		}
+		event.Sequence = sequence
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// write runs fn in a transaction, then wakes up the watchers if it was committed
+func (store *SQLiteLaptopStore) write(fn func(tx *sql.Tx) error) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	store.notifier.notify()
+
+	return nil
+}
+
+func findSQLiteLaptop(tx *sql.Tx, id string) (*pb.Laptop, error) {
+	var data []byte
+	err := tx.QueryRow(`SELECT data FROM laptops WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot find laptop: %w", err)
+	}
+
+	laptop := &pb.Laptop{}
+	err = proto.Unmarshal(data, laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal laptop: %w", err)
+	}
+
+	return laptop, nil
+}
+
+// insertSQLiteLaptop inserts the laptop with its indexed columns
+func insertSQLiteLaptop(tx *sql.Tx, laptop *pb.Laptop) error {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO laptops (id, brand, brand_key, name, price_usd, cpu_cores, cpu_min_ghz, ram_bits, release_year, revision, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		laptop.GetId(),
+		laptop.GetBrand(),
+		foldKey(laptop.GetBrand()),
+		laptop.GetName(),
+		laptop.GetPriceUsd(),
+		laptop.GetCpu().GetNumberCores(),
+		laptop.GetCpu().GetMinGhz(),
+		sqliteBits(laptop.GetRam()),
+		laptop.GetReleaseYear(),
+		laptop.GetRevision(),
+		data,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot insert laptop: %w", err)
+	}
+
+	return nil
+}
+
+// insertLaptopEvent records the change and forgets the events too old to be resumed from
+func insertLaptopEvent(tx *sql.Tx, eventType pb.LaptopEvent_Type, laptop *pb.Laptop) error {
+	event := &pb.LaptopEvent{
+		Type:   eventType,
+		Laptop: laptop,
+		Time:   timestamppb.Now(),
+	}
+
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cannot marshal event: %w", err)
+	}
+
+	result, err := tx.Exec(`INSERT INTO laptop_events (data) VALUES (?)`, data)
+	if err != nil {
+		return fmt.Errorf("cannot insert event: %w", err)
+	}
+
+	sequence, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("cannot read event sequence: %w", err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM laptop_events WHERE sequence <= ?`, sequence-laptopEventHistory)
+	if err != nil {
+		return fmt.Errorf("cannot trim events: %w", err)
+	}
+
+	return nil
+}
+
+// sqliteBits returns the memory in bits for an INTEGER column, which holds at most math.MaxInt64
+func sqliteBits(memory *pb.Memory) int64 {
+	bits := toBit(memory)
+	if bits > math.MaxInt64 {
+		return math.MaxInt64
+	}
+
+	return int64(bits)
+}
+
+// sqliteFilter translates the filter bounds on indexed columns into a WHERE clause.
+// A nil filter has no bounds.
+func sqliteFilter(filter *pb.Filter) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if filter.MaxPriceUsd != nil {
+		add("price_usd <= ?", filter.GetMaxPriceUsd())
+	}
+	if filter.MinPriceUsd != nil {
+		add("price_usd >= ?", filter.GetMinPriceUsd())
+	}
+	if filter.MinCpuCores != nil {
+		add("cpu_cores >= ?", filter.GetMinCpuCores())
+	}
+	if filter.MinCpuGhz != nil {
+		add("cpu_min_ghz >= ?", filter.GetMinCpuGhz())
+	}
+	if filter.MinRam != nil {
+		add("ram_bits >= ?", sqliteBits(filter.GetMinRam()))
+	}
+	if filter.MinReleaseYear != nil {
+		add("release_year >= ?", filter.GetMinReleaseYear())
+	}
+	if filter.MaxReleaseYear != nil {
+		add("release_year <= ?", filter.GetMaxReleaseYear())
+	}
+	if len(filter.GetBrands()) > 0 {
+		placeholders := make([]string, len(filter.GetBrands()))
+		values := make([]interface{}, len(filter.GetBrands()))
+		for i, brand := range filter.GetBrands() {
+			placeholders[i] = "?"
+			values[i] = foldKey(brand)
+		}
+		add("brand_key IN ("+strings.Join(placeholders, ", ")+")", values...)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// SQLiteLaptopRevisionStore keeps the versions of the laptops in the database of a SQLiteLaptopStore
+type SQLiteLaptopRevisionStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteLaptopRevisionStore(laptopStore *SQLiteLaptopStore) LaptopRevisionStore {
+	return &SQLiteLaptopRevisionStore{
+		db: laptopStore.db,
+	}
+}
+
+func (store *SQLiteLaptopRevisionStore) Save(laptop *pb.Laptop) error {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	_, err = store.db.Exec(
+		`INSERT OR REPLACE INTO laptop_revisions (id, revision, data) VALUES (?, ?, ?)`,
+		laptop.GetId(), int64(laptop.GetRevision()), data,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot save revision: %w", err)
+	}
+
+	return nil
+}
+
+func (store *SQLiteLaptopRevisionStore) Find(id string, revision uint64) *pb.Laptop {
+	var data []byte
+	err := store.db.QueryRow(
+		`SELECT data FROM laptop_revisions WHERE id = ? AND revision = ?`,
+		id, int64(revision),
+	).Scan(&data)
+	if err != nil {
+		return nil
+	}
+
+	laptop := &pb.Laptop{}
+	err = proto.Unmarshal(data, laptop)
+	if err != nil {
+		return nil
+	}
+
+	return laptop
+}
+
+func (store *SQLiteLaptopRevisionStore) List(id string, found func(laptop *pb.Laptop) error) error {
+	rows, err := store.db.Query(`SELECT data FROM laptop_revisions WHERE id = ? ORDER BY revision`, id)
+	if err != nil {
+		return fmt.Errorf("cannot list revisions: %w", err)
+	}
+
+	// The rows are read before calling found, which may block, as there is a single connection
+	laptops := []*pb.Laptop{}
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot read revision: %w", err)
+		}
+
+		laptop := &pb.Laptop{}
+		err = proto.Unmarshal(data, laptop)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot unmarshal revision: %w", err)
+		}
+		laptops = append(laptops, laptop)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot list revisions: %w", err)
+	}
+
+	for _, laptop := range laptops {
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *SQLiteLaptopRevisionStore) Delete(id string) error {
+	_, err := store.db.Exec(`DELETE FROM laptop_revisions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("cannot delete revisions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSQLiteLaptopStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "laptops.db")
+
+	store, err := NewSQLiteLaptopStore(path)
+	require.NoError(t, err)
+
+	laptops := make(map[string]*pb.Laptop)
+	for i := 0; i < 20; i++ {
+		laptop := sample.NewLaptop()
+		err := store.Save(laptop)
+		require.NoError(t, err)
+		laptops[laptop.Id] = laptop
+	}
+
+	var laptop *pb.Laptop
+	for _, laptop = range laptops {
+		break
+	}
+
+	err = store.Save(laptop)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	found := store.Find(laptop.Id)
+	require.True(t, proto.Equal(laptop, found))
+
+	updated := proto.Clone(laptop).(*pb.Laptop)
+	updated.PriceUsd = 999
+	err = store.Update(updated, 2)
+	require.ErrorIs(t, err, ErrRevisionMismatch)
+	err = store.Update(updated, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), store.Find(laptop.Id).GetRevision())
+	laptops[laptop.Id] = updated
+
+	err = store.Update(sample.NewLaptop(), 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	filter := &pb.Filter{
+		MaxPriceUsd: proto.Float64(3000),
+		MinCpuCores: proto.Uint32(4),
+		MinRam:      &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE},
+	}
+	expected := make(map[string]bool)
+	for id, laptop := range laptops {
+		if isQualified(filter, laptop) {
+			expected[id] = true
+		}
+	}
+
+	result := make(map[string]bool)
+	err = store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		result[laptop.Id] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected, result)
+
+	err = store.Delete(laptop.Id, 0)
+	require.NoError(t, err)
+	require.Nil(t, store.Find(laptop.Id))
+
+	err = store.Close()
+	require.NoError(t, err)
+
+	// Laptops and events survive a restart
+	store, err = NewSQLiteLaptopStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.Nil(t, store.Find(laptop.Id))
+	// A nil filter searches every laptop
+	count := 0
+	err = store.Search(context.Background(), nil, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(laptops)-1, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*pb.LaptopEvent{}
+	err = store.Watch(ctx, 20, func(event *pb.LaptopEvent) error {
+		events = append(events, event)
+		if len(events) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, pb.LaptopEvent_UPDATED, events[0].GetType())
+	require.Equal(t, pb.LaptopEvent_DELETED, events[1].GetType())
+	require.Equal(t, uint64(22), events[1].GetSequence())
+}
+
+func TestSQLiteLaptopStoreBrandFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "laptops.db")
+	store, err := NewSQLiteLaptopStore(path)
+	require.NoError(t, err)
+
+	laptops := []*pb.Laptop{}
+	for _, brand := range []string{"Škoda", "ΣΟΦΟΣ", "\u212Aelvin", "Dell"} {
+		laptop := sample.NewLaptop()
+		laptop.Brand = brand
+		require.NoError(t, store.Save(laptop))
+		laptops = append(laptops, laptop)
+	}
+
+	// The brands match like in the memory store, beyond the ASCII letters SQLite folds
+	filter := &pb.Filter{Brands: []string{"škoda", "σοφος", "kelvin"}}
+	search := func() map[string]bool {
+		result := make(map[string]bool)
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			result[laptop.Id] = true
+			return nil
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	expected := make(map[string]bool)
+	for _, laptop := range laptops {
+		if isQualified(filter, laptop) {
+			expected[laptop.Id] = true
+		}
+	}
+	require.Len(t, expected, 3)
+	require.Equal(t, expected, search())
+
+	// The laptops saved before the brand key existed get theirs when the store opens
+	_, err = store.db.Exec(`UPDATE laptops SET brand_key = ''`)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = NewSQLiteLaptopStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	require.Equal(t, expected, search())
+}
+
+func TestSQLiteLaptopStoreTrash(t *testing.T) {
+	store, err := NewSQLiteLaptopStore(filepath.Join(t.TempDir(), "laptops.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	testLaptopStoreTrash(t, store)
+}
+
+func TestSQLiteLaptopStoreSaveAll(t *testing.T) {
+	store, err := NewSQLiteLaptopStore(filepath.Join(t.TempDir(), "laptops.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	testLaptopStoreSaveAll(t, store)
+}
+
+func TestSQLiteLaptopRevisionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "laptops.db")
+	store, err := NewSQLiteLaptopStore(path)
+	require.NoError(t, err)
+
+	testLaptopRevisionStore(t, NewSQLiteLaptopRevisionStore(store))
+
+	// The versions survive a restart
+	laptop := sample.NewLaptop()
+	laptop.Revision = 1
+	require.NoError(t, NewSQLiteLaptopRevisionStore(store).Save(laptop))
+	require.NoError(t, store.Close())
+
+	store, err = NewSQLiteLaptopStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	require.True(t, proto.Equal(laptop, NewSQLiteLaptopRevisionStore(store).Find(laptop.Id, 1)))
+}
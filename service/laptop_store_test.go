@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInMemoryLaptopStoreSearch(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+
+	laptops := make(map[string]*pb.Laptop)
+	for i := 0; i < 200; i++ {
+		laptop := sample.NewLaptop()
+		err := store.Save(laptop)
+		require.NoError(t, err)
+		laptops[laptop.Id] = laptop
+	}
+
+	// Updates and deletes must keep the indexes in sync
+	i := 0
+	for id, laptop := range laptops {
+		if i%3 == 0 {
+			err := store.Delete(id, 0)
+			require.NoError(t, err)
+			delete(laptops, id)
+		} else if i%3 == 1 {
+			updated := proto.Clone(laptop).(*pb.Laptop)
+			updated.PriceUsd = sample.NewLaptop().PriceUsd
+			updated.Cpu = sample.NewCPU()
+			err := store.Update(updated, 0)
+			require.NoError(t, err)
+			laptops[id] = updated
+		}
+		i++
+	}
+
+	filters := []*pb.Filter{
+		nil,
+		{},
+		{MaxPriceUsd: proto.Float64(2000)},
+		{MinPriceUsd: proto.Float64(2000), MaxPriceUsd: proto.Float64(2500)},
+		{MinCpuCores: proto.Uint32(6)},
+		{MinCpuGhz: proto.Float64(3), MinCpuCores: proto.Uint32(4)},
+		{MinRam: &pb.Memory{Value: 32, Unit: pb.Memory_GIGABYTE}, MaxPriceUsd: proto.Float64(3000)},
+		{MaxPriceUsd: proto.Float64(100)},
+	}
+
+	for _, filter := range filters {
+		expected := make(map[string]bool)
+		for id, laptop := range laptops {
+			if isQualified(filter, laptop) {
+				expected[id] = true
+			}
+		}
+
+		found := make(map[string]bool)
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			require.False(t, found[laptop.Id])
+			found[laptop.Id] = true
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, found, filter)
+	}
+}
+
+func TestInMemoryLaptopStoreSearchWithoutLock(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	err := store.Save(sample.NewLaptop())
+	require.NoError(t, err)
+
+	// Writing from the callback would deadlock if the lock was held while sending
+	err = store.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		return store.Save(sample.NewLaptop())
+	})
+	require.NoError(t, err)
+}
+
+func TestInMemoryLaptopStoreWatch(t *testing.T) {
+	store := NewInMemoryLaptopStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan *pb.LaptopEvent, 10)
+	watching := make(chan error, 1)
+	watch := func(afterSequence uint64) {
+		watching <- store.Watch(ctx, afterSequence, func(event *pb.LaptopEvent) error {
+			events <- event
+			return nil
+		})
+	}
+
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	go watch(0)
+	time.Sleep(50 * time.Millisecond)
+
+	updated := proto.Clone(laptop).(*pb.Laptop)
+	updated.PriceUsd = 1000
+	err = store.Update(updated, 0)
+	require.NoError(t, err)
+	err = store.Delete(laptop.Id, 0)
+	require.NoError(t, err)
+
+	event := <-events
+	require.Equal(t, uint64(2), event.GetSequence())
+	require.Equal(t, pb.LaptopEvent_UPDATED, event.GetType())
+	require.Equal(t, 1000.0, event.GetLaptop().GetPriceUsd())
+
+	event = <-events
+	require.Equal(t, uint64(3), event.GetSequence())
+	require.Equal(t, pb.LaptopEvent_DELETED, event.GetType())
+
+	cancel()
+	require.NoError(t, <-watching)
+
+	// Resuming replays the events after the given sequence
+	ctx, cancel = context.WithCancel(context.Background())
+	go watch(1)
+
+	for _, sequence := range []uint64{2, 3} {
+		event := <-events
+		require.Equal(t, sequence, event.GetSequence())
+	}
+
+	cancel()
+	require.NoError(t, <-watching)
+}
+
+func TestLaptopEventLogExpired(t *testing.T) {
+	events := newLaptopEventLog(2)
+	for i := 0; i < 4; i++ {
+		events.append(pb.LaptopEvent_CREATED, sample.NewLaptop())
+	}
+
+	pending, err := events.since(2)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+
+	_, err = events.since(1)
+	require.ErrorIs(t, err, ErrEventsExpired)
+
+	_, err = events.since(5)
+	require.ErrorIs(t, err, ErrEventsExpired)
+}
+
+func TestInMemoryLaptopStoreSaveAll(t *testing.T) {
+	testLaptopStoreSaveAll(t, NewInMemoryLaptopStore())
+}
+
+// testLaptopStoreSaveAll checks that a LaptopStore implementation saves a batch all or nothing
+func testLaptopStoreSaveAll(t *testing.T, store LaptopStore) {
+	existing := sample.NewLaptop()
+	err := store.Save(existing)
+	require.NoError(t, err)
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+
+	err = store.SaveAll([]*pb.Laptop{laptop1, existing})
+	require.ErrorIs(t, err, ErrAlreadyExists)
+	require.Nil(t, store.Find(laptop1.Id))
+
+	err = store.SaveAll([]*pb.Laptop{laptop1, laptop1})
+	require.ErrorIs(t, err, ErrAlreadyExists)
+	require.Nil(t, store.Find(laptop1.Id))
+
+	err = store.SaveAll([]*pb.Laptop{laptop1, laptop2})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), store.Find(laptop1.Id).GetRevision())
+	require.Equal(t, uint64(1), store.Find(laptop2.Id).GetRevision())
+
+	// The failed batches left no event
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*pb.LaptopEvent{}
+	err = store.Watch(ctx, 1, func(event *pb.LaptopEvent) error {
+		events = append(events, event)
+		if len(events) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	for i, laptop := range []*pb.Laptop{laptop1, laptop2} {
+		require.Equal(t, uint64(i+2), events[i].GetSequence())
+		require.Equal(t, pb.LaptopEvent_CREATED, events[i].GetType())
+		require.Equal(t, laptop.Id, events[i].GetLaptop().GetId())
+	}
+}
+
+func TestInMemoryLaptopStoreTrash(t *testing.T) {
+	testLaptopStoreTrash(t, NewInMemoryLaptopStore())
+}
+
+// testLaptopStoreTrash checks the soft deletion of a LaptopStore implementation
+func testLaptopStoreTrash(t *testing.T, store LaptopStore) {
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	err = store.Undelete(laptop.Id, 0)
+	require.ErrorIs(t, err, ErrNotFound)
+	err = store.Purge(laptop.Id)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = store.Delete(laptop.Id, 0)
+	require.NoError(t, err)
+	require.Nil(t, store.Find(laptop.Id))
+
+	deleted := store.FindDeleted(laptop.Id)
+	require.NotNil(t, deleted)
+	require.NotNil(t, deleted.GetDeletedAt())
+	require.Equal(t, uint64(2), deleted.GetRevision())
+
+	err = store.Search(context.Background(), &pb.Filter{}, func(found *pb.Laptop) error {
+		require.NotEqual(t, laptop.Id, found.Id)
+		return nil
+	})
+	require.NoError(t, err)
+
+	trash := []string{}
+	err = store.ListDeleted(context.Background(), func(found *pb.Laptop) error {
+		trash = append(trash, found.Id)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{laptop.Id}, trash)
+
+	// The id stays taken while the laptop is in the trash
+	err = store.Save(laptop)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+	err = store.Delete(laptop.Id, 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = store.Undelete(laptop.Id, 1)
+	require.ErrorIs(t, err, ErrRevisionMismatch)
+	err = store.Undelete(laptop.Id, 2)
+	require.NoError(t, err)
+	require.Nil(t, store.FindDeleted(laptop.Id))
+
+	restored := store.Find(laptop.Id)
+	require.NotNil(t, restored)
+	require.Nil(t, restored.GetDeletedAt())
+	require.Equal(t, uint64(3), restored.GetRevision())
+
+	err = store.Delete(laptop.Id, 3)
+	require.NoError(t, err)
+	err = store.Purge(laptop.Id)
+	require.NoError(t, err)
+	require.Nil(t, store.Find(laptop.Id))
+	require.Nil(t, store.FindDeleted(laptop.Id))
+
+	// A purged id can be used again
+	err = store.Save(laptop)
+	require.NoError(t, err)
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"fmt"
+	"gobook/pb"
+	"math"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minReleaseYear is the earliest release year accepted for a laptop
+const minReleaseYear = 1970
+
+// maxMemoryBits is the largest memory accepted, so its bits fit the int64 columns of SQLite
+const maxMemoryBits = math.MaxInt64
+
+// laptopValidator collects the invalid fields of a laptop, with their proto field paths
+type laptopValidator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+// validateLaptop returns an InvalidArgument error with a BadRequest detail listing
+// every invalid field of the laptop, or nil if the laptop is valid. The id is checked by the caller.
+func validateLaptop(laptop *pb.Laptop) error {
+	validator := &laptopValidator{}
+	validator.laptop(laptop)
+
+	if len(validator.violations) == 0 {
+		return nil
+	}
+
+	first := validator.violations[0]
+	st := status.Newf(
+		codes.InvalidArgument,
+		"laptop has %d invalid fields, first %s %s",
+		len(validator.violations),
+		first.GetField(),
+		first.GetDescription(),
+	)
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: validator.violations})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+func (validator *laptopValidator) add(field string, format string, args ...interface{}) {
+	validator.violations = append(validator.violations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+func (validator *laptopValidator) required(field string, value string) {
+	if value == "" {
+		validator.add(field, "is required")
+	}
+}
+
+// positive tells if the value is a finite number above zero, which NaN is not
+func positive(value float64) bool {
+	return value > 0 && !math.IsInf(value, 0)
+}
+
+func (validator *laptopValidator) laptop(laptop *pb.Laptop) {
+	validator.required("brand", laptop.GetBrand())
+	validator.required("name", laptop.GetName())
+	validator.cpu("cpu", laptop.GetCpu())
+	validator.memory("ram", laptop.GetRam())
+
+	for i, gpu := range laptop.GetGpus() {
+		validator.gpu(fmt.Sprintf("gpus[%d]", i), gpu)
+	}
+
+	if len(laptop.GetStorages()) == 0 {
+		validator.add("storages", "must have at least one storage")
+	}
+	for i, storage := range laptop.GetStorages() {
+		validator.storage(fmt.Sprintf("storages[%d]", i), storage)
+	}
+
+	validator.screen("screen", laptop.GetScreen())
+	validator.keyboard("keyboard", laptop.GetKeyboard())
+
+	switch weight := laptop.GetWeight().(type) {
+	case nil:
+		validator.add("weight_kg", "is required")
+	case *pb.Laptop_WeightKg:
+		if !positive(weight.WeightKg) {
+			validator.add("weight_kg", "must be positive")
+		}
+	case *pb.Laptop_WeightLb:
+		if !positive(weight.WeightLb) {
+			validator.add("weight_lb", "must be positive")
+		}
+	}
+
+	if !positive(laptop.GetPriceUsd()) {
+		validator.add("price_usd", "must be positive")
+	}
+
+	maxReleaseYear := uint32(time.Now().Year() + 1)
+	if laptop.GetReleaseYear() < minReleaseYear || laptop.GetReleaseYear() > maxReleaseYear {
+		validator.add("release_year", "must be between %d and %d", minReleaseYear, maxReleaseYear)
+	}
+}
+
+func (validator *laptopValidator) cpu(field string, cpu *pb.CPU) {
+	if cpu == nil {
+		validator.add(field, "is required")
+		return
+	}
+
+	validator.required(field+".brand", cpu.GetBrand())
+	validator.required(field+".name", cpu.GetName())
+
+	if cpu.GetNumberCores() == 0 {
+		validator.add(field+".number_cores", "must be positive")
+	}
+	if cpu.GetNumberThreads() < cpu.GetNumberCores() {
+		validator.add(field+".number_threads", "must be at least the number of cores")
+	}
+
+	validator.frequency(field, cpu.GetMinGhz(), cpu.GetMaxGhz())
+}
+
+func (validator *laptopValidator) gpu(field string, gpu *pb.GPU) {
+	if gpu == nil {
+		validator.add(field, "is required")
+		return
+	}
+
+	validator.required(field+".brand", gpu.GetBrand())
+	validator.required(field+".name", gpu.GetName())
+	validator.frequency(field, gpu.GetMinGhz(), gpu.GetMaxGhz())
+	validator.memory(field+".memory", gpu.GetMemory())
+}
+
+func (validator *laptopValidator) frequency(field string, minGhz float64, maxGhz float64) {
+	if !positive(minGhz) {
+		validator.add(field+".min_ghz", "must be positive")
+	}
+	if !(maxGhz >= minGhz) || math.IsInf(maxGhz, 0) {
+		validator.add(field+".max_ghz", "must be at least min_ghz")
+	}
+}
+
+func (validator *laptopValidator) memory(field string, memory *pb.Memory) {
+	if memory == nil {
+		validator.add(field, "is required")
+		return
+	}
+
+	if memory.GetValue() == 0 {
+		validator.add(field+".value", "must be positive")
+	}
+	shift, ok := memoryUnitShifts[memory.GetUnit()]
+	if !ok {
+		validator.add(field+".unit", "must be known")
+	} else if memory.GetValue() > maxMemoryBits>>shift {
+		validator.add(field+".value", "must be at most %d bits", uint64(maxMemoryBits))
+	}
+}
+
+func (validator *laptopValidator) storage(field string, storage *pb.Storage) {
+	if storage == nil {
+		validator.add(field, "is required")
+		return
+	}
+
+	if storage.GetDriver() == pb.Storage_UNKNOWN {
+		validator.add(field+".driver", "must be known")
+	}
+	validator.memory(field+".memory", storage.GetMemory())
+}
+
+func (validator *laptopValidator) screen(field string, screen *pb.Screen) {
+	if screen == nil {
+		validator.add(field, "is required")
+		return
+	}
+
+	if !positive(float64(screen.GetSizeInch())) {
+		validator.add(field+".size_inch", "must be positive")
+	}
+
+	resolution := screen.GetResolution()
+	if resolution == nil {
+		validator.add(field+".resolution", "is required")
+	} else {
+		if resolution.GetWidth() == 0 {
+			validator.add(field+".resolution.width", "must be positive")
+		}
+		if resolution.GetHeight() == 0 {
+			validator.add(field+".resolution.height", "must be positive")
+		}
+	}
+
+	if screen.GetPanel() == pb.Screen_UNKNOWN {
+		validator.add(field+".panel", "must be known")
+	}
+}
+
+func (validator *laptopValidator) keyboard(field string, keyboard *pb.Keyboard) {
+	if keyboard == nil {
+		validator.add(field, "is required")
+		return
+	}
+
+	if keyboard.GetLayout() == pb.Keyboard_UNKNOWN {
+		validator.add(field+".layout", "must be known")
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateLaptop(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(laptop *pb.Laptop)
+		fields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(laptop *pb.Laptop) {},
+		},
+		{
+			name: "cpu",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Cpu.NumberCores = 0
+				laptop.Cpu.MinGhz = 3.5
+				laptop.Cpu.MaxGhz = 2.5
+			},
+			fields: []string{"cpu.number_cores", "cpu.max_ghz"},
+		},
+		{
+			name: "threads_less_than_cores",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Cpu.NumberCores = 8
+				laptop.Cpu.NumberThreads = 4
+			},
+			fields: []string{"cpu.number_threads"},
+		},
+		{
+			name: "unknown_units",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Ram.Unit = pb.Memory_UNKNOWN
+				laptop.Gpus[0].Memory.Unit = pb.Memory_UNKNOWN
+				laptop.Storages[0].Driver = pb.Storage_UNKNOWN
+			},
+			fields: []string{"ram.unit", "gpus[0].memory.unit", "storages[0].driver"},
+		},
+		{
+			name: "missing_messages",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Brand = ""
+				laptop.Screen = nil
+				laptop.Keyboard = nil
+				laptop.Storages = nil
+			},
+			fields: []string{"brand", "storages", "screen", "keyboard"},
+		},
+		{
+			name: "screen",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Screen.Resolution.Width = 0
+				laptop.Screen.Panel = pb.Screen_UNKNOWN
+			},
+			fields: []string{"screen.resolution.width", "screen.panel"},
+		},
+		{
+			name: "price_weight_year",
+			modify: func(laptop *pb.Laptop) {
+				laptop.PriceUsd = -1
+				laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 0}
+				laptop.ReleaseYear = 1900
+			},
+			fields: []string{"weight_lb", "price_usd", "release_year"},
+		},
+		{
+			name: "not_a_number",
+			modify: func(laptop *pb.Laptop) {
+				laptop.PriceUsd = math.NaN()
+				laptop.Weight = &pb.Laptop_WeightKg{WeightKg: math.NaN()}
+				laptop.Cpu.MinGhz = math.NaN()
+				laptop.Screen.SizeInch = float32(math.NaN())
+			},
+			fields: []string{"cpu.min_ghz", "cpu.max_ghz", "screen.size_inch", "weight_kg", "price_usd"},
+		},
+		{
+			name: "infinite",
+			modify: func(laptop *pb.Laptop) {
+				laptop.PriceUsd = math.Inf(1)
+				laptop.Weight = &pb.Laptop_WeightLb{WeightLb: math.Inf(1)}
+				laptop.Cpu.MaxGhz = math.Inf(1)
+				laptop.Screen.SizeInch = float32(math.Inf(1))
+			},
+			fields: []string{"cpu.max_ghz", "screen.size_inch", "weight_lb", "price_usd"},
+		},
+		{
+			name: "memory_overflow",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Ram = &pb.Memory{Value: 1<<30 + 1, Unit: pb.Memory_GIGABYTE}
+				laptop.Storages[0].Memory = &pb.Memory{Value: 1<<63 + 1, Unit: pb.Memory_BIT}
+			},
+			fields: []string{"ram.value", "storages[0].memory.value"},
+		},
+		{
+			name: "largest_memory",
+			modify: func(laptop *pb.Laptop) {
+				laptop.Ram = &pb.Memory{Value: 1<<30 - 1, Unit: pb.Memory_GIGABYTE}
+				laptop.Storages[0].Memory = &pb.Memory{Value: math.MaxInt64, Unit: pb.Memory_BIT}
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			laptop := sample.NewLaptop()
+			tc.modify(laptop)
+
+			err := validateLaptop(laptop)
+			if len(tc.fields) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Equal(t, tc.fields, violationFields(t, err))
+		})
+	}
+}
+
+func TestLaptopServerCreateInvalidLaptop(t *testing.T) {
+	laptop := sample.NewLaptop()
+	laptop.Cpu.NumberThreads = laptop.Cpu.NumberCores - 1
+	laptop.Screen = nil
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+	res, err := server.CreateLaptopService(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Nil(t, res)
+	require.Equal(t, []string{"cpu.number_threads", "screen"}, violationFields(t, err))
+}
+
+// violationFields returns the field paths of the BadRequest detail of an InvalidArgument error
+func violationFields(t *testing.T, err error) []string {
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+
+	details := st.Details()
+	require.Len(t, details, 1)
+
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	fields := []string{}
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields = append(fields, violation.GetField())
+	}
+	return fields
+}
@@ -1,9 +1,22 @@
 package service
 
-import "sync"
+import (
+	"gobook/pb"
+	"log"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
 
 type RatingStore interface {
 	Add(laptopID string, score float64) (*Rating, error)
+	Find(laptopID string) *Rating
+	// Set replaces the rating of the laptop
+	Set(laptopID string, rating *Rating) error
+	// List calls found with the rating of every rated laptop
+	List(found func(laptopID string, rating *Rating) error) error
+	// Delete removes the rating of the laptop, if any
+	Delete(laptopID string) error
 }
 
 type Rating struct {
@@ -11,9 +24,20 @@ type Rating struct {
 	Sum   float64
 }
 
+// Average returns the average score, or 0 if the laptop has not been rated
+func (rating *Rating) Average() float64 {
+	if rating == nil || rating.Count == 0 {
+		return 0
+	}
+
+	return rating.Sum / float64(rating.Count)
+}
+
 type InMemoryRatingStore struct {
 	mutex  sync.RWMutex
 	rating map[string]*Rating
+	// wal is the optional write-ahead log of the changes
+	wal *storeLog
 }
 
 func NewInMemoryRatingStore() RatingStore {
@@ -22,21 +46,179 @@ func NewInMemoryRatingStore() RatingStore {
 	}
 }
 
+// NewInMemoryRatingStoreWithWAL returns an InMemoryRatingStore that logs its changes
+// in the ratings files of the options dir, after restoring the ratings they hold
+func NewInMemoryRatingStoreWithWAL(options WALOptions) (*InMemoryRatingStore, error) {
+	store := NewInMemoryRatingStore().(*InMemoryRatingStore)
+
+	wal, err := openStoreLog(
+		options,
+		"ratings",
+		func() proto.Message {
+			return &pb.RatingRecord{}
+		},
+		func(entry proto.Message) {
+			record := entry.(*pb.RatingRecord)
+			// A record without ratings is logged by Delete
+			if record.GetCount() == 0 {
+				delete(store.rating, record.GetLaptopId())
+				return
+			}
+			store.rating[record.GetLaptopId()] = &Rating{
+				Count: record.GetCount(),
+				Sum:   record.GetSum(),
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	store.wal = wal
+	return store, nil
+}
+
+// Close closes the write-ahead log of the store, if any
+func (store *InMemoryRatingStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.wal == nil {
+		return nil
+	}
+
+	return store.wal.close()
+}
+
 func (store *InMemoryRatingStore) Add(laptopID string, score float64) (*Rating, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rating := &Rating{
+		Count: 1,
+		Sum:   score,
+	}
+	if stored := store.rating[laptopID]; stored != nil {
+		rating.Count += stored.Count
+		rating.Sum += stored.Sum
+	}
+
+	// The log keeps the resulting rating rather than the score, so replaying it twice is harmless
+	if store.wal != nil {
+		err := store.wal.append(&pb.RatingRecord{
+			LaptopId: laptopID,
+			Count:    rating.Count,
+			Sum:      rating.Sum,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	store.rating[laptopID] = rating
+	store.snapshotIfDue()
+
+	return rating, nil
+}
+
+func (store *InMemoryRatingStore) Find(laptopID string) *Rating {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
 	rating := store.rating[laptopID]
 	if rating == nil {
-		rating = &Rating{
-			Count: 1,
-			Sum:   score,
+		return nil
+	}
+
+	return &Rating{
+		Count: rating.Count,
+		Sum:   rating.Sum,
+	}
+}
+
+func (store *InMemoryRatingStore) Set(laptopID string, rating *Rating) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.wal != nil {
+		err := store.wal.append(&pb.RatingRecord{
+			LaptopId: laptopID,
+			Count:    rating.Count,
+			Sum:      rating.Sum,
+		})
+		if err != nil {
+			return err
 		}
-	} else {
-		rating.Count++
-		rating.Sum += score
 	}
 
-	store.rating[laptopID] = rating
-	return rating, nil
+	store.rating[laptopID] = &Rating{
+		Count: rating.Count,
+		Sum:   rating.Sum,
+	}
+	store.snapshotIfDue()
+
+	return nil
+}
+
+func (store *InMemoryRatingStore) List(found func(laptopID string, rating *Rating) error) error {
+	store.mutex.RLock()
+	ratings := make(map[string]*Rating, len(store.rating))
+	for laptopID, rating := range store.rating {
+		ratings[laptopID] = &Rating{
+			Count: rating.Count,
+			Sum:   rating.Sum,
+		}
+	}
+	store.mutex.RUnlock()
+
+	for laptopID, rating := range ratings {
+		err := found(laptopID, rating)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *InMemoryRatingStore) Delete(laptopID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.rating[laptopID] == nil {
+		return nil
+	}
+
+	if store.wal != nil {
+		err := store.wal.append(&pb.RatingRecord{LaptopId: laptopID})
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(store.rating, laptopID)
+	store.snapshotIfDue()
+
+	return nil
+}
+
+// snapshotIfDue writes a snapshot of the ratings once enough changes were logged
+func (store *InMemoryRatingStore) snapshotIfDue() {
+	if store.wal == nil || !store.wal.snapshotDue() {
+		return
+	}
+
+	entries := make([]proto.Message, 0, len(store.rating))
+	for laptopID, rating := range store.rating {
+		entries = append(entries, &pb.RatingRecord{
+			LaptopId: laptopID,
+			Count:    rating.Count,
+			Sum:      rating.Sum,
+		})
+	}
+
+	err := store.wal.snapshot(entries)
+	if err != nil {
+		log.Printf("cannot snapshot ratings: %v", err)
+	}
 }
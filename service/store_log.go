@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gobook/serializer"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// WALOptions configures the write-ahead log of an in-memory store
+type WALOptions struct {
+	// Dir keeps the log and snapshot files of the stores
+	Dir string
+	// SnapshotEvery writes a snapshot and empties the log after this many changes, 0 never does
+	SnapshotEvery int
+	// Sync flushes every change to disk before the store acknowledges it
+	Sync bool
+}
+
+// storeLog is the write-ahead log of an in-memory store: every change is appended to
+// <name>.wal before it is applied, and <name>.snapshot holds the state when the log was emptied.
+// Entries must be idempotent, so replaying a log over a newer snapshot gives the same state.
+type storeLog struct {
+	options WALOptions
+	name    string
+	file    *os.File
+	// size is the length of the valid records of the file
+	size int64
+	// entries is the number of changes logged since the last snapshot
+	entries int
+}
+
+// openStoreLog calls replay with the entries of the snapshot then of the log, and opens the log to append.
+// A torn record at the end of the log is truncated, as its change was never acknowledged.
+// A corrupt record followed by more data fails instead, since acknowledged changes would be lost.
+func openStoreLog(
+	options WALOptions,
+	name string,
+	newEntry func() proto.Message,
+	replay func(entry proto.Message),
+) (*storeLog, error) {
+	err := os.MkdirAll(options.Dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create wal dir: %w", err)
+	}
+
+	wal := &storeLog{
+		options: options,
+		name:    name,
+	}
+
+	snapshot, err := os.Open(wal.snapshotPath())
+	if err == nil {
+		_, err = readStoreLog(serializer.NewRecordReader(snapshot), newEntry, replay)
+		snapshot.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read snapshot %s: %w", wal.snapshotPath(), err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot open snapshot: %w", err)
+	}
+
+	file, err := os.OpenFile(wal.logPath(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open wal: %w", err)
+	}
+
+	reader := serializer.NewRecordReader(file)
+	wal.entries, err = readStoreLog(reader, newEntry, replay)
+	wal.size = reader.Offset()
+	if errors.Is(err, serializer.ErrCorruptRecord) {
+		torn, tornErr := isTornTail(file, wal.size)
+		if tornErr != nil {
+			err = tornErr
+		} else if torn {
+			log.Printf("truncate wal %s after %d entries: %v", wal.logPath(), wal.entries, err)
+			err = file.Truncate(wal.size)
+		} else {
+			err = fmt.Errorf("record at offset %d is followed by more data: %w", wal.size, err)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("cannot read wal: %w", err)
+	}
+
+	_, err = file.Seek(wal.size, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("cannot seek wal: %w", err)
+	}
+
+	wal.file = file
+	return wal, nil
+}
+
+// isTornTail tells if the corrupt record at offset runs to the end of file, as one cut short by a crash does
+func isTornTail(file *os.File, offset int64) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("cannot stat wal: %w", err)
+	}
+
+	header := make([]byte, binary.MaxVarintLen64)
+	n, err := file.ReadAt(header, offset)
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("cannot read wal: %w", err)
+	}
+
+	length, size := binary.Uvarint(header[:n])
+	if size == 0 {
+		// The length itself is cut short
+		return true, nil
+	}
+	if size < 0 {
+		return false, nil
+	}
+
+	// The data follows the length and the checksum
+	remaining := info.Size() - offset - int64(size) - 4
+	return remaining <= 0 || length >= uint64(remaining), nil
+}
+
+// readStoreLog replays the records of reader and counts them
+func readStoreLog(
+	reader *serializer.RecordReader,
+	newEntry func() proto.Message,
+	replay func(entry proto.Message),
+) (int, error) {
+	count := 0
+	for {
+		entry := newEntry()
+		err := reader.Read(entry)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		replay(entry)
+		count++
+	}
+}
+
+func (wal *storeLog) logPath() string {
+	return filepath.Join(wal.options.Dir, wal.name+".wal")
+}
+
+func (wal *storeLog) snapshotPath() string {
+	return filepath.Join(wal.options.Dir, wal.name+".snapshot")
+}
+
+// append logs the change. On error the log is left as it was, and the change must not be applied.
+func (wal *storeLog) append(entry proto.Message) error {
+	err := serializer.WriteRecord(wal.file, entry)
+	if err == nil && wal.options.Sync {
+		err = wal.file.Sync()
+	}
+	if err != nil {
+		// Drop a partial record, so the next ones are not lost behind it
+		wal.file.Truncate(wal.size)
+		wal.file.Seek(wal.size, io.SeekStart)
+		return fmt.Errorf("cannot append to wal: %w", err)
+	}
+
+	offset, err := wal.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("cannot append to wal: %w", err)
+	}
+
+	wal.size = offset
+	wal.entries++
+	return nil
+}
+
+// snapshotDue tells if enough changes were logged since the last snapshot
+func (wal *storeLog) snapshotDue() bool {
+	return wal.options.SnapshotEvery > 0 && wal.entries >= wal.options.SnapshotEvery
+}
+
+// snapshot replaces the snapshot with the entries, then empties the log.
+// The store must not change until it returns.
+func (wal *storeLog) snapshot(entries []proto.Message) error {
+	temp := wal.snapshotPath() + ".tmp"
+
+	file, err := os.Create(temp)
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		err = serializer.WriteRecord(writer, entry)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, wal.snapshotPath())
+	}
+	if err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+
+	// A crash before the log is emptied replays it over the new snapshot, which is harmless
+	err = wal.file.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("cannot truncate wal: %w", err)
+	}
+	_, err = wal.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("cannot truncate wal: %w", err)
+	}
+
+	wal.size = 0
+	wal.entries = 0
+	return nil
+}
+
+func (wal *storeLog) close() error {
+	return wal.file.Close()
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"gobook/pb"
+	"gobook/sample"
+	"gobook/serializer"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInMemoryLaptopStoreWithWAL(t *testing.T) {
+	options := WALOptions{
+		Dir:           t.TempDir(),
+		SnapshotEvery: 7,
+		Sync:          true,
+	}
+
+	store, err := NewInMemoryLaptopStoreWithWAL(options)
+	require.NoError(t, err)
+
+	laptops := make(map[string]*pb.Laptop)
+	for i := 0; i < 10; i++ {
+		laptop := sample.NewLaptop()
+		err := store.Save(laptop)
+		require.NoError(t, err)
+		laptops[laptop.Id] = laptop
+	}
+
+	var laptop *pb.Laptop
+	for _, laptop = range laptops {
+		break
+	}
+
+	updated := proto.Clone(laptop).(*pb.Laptop)
+	updated.PriceUsd = 999
+	err = store.Update(updated, 1)
+	require.NoError(t, err)
+	laptops[laptop.Id] = updated
+
+	deleted := sample.NewLaptop()
+	err = store.Save(deleted)
+	require.NoError(t, err)
+	err = store.Delete(deleted.Id, 0)
+	require.NoError(t, err)
+
+	// The first 7 changes are in the snapshot, the next 6 in the log
+	_, err = os.Stat(filepath.Join(options.Dir, "laptops.snapshot"))
+	require.NoError(t, err)
+	require.Equal(t, 6, store.wal.entries)
+
+	err = store.Close()
+	require.NoError(t, err)
+
+	// A torn record at the end of the log is dropped
+	wal := filepath.Join(options.Dir, "laptops.wal")
+	file, err := os.OpenFile(wal, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.Write([]byte{200, 1, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	before, err := os.Stat(wal)
+	require.NoError(t, err)
+
+	store, err = NewInMemoryLaptopStoreWithWAL(options)
+	require.NoError(t, err)
+	defer store.Close()
+
+	after, err := os.Stat(wal)
+	require.NoError(t, err)
+	require.Equal(t, before.Size()-4, after.Size())
+
+	restored := make(map[string]*pb.Laptop)
+	err = store.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		restored[laptop.Id] = laptop
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, restored, len(laptops))
+	for id, laptop := range laptops {
+		require.True(t, proto.Equal(laptop, restored[id]))
+	}
+	require.Equal(t, uint64(2), store.Find(laptop.Id).GetRevision())
+	require.NotNil(t, store.FindDeleted(deleted.Id))
+
+	// The events keep their numbering, and the ones before the restart have expired
+	require.Equal(t, uint64(13), store.events.current())
+	err = store.Watch(context.Background(), 5, func(event *pb.LaptopEvent) error {
+		return nil
+	})
+	require.ErrorIs(t, err, ErrEventsExpired)
+
+	// The restored indexes answer searches
+	count := 0
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: proto.Float64(999)}, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, count, 1)
+
+	err = store.Save(sample.NewLaptop())
+	require.NoError(t, err)
+
+	// A batch is replayed like the laptops saved one by one
+	batch := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	err = store.SaveAll(batch)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = NewInMemoryLaptopStoreWithWAL(options)
+	require.NoError(t, err)
+	defer store.Close()
+	for _, laptop := range batch {
+		require.True(t, proto.Equal(laptop, store.Find(laptop.Id)))
+	}
+	require.Equal(t, uint64(16), store.events.current())
+
+	// The sequence survives a snapshot with an empty log too
+	saves := options.SnapshotEvery - store.wal.entries
+	for i := 0; i < saves; i++ {
+		require.NoError(t, store.Save(sample.NewLaptop()))
+	}
+	require.Equal(t, 0, store.wal.entries)
+	require.NoError(t, store.Close())
+
+	store, err = NewInMemoryLaptopStoreWithWAL(options)
+	require.NoError(t, err)
+	defer store.Close()
+	require.Equal(t, uint64(16+saves), store.events.current())
+}
+
+func TestInMemoryRatingStoreWithWAL(t *testing.T) {
+	options := WALOptions{
+		Dir:           t.TempDir(),
+		SnapshotEvery: 3,
+	}
+
+	store, err := NewInMemoryRatingStoreWithWAL(options)
+	require.NoError(t, err)
+
+	laptopID := sample.NewLaptop().Id
+	for _, score := range []float64{1, 2, 3, 4, 5} {
+		_, err := store.Add(laptopID, score)
+		require.NoError(t, err)
+	}
+	_, err = store.Add("other", 5)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = NewInMemoryRatingStoreWithWAL(options)
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.Equal(t, &Rating{Count: 5, Sum: 15}, store.Find(laptopID))
+	require.Equal(t, &Rating{Count: 1, Sum: 5}, store.Find("other"))
+
+	rating, err := store.Add(laptopID, 5)
+	require.NoError(t, err)
+	require.Equal(t, &Rating{Count: 6, Sum: 20}, rating)
+}
+
+func TestStoreLogCorruptRecord(t *testing.T) {
+	options := WALOptions{Dir: t.TempDir()}
+
+	store, err := NewInMemoryRatingStoreWithWAL(options)
+	require.NoError(t, err)
+	for _, laptopID := range []string{"laptop1", "laptop2", "laptop3"} {
+		_, err := store.Add(laptopID, 5)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Close())
+
+	wal := filepath.Join(options.Dir, "ratings.wal")
+	data, err := os.ReadFile(wal)
+	require.NoError(t, err)
+
+	// A corrupt record in the middle of the log fails, the records after it were acknowledged
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(wal, corrupt, 0600))
+	_, err = NewInMemoryRatingStoreWithWAL(options)
+	require.ErrorIs(t, err, serializer.ErrCorruptRecord)
+	after, err := os.ReadFile(wal)
+	require.NoError(t, err)
+	require.Equal(t, corrupt, after)
+
+	// A corrupt last record is a torn write, it is dropped
+	corrupt = append([]byte{}, data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(wal, corrupt, 0600))
+	store, err = NewInMemoryRatingStoreWithWAL(options)
+	require.NoError(t, err)
+	defer store.Close()
+	require.NotNil(t, store.Find("laptop2"))
+	require.Nil(t, store.Find("laptop3"))
+}
+
+func TestInMemoryLaptopRevisionStoreWithWAL(t *testing.T) {
+	options := WALOptions{
+		Dir:           t.TempDir(),
+		SnapshotEvery: 3,
+	}
+
+	store, err := NewInMemoryLaptopRevisionStoreWithWAL(options)
+	require.NoError(t, err)
+	testLaptopRevisionStore(t, store)
+
+	laptop := sample.NewLaptop()
+	laptop.Revision = 1
+	require.NoError(t, store.Save(laptop))
+	deleted := sample.NewLaptop()
+	deleted.Revision = 1
+	require.NoError(t, store.Save(deleted))
+	require.NoError(t, store.Delete(deleted.Id))
+	require.NoError(t, store.Close())
+
+	store, err = NewInMemoryLaptopRevisionStoreWithWAL(options)
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.True(t, proto.Equal(laptop, store.Find(laptop.Id, 1)))
+	require.Nil(t, store.Find(deleted.Id, 1))
+}
@@ -8,6 +8,8 @@ import (
 type UserStore interface {
 	Save(user *User) error
 	Find(username string) (*User, error)
+	// List calls found with every user
+	List(found func(user *User) error) error
 }
 
 type InMemoryUserStore struct {
@@ -22,8 +24,8 @@ func NewInMemoryUserStore() UserStore {
 }
 
 func (store *InMemoryUserStore) Save(user *User) error {
-	store.mutex.RLock()
-	defer store.mutex.RUnlock()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
 	if store.users[user.Username] != nil {
 		return ErrAlreadyExists
@@ -44,3 +46,21 @@ func (store *InMemoryUserStore) Find(username string) (*User, error) {
 
 	return user, nil
 }
+
+func (store *InMemoryUserStore) List(found func(user *User) error) error {
+	store.mutex.RLock()
+	users := make([]*User, 0, len(store.users))
+	for _, user := range store.users {
+		users = append(users, user.Clone())
+	}
+	store.mutex.RUnlock()
+
+	for _, user := range users {
+		err := found(user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,9 +1,52 @@
 package util
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	TokenSymmetricKey string `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	// StorageDriver selects where the data is stored: memory, wal, sqlite, bolt or raft
+	StorageDriver string `mapstructure:"STORAGE_DRIVER"`
+	// StoragePath is the database file of the persistent storage drivers
+	StoragePath string `mapstructure:"STORAGE_PATH"`
+	// WALDir keeps the write-ahead logs and snapshots of the wal driver
+	WALDir string `mapstructure:"WAL_DIR"`
+	// WALSnapshotEvery is the number of logged changes after which a store writes a snapshot
+	WALSnapshotEvery int `mapstructure:"WAL_SNAPSHOT_EVERY"`
+	// WALSync flushes every logged change to disk before acknowledging it
+	WALSync bool `mapstructure:"WAL_SYNC"`
+	// ClusterNodeID is the id of this node in the cluster peers of the raft driver
+	ClusterNodeID string `mapstructure:"CLUSTER_NODE_ID"`
+	// ClusterPeers lists the id=address of every cluster node, separated by commas
+	ClusterPeers string `mapstructure:"CLUSTER_PEERS"`
+	// ClusterDataDir keeps the raft log and snapshots of the nodes, in a folder per node id
+	ClusterDataDir string `mapstructure:"CLUSTER_DATA_DIR"`
+	// ClusterPeerName is the name the certificate of every cluster node is valid for
+	ClusterPeerName string `mapstructure:"CLUSTER_PEER_NAME"`
+	// IdempotencyTTL is how long the response of a request with an idempotency key is replayed
+	IdempotencyTTL time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+	// ImageUploadTTL is how long a resumable image upload is kept after its last chunk
+	ImageUploadTTL time.Duration `mapstructure:"IMAGE_UPLOAD_TTL"`
+	// ImageVariants lists the name=size of the variants generated for every uploaded image, separated by commas
+	ImageVariants string `mapstructure:"IMAGE_VARIANTS"`
+	// ImageResizeWorkers is the number of workers generating the image variants
+	ImageResizeWorkers int `mapstructure:"IMAGE_RESIZE_WORKERS"`
+	// PurgeRetention is how long a deleted laptop stays in the trash before it is purged with its images and ratings
+	PurgeRetention time.Duration `mapstructure:"PURGE_RETENTION"`
+	// PurgeInterval is how often the trash is checked for laptops to purge
+	PurgeInterval time.Duration `mapstructure:"PURGE_INTERVAL"`
+}
+
+// durationDefaults are the durations used when they are not configured
+var durationDefaults = map[string]time.Duration{
+	"IDEMPOTENCY_TTL":  24 * time.Hour,
+	"IMAGE_UPLOAD_TTL": 24 * time.Hour,
+	"PURGE_RETENTION":  30 * 24 * time.Hour,
+	"PURGE_INTERVAL":   time.Hour,
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -11,10 +54,39 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigFile(path)
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
+	for key, value := range durationDefaults {
+		viper.SetDefault(key, value)
+	}
 	err = viper.ReadInConfig()
 	if err != nil {
 		return
 	}
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+	err = config.validate()
 	return
 }
+
+// validate returns an error if a duration is not positive: a zero ticker interval panics,
+// and a zero retention would purge the deleted laptops right away
+func (config Config) validate() error {
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"IDEMPOTENCY_TTL", config.IdempotencyTTL},
+		{"IMAGE_UPLOAD_TTL", config.ImageUploadTTL},
+		{"PURGE_RETENTION", config.PurgeRetention},
+		{"PURGE_INTERVAL", config.PurgeInterval},
+	}
+
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			return fmt.Errorf("%s must be a positive duration, got %v", duration.key, duration.value)
+		}
+	}
+
+	return nil
+}